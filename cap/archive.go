@@ -0,0 +1,165 @@
+package cap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrArchiveConflict is returned when an archived alert with the same sender,
+// identifier and sent date but different content already exists
+var ErrArchiveConflict = errors.New("A different alert with the same sender, identifier and sent date is already archived")
+
+// ErrNotArchived is returned when a requested alert is not in the archive
+var ErrNotArchived = errors.New("Alert is not archived")
+
+// Archive stores every received alert for later review
+//
+// Alerts are keyed by their sender, identifier and sent date. Storing an exact
+// repeat of an archived alert is not an error, but storing a different alert
+// under the same key returns ErrArchiveConflict.
+type Archive interface {
+	// Put parses and stores the raw XML of an alert, returning false if it was already archived
+	Put(raw []byte) (bool, error)
+
+	// Get returns back the archived alert with the specified reference
+	Get(ref Reference) (*ArchivedAlert, error)
+
+	// Query returns back the archived alerts matching the query, ordered by sent date
+	Query(q ArchiveQuery) ([]*ArchivedAlert, error)
+
+	// Close releases the resources held by the archive
+	Close() error
+}
+
+// ArchivedAlert is an alert stored in an Archive
+type ArchivedAlert struct {
+	// Raw is the XML exactly as it was received
+	Raw []byte
+
+	// Alert is the parsed form of Raw
+	Alert *Alert
+}
+
+// Reference returns back the sender,identifier,sent reference of the archived alert
+func (a *ArchivedAlert) Reference() Reference {
+	return a.Alert.Reference()
+}
+
+// ArchiveQuery selects alerts from an Archive
+//
+// Zero values match every alert. An alert matches Event, Severity and the
+// geocode fields if any of its Info blocks (or Areas, for geocodes) match.
+type ArchiveQuery struct {
+	// Since and Until restrict the sent date of the alert to [Since, Until)
+	Since time.Time
+	Until time.Time
+
+	Event    string
+	Severity string
+
+	GeocodeName  string
+	GeocodeValue string
+
+	// Limit is the maximum number of alerts returned, or 0 for no limit
+	Limit int
+}
+
+// Matches returns back whether the alert is selected by the query
+func (q *ArchiveQuery) Matches(alert *Alert) bool {
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		sent, err := ParseCAPDate(alert.SentDate)
+
+		if err != nil {
+			return false
+		}
+
+		if !q.Since.IsZero() && sent.Before(q.Since) {
+			return false
+		}
+
+		if !q.Until.IsZero() && !sent.Before(q.Until) {
+			return false
+		}
+	}
+
+	if q.Event == "" && q.Severity == "" && q.GeocodeName == "" {
+		return true
+	}
+
+	for _, info := range alert.Infos {
+		if q.Event != "" && info.EventType != q.Event {
+			continue
+		}
+
+		if q.Severity != "" && info.Severity != q.Severity {
+			continue
+		}
+
+		if q.GeocodeName == "" || infoHasGeocode(&info, q.GeocodeName, q.GeocodeValue) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func infoHasGeocode(info *Info, name, value string) bool {
+	for _, area := range info.Areas {
+		for _, geocode := range area.Geocodes {
+			if geocode.ValueName == name && (value == "" || geocode.Value == value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseArchivedAlert parses the raw XML of a CAP 1.2 or CAP 1.1 alert
+func parseArchivedAlert(raw []byte) (*ArchivedAlert, error) {
+	alert, err := ParseAlert(raw)
+
+	if err != nil {
+		alert11, err11 := ParseAlert11(raw)
+
+		if err11 != nil {
+			return nil, err
+		}
+
+		alert = &alert11.Alert
+	}
+
+	return &ArchivedAlert{Raw: raw, Alert: alert}, nil
+}
+
+// archiveKey returns back a file and database safe key for a reference
+func archiveKey(ref Reference) string {
+	sum := sha256.Sum256([]byte(ref.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// sameArchivedContent reports whether two raw alerts are exact repeats
+func sameArchivedContent(a, b []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(a), bytes.TrimSpace(b))
+}
+
+// sortArchived orders archived alerts by sent date, applying the query limit
+func sortArchived(found []*ArchivedAlert, limit int) []*ArchivedAlert {
+	sort.SliceStable(found, func(i, j int) bool {
+		return archivedSentUnix(found[i]) < archivedSentUnix(found[j])
+	})
+
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+
+	return found
+}
+
+func archivedSentUnix(a *ArchivedAlert) int64 {
+	return sentUnix(a.Alert)
+}
@@ -0,0 +1,231 @@
+package cap
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileArchive is an Archive that stores each alert as an XML file in a directory
+//
+// Files are laid out as <dir>/<yyyy>/<mm>/<dd>/<key>.xml using the sent date of
+// the alert, so the archive can also be browsed by hand. The parsed alerts are
+// indexed in memory when the archive is opened.
+type FileArchive struct {
+	dir     string
+	skipped []error
+
+	mu    sync.RWMutex
+	index map[string]fileArchiveEntry
+}
+
+type fileArchiveEntry struct {
+	path  string
+	alert *Alert
+}
+
+// NewFileArchive opens the FileArchive in dir, creating the directory if needed
+//
+// Files that cannot be read or parsed are left out of the archive rather than
+// failing the whole directory, and are reported by Skipped. Files are parsed
+// with DefaultLimits, and larger files are skipped without being read.
+func NewFileArchive(dir string) (*FileArchive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	archive := &FileArchive{dir: dir, index: make(map[string]fileArchiveEntry)}
+
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !strings.HasSuffix(path, ".xml") {
+			return err
+		}
+
+		raw, err := readArchiveFile(path)
+
+		if err == nil {
+			var archived *ArchivedAlert
+
+			if archived, err = parseArchivedAlert(raw); err == nil {
+				archive.index[archiveKey(archived.Reference())] = fileArchiveEntry{path: path, alert: archived.Alert}
+				return nil
+			}
+		}
+
+		archive.skipped = append(archive.skipped, fmt.Errorf("Skipped archived alert %q: %s", path, err))
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// Skipped returns back the errors for the files left out when the archive was opened
+func (fa *FileArchive) Skipped() []error {
+	return fa.skipped
+}
+
+// Put parses and stores the raw XML of an alert, returning false if it was already archived
+func (fa *FileArchive) Put(raw []byte) (bool, error) {
+	archived, err := parseArchivedAlert(raw)
+
+	if err != nil {
+		return false, err
+	}
+
+	key := archiveKey(archived.Reference())
+
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+
+	if entry, exists := fa.index[key]; exists {
+		existing, err := readArchiveFile(entry.path)
+
+		if err != nil {
+			return false, err
+		}
+
+		if !sameArchivedContent(existing, raw) {
+			return false, ErrArchiveConflict
+		}
+
+		return false, nil
+	}
+
+	path := filepath.Join(fa.dir, fileArchiveDatePath(archived.Alert), key+".xml")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+
+	// Write to a temporary file first so a crash never leaves a partial alert behind
+	tmp := path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return false, err
+	}
+
+	fa.index[key] = fileArchiveEntry{path: path, alert: archived.Alert}
+
+	return true, nil
+}
+
+// Get returns back the archived alert with the specified reference
+func (fa *FileArchive) Get(ref Reference) (*ArchivedAlert, error) {
+	fa.mu.RLock()
+	entry, exists := fa.index[archiveKey(ref)]
+	fa.mu.RUnlock()
+
+	if !exists {
+		return nil, ErrNotArchived
+	}
+
+	return fa.load(entry)
+}
+
+// Query returns back the archived alerts matching the query, ordered by sent date
+func (fa *FileArchive) Query(q ArchiveQuery) ([]*ArchivedAlert, error) {
+	fa.mu.RLock()
+
+	var matches []fileArchiveEntry
+
+	for _, entry := range fa.index {
+		if q.Matches(entry.alert) {
+			matches = append(matches, entry)
+		}
+	}
+
+	fa.mu.RUnlock()
+
+	found := make([]*ArchivedAlert, 0, len(matches))
+	paths := make(map[*ArchivedAlert]string, len(matches))
+
+	for _, entry := range matches {
+		archived := &ArchivedAlert{Alert: entry.alert}
+		paths[archived] = entry.path
+		found = append(found, archived)
+	}
+
+	found = sortArchived(found, q.Limit)
+
+	// Only read the files for the alerts that survived the limit
+	for _, archived := range found {
+		raw, err := readArchiveFile(paths[archived])
+
+		if err != nil {
+			return nil, err
+		}
+
+		archived.Raw = raw
+	}
+
+	return found, nil
+}
+
+// Close releases the resources held by the archive
+func (fa *FileArchive) Close() error {
+	return nil
+}
+
+func (fa *FileArchive) load(entry fileArchiveEntry) (*ArchivedAlert, error) {
+	raw, err := readArchiveFile(entry.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArchivedAlert{Raw: raw, Alert: entry.alert}, nil
+}
+
+// readArchiveFile returns back the contents of an archived file, or a
+// *LimitError without reading it all if it exceeds DefaultLimits.MaxDocumentSize
+func readArchiveFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	max := DefaultLimits.MaxDocumentSize
+
+	if max <= 0 {
+		return ioutil.ReadAll(f)
+	}
+
+	raw, err := ioutil.ReadAll(io.LimitReader(f, max+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(raw)) > max {
+		return nil, &LimitError{Limit: "MaxDocumentSize", Max: max, Offset: max}
+	}
+
+	return raw, nil
+}
+
+// fileArchiveDatePath returns back the yyyy/mm/dd directory for an alert
+func fileArchiveDatePath(alert *Alert) string {
+	sent, err := ParseCAPDate(alert.SentDate)
+
+	if err != nil {
+		return "undated"
+	}
+
+	return sent.UTC().Format("2006/01/02")
+}
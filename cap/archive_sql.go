@@ -0,0 +1,242 @@
+package cap
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// sqlArchiveSchema creates the tables used by SQLArchive
+var sqlArchiveSchema = []string{
+	`CREATE TABLE IF NOT EXISTS cap_alerts (
+		key        TEXT PRIMARY KEY,
+		sender     TEXT NOT NULL,
+		identifier TEXT NOT NULL,
+		sent       TEXT NOT NULL,
+		sent_unix  INTEGER,
+		raw        BLOB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS cap_alerts_sent ON cap_alerts (sent_unix)`,
+	`CREATE TABLE IF NOT EXISTS cap_alert_infos (
+		key        TEXT NOT NULL,
+		info_index INTEGER NOT NULL,
+		event      TEXT NOT NULL,
+		severity   TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS cap_alert_infos_key ON cap_alert_infos (key)`,
+	`CREATE TABLE IF NOT EXISTS cap_alert_geocodes (
+		key        TEXT NOT NULL,
+		info_index INTEGER NOT NULL,
+		name       TEXT NOT NULL,
+		value      TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS cap_alert_geocodes_value ON cap_alert_geocodes (name, value)`,
+}
+
+// SQLArchive is an Archive backed by a SQLite database
+//
+// The database handle is opened by the caller with a SQLite driver of their
+// choice, for example the pure Go modernc.org/sqlite or github.com/mattn/go-sqlite3,
+// and remains theirs to close. The tables are created when the archive is
+// opened if they do not already exist.
+//
+// Each connection to an in-memory SQLite database opens a separate, empty
+// database, so limit the handle to one connection with db.SetMaxOpenConns(1)
+// or use a shared cache DSN such as "file::memory:?cache=shared".
+type SQLArchive struct {
+	db *sql.DB
+}
+
+// NewSQLArchive opens an SQLArchive in the database, creating its tables if needed
+func NewSQLArchive(db *sql.DB) (*SQLArchive, error) {
+	for _, stmt := range sqlArchiveSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	return &SQLArchive{db: db}, nil
+}
+
+// Put parses and stores the raw XML of an alert, returning false if it was already archived
+func (sa *SQLArchive) Put(raw []byte) (bool, error) {
+	archived, err := parseArchivedAlert(raw)
+
+	if err != nil {
+		return false, err
+	}
+
+	key := archiveKey(archived.Reference())
+
+	tx, err := sa.db.Begin()
+
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	var existing []byte
+	err = tx.QueryRow(`SELECT raw FROM cap_alerts WHERE key = ?`, key).Scan(&existing)
+
+	switch {
+	case err == nil:
+		if !sameArchivedContent(existing, raw) {
+			return false, ErrArchiveConflict
+		}
+
+		return false, nil
+	case err != sql.ErrNoRows:
+		return false, err
+	}
+
+	var sentUnix interface{}
+
+	if sent, err := ParseCAPDate(archived.Alert.SentDate); err == nil {
+		sentUnix = sent.Unix()
+	}
+
+	alert := archived.Alert
+
+	_, err = tx.Exec(`INSERT INTO cap_alerts (key, sender, identifier, sent, sent_unix, raw) VALUES (?, ?, ?, ?, ?, ?)`,
+		key, alert.SenderID, alert.MessageID, alert.SentDate, sentUnix, raw)
+
+	if err != nil {
+		return false, err
+	}
+
+	for i, info := range alert.Infos {
+		_, err = tx.Exec(`INSERT INTO cap_alert_infos (key, info_index, event, severity) VALUES (?, ?, ?, ?)`,
+			key, i, info.EventType, info.Severity)
+
+		if err != nil {
+			return false, err
+		}
+
+		for _, area := range info.Areas {
+			for _, geocode := range area.Geocodes {
+				_, err = tx.Exec(`INSERT INTO cap_alert_geocodes (key, info_index, name, value) VALUES (?, ?, ?, ?)`,
+					key, i, geocode.ValueName, geocode.Value)
+
+				if err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Get returns back the archived alert with the specified reference
+func (sa *SQLArchive) Get(ref Reference) (*ArchivedAlert, error) {
+	var raw []byte
+	err := sa.db.QueryRow(`SELECT raw FROM cap_alerts WHERE key = ?`, archiveKey(ref)).Scan(&raw)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotArchived
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseArchivedAlert(raw)
+}
+
+// Query returns back the archived alerts matching the query, ordered by sent date
+func (sa *SQLArchive) Query(q ArchiveQuery) ([]*ArchivedAlert, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	if !q.Since.IsZero() {
+		where = append(where, `a.sent_unix >= ?`)
+		args = append(args, q.Since.Unix())
+	}
+
+	if !q.Until.IsZero() {
+		where = append(where, `a.sent_unix < ?`)
+		args = append(args, q.Until.Unix())
+	}
+
+	if q.Event != "" || q.Severity != "" || q.GeocodeName != "" {
+		infoWhere := []string{`i.key = a.key`}
+
+		if q.Event != "" {
+			infoWhere = append(infoWhere, `i.event = ?`)
+			args = append(args, q.Event)
+		}
+
+		if q.Severity != "" {
+			infoWhere = append(infoWhere, `i.severity = ?`)
+			args = append(args, q.Severity)
+		}
+
+		if q.GeocodeName != "" {
+			geocodeWhere := `g.key = i.key AND g.info_index = i.info_index AND g.name = ?`
+			args = append(args, q.GeocodeName)
+
+			if q.GeocodeValue != "" {
+				geocodeWhere += ` AND g.value = ?`
+				args = append(args, q.GeocodeValue)
+			}
+
+			infoWhere = append(infoWhere, `EXISTS (SELECT 1 FROM cap_alert_geocodes g WHERE `+geocodeWhere+`)`)
+		}
+
+		where = append(where, `EXISTS (SELECT 1 FROM cap_alert_infos i WHERE `+strings.Join(infoWhere, ` AND `)+`)`)
+	}
+
+	query := `SELECT a.raw FROM cap_alerts a`
+
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+
+	query += ` ORDER BY a.sent_unix, a.rowid`
+
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+
+	rows, err := sa.db.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var found []*ArchivedAlert
+
+	for rows.Next() {
+		var raw []byte
+
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+
+		archived, err := parseArchivedAlert(raw)
+
+		if err != nil {
+			return nil, err
+		}
+
+		found = append(found, archived)
+	}
+
+	return found, rows.Err()
+}
+
+// Close releases the resources held by the archive
+//
+// The database handle passed to NewSQLArchive is left open for the caller to close.
+func (sa *SQLArchive) Close() error {
+	return nil
+}
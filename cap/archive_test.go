@@ -0,0 +1,279 @@
+package cap
+
+import (
+	"bytes"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func getArchiveExample() ([]byte, error) {
+	return ioutil.ReadFile("../examples/nws_alert.xml")
+}
+
+// archiveTestAlert returns back a CAP 1.2 alert with a single Info block
+func archiveTestAlert(id, sent, event, severity, fips string) []byte {
+	return []byte(`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+	<identifier>` + id + `</identifier>
+	<sender>sender@example.com</sender>
+	<sent>` + sent + `</sent>
+	<status>Actual</status>
+	<msgType>Alert</msgType>
+	<scope>Public</scope>
+	<info>
+		<category>Met</category>
+		<event>` + event + `</event>
+		<urgency>Expected</urgency>
+		<severity>` + severity + `</severity>
+		<certainty>Likely</certainty>
+		<area>
+			<areaDesc>Test</areaDesc>
+			<geocode><valueName>FIPS6</valueName><value>` + fips + `</value></geocode>
+		</area>
+	</info>
+</alert>`)
+}
+
+func withArchives(t *testing.T, fn func(t *testing.T, archive Archive)) {
+	dir, err := ioutil.TempDir("", "cap-archive")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	t.Run("File", func(t *testing.T) {
+		archive, err := NewFileArchive(dir)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer archive.Close()
+		fn(t, archive)
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		db, err := sql.Open("sqlite", ":memory:")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every connection to :memory: is a separate database
+		db.SetMaxOpenConns(1)
+		defer db.Close()
+
+		archive, err := NewSQLArchive(db)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer archive.Close()
+		fn(t, archive)
+	})
+}
+
+func TestArchivePutDeduplicatesExactRepeats(t *testing.T) {
+	raw, err := getArchiveExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withArchives(t, func(t *testing.T, archive Archive) {
+		added, err := archive.Put(raw)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertEqual(t, added, true, "The first copy should be archived")
+
+		added, err = archive.Put(raw)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertEqual(t, added, false, "An exact repeat should not be archived again")
+	})
+}
+
+func TestArchivePutReturnsErrForConflictingAlert(t *testing.T) {
+	withArchives(t, func(t *testing.T, archive Archive) {
+		archive.Put(archiveTestAlert("1", "2015-08-15T20:45:00-05:00", "Flood Warning", "Moderate", "005067"))
+
+		_, err := archive.Put(archiveTestAlert("1", "2015-08-15T20:45:00-05:00", "Flood Warning", "Severe", "005067"))
+
+		assertEqual(t, err, ErrArchiveConflict, "A conflicting alert should be rejected")
+	})
+}
+
+func TestArchiveGetReturnsRawAndParsedAlert(t *testing.T) {
+	raw, err := getArchiveExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withArchives(t, func(t *testing.T, archive Archive) {
+		archive.Put(raw)
+
+		archived, err := archive.Get(Reference{
+			SenderID:  "w-nws.webmaster@noaa.gov",
+			MessageID: "NOAA-NWS-ALERTS-AR1253BA3B00A4.FloodWarning.1253BA3D4A94AR.LZKFLSLZK.342064b5a5aafb8265dfc3707d6a3b09",
+			SentDate:  "2015-08-15T20:45:00-05:00",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertEqual(t, string(archived.Raw), string(raw), "Raw XML should be preserved")
+		assertEqual(t, archived.Alert.Infos[0].EventType, "Flood Warning", "EventType does not match!")
+
+		_, err = archive.Get(Reference{SenderID: "nobody", MessageID: "1", SentDate: "x"})
+
+		assertEqual(t, err, ErrNotArchived, "Missing alerts should return ErrNotArchived")
+	})
+}
+
+func TestArchiveQuerySelectsMatchingAlerts(t *testing.T) {
+	withArchives(t, func(t *testing.T, archive Archive) {
+		archive.Put(archiveTestAlert("1", "2015-08-15T20:45:00-05:00", "Flood Warning", "Moderate", "005067"))
+		archive.Put(archiveTestAlert("2", "2015-08-16T20:45:00-05:00", "Tornado Warning", "Extreme", "005147"))
+		archive.Put(archiveTestAlert("3", "2015-08-17T20:45:00-05:00", "Flood Warning", "Severe", "005147"))
+
+		ids := func(q ArchiveQuery) string {
+			found, err := archive.Query(q)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result []string
+
+			for _, archived := range found {
+				result = append(result, archived.Alert.MessageID)
+			}
+
+			return strings.Join(result, ",")
+		}
+
+		assertEqual(t, ids(ArchiveQuery{}), "1,2,3", "Every alert should match an empty query")
+		assertEqual(t, ids(ArchiveQuery{Event: "Flood Warning"}), "1,3", "Event query does not match!")
+		assertEqual(t, ids(ArchiveQuery{Severity: "Extreme"}), "2", "Severity query does not match!")
+		assertEqual(t, ids(ArchiveQuery{GeocodeName: "FIPS6", GeocodeValue: "005147"}), "2,3", "Geocode query does not match!")
+		assertEqual(t, ids(ArchiveQuery{Limit: 2}), "1,2", "Limit was not applied")
+		assertEqual(t,
+			ids(ArchiveQuery{
+				Since: time.Date(2015, 8, 16, 12, 0, 0, 0, time.UTC),
+				Until: time.Date(2015, 8, 18, 1, 45, 0, 0, time.UTC),
+			}),
+			"2",
+			"Time range query does not match!")
+	})
+}
+
+func TestFileArchiveReopensExistingAlerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cap-archive")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	archive, _ := NewFileArchive(dir)
+	archive.Put(archiveTestAlert("1", "2015-08-15T20:45:00-05:00", "Flood Warning", "Moderate", "005067"))
+
+	reopened, err := NewFileArchive(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, _ := reopened.Query(ArchiveQuery{})
+
+	assertEqual(t, len(found), 1, "The archived alert should be found after reopening")
+}
+
+func TestFileArchiveSkipsCorruptFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cap-archive")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	archive, _ := NewFileArchive(dir)
+	archive.Put(archiveTestAlert("1", "2015-08-15T20:45:00-05:00", "Flood Warning", "Moderate", "005067"))
+	ioutil.WriteFile(filepath.Join(dir, "corrupt.xml"), []byte("<alert"), 0644)
+
+	reopened, err := NewFileArchive(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, _ := reopened.Query(ArchiveQuery{})
+
+	assertEqual(t, len(found), 1, "The readable alert should still be archived")
+	assertEqual(t, len(reopened.Skipped()), 1, "The corrupt file should be reported")
+	assertStartsWith(t, reopened.Skipped()[0].Error(), `Skipped archived alert "`+filepath.Join(dir, "corrupt.xml")+`"`, "The corrupt file should be named")
+}
+
+func TestFileArchiveSkipsOversizedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cap-archive")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	huge := append([]byte("<alert>"), bytes.Repeat([]byte(" "), int(DefaultLimits.MaxDocumentSize))...)
+	ioutil.WriteFile(filepath.Join(dir, "huge.xml"), huge, 0644)
+
+	archive, err := NewFileArchive(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(archive.Skipped()), 1, "The oversized file should be reported")
+
+	if !strings.Contains(archive.Skipped()[0].Error(), "exceeds MaxDocumentSize") {
+		t.Errorf("The limit should be named: %s", archive.Skipped()[0])
+	}
+}
+
+func TestSQLArchiveCloseLeavesDatabaseOpen(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	archive, err := NewSQLArchive(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive.Close()
+
+	assertEqual(t, db.Ping(), nil, "The caller's database should stay open")
+}
@@ -3,6 +3,7 @@ package cap
 import (
 	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -114,26 +115,35 @@ func (l *Link) FollowAlert() (*Alert11, error) {
 		return nil, err
 	}
 
-	var alert Alert11
-	err = xml.Unmarshal(body, &alert)
+	return ParseAlert11(body)
+}
+
+// GetNWSAtomFeed retrieves the main National Weather Service CAP v1.1 ATOM feed
+func GetNWSAtomFeed() (*NWSAtomFeed, error) {
+	return GetAtomFeed(NwsNationalAtomFeedURL)
+}
+
+// GetAtomFeed retrieves a CAP v1.1 ATOM feed in the NWS format from url
+func GetAtomFeed(url string) (*NWSAtomFeed, error) {
+	body, err := handleHTTPResponse(http.Get(url))
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &alert, nil
+	return ParseNWSAtomFeed(body)
 }
 
-// GetNWSAtomFeed retrieves the main National Weather Service CAP v1.1 ATOM feed
-func GetNWSAtomFeed() (*NWSAtomFeed, error) {
-	body, err := handleHTTPResponse(http.Get(NwsNationalAtomFeedURL))
-
-	if err != nil {
+// ParseNWSAtomFeed parses XML bytes into an NWSAtomFeed
+//
+// Documents exceeding DefaultLimits or declaring a DOCTYPE are rejected.
+func ParseNWSAtomFeed(xmlData []byte) (*NWSAtomFeed, error) {
+	if err := DefaultLimits.Check(xmlData); err != nil {
 		return nil, err
 	}
 
 	var downloadedFeed NWSAtomFeed
-	err = xml.Unmarshal(body, &downloadedFeed)
+	err := xml.Unmarshal(xmlData, &downloadedFeed)
 
 	if err != nil {
 		return nil, err
@@ -159,11 +169,16 @@ func handleHTTPResponse(response *http.Response, err error) ([]byte, error) {
 		return nil, fmt.Errorf("Feed exceeds maximum size of %d bytes", MaxFeedSize)
 	}
 
-	body, err := ioutil.ReadAll(response.Body)
+	// The Content-Length header may be missing, so the body itself is limited too
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, MaxFeedSize+1))
 
 	if err != nil {
 		return nil, err
 	}
 
+	if int64(len(body)) > MaxFeedSize {
+		return nil, fmt.Errorf("Feed exceeds maximum size of %d bytes", MaxFeedSize)
+	}
+
 	return body, nil
 }
@@ -2,9 +2,20 @@ package cap
 
 import (
 	"encoding/xml"
+	"fmt"
+	"strings"
 	"time"
 )
 
+// CAP12Namespace is the XML namespace of CAP 1.2 alerts
+const CAP12Namespace string = "urn:oasis:names:tc:emergency:cap:1.2"
+
+// CAP11Namespace is the XML namespace of CAP 1.1 alerts
+const CAP11Namespace string = "urn:oasis:names:tc:emergency:cap:1.1"
+
+// XMLDSigNamespace is the namespace of the XML digital signatures CAP 1.2 allows at the end of an alert
+const XMLDSigNamespace string = "http://www.w3.org/2000/09/xmldsig#"
+
 // Alert provides basic information about the current message: its purpose, its source and its status
 type Alert struct {
 	XMLName xml.Name `xml:"urn:oasis:names:tc:emergency:cap:1.2 alert"`
@@ -18,11 +29,25 @@ type Alert struct {
 	Scope         string   `xml:"scope"`
 	Restriction   string   `xml:"restriction,omitempty"`
 	Addresses     string   `xml:"addresses,omitempty"`
-	HandlingCode  string   `xml:"code,omitempty"`
-	Note          string   `xml:"note,omitempty"`
-	ReferenceIDs  []string `xml:"references,omitempty"`
-	IncidentIDs   []string `xml:"incidents,omitempty"`
-	Infos         []Info   `xml:"info,omitempty"`
+	HandlingCodes []string `xml:"code,omitempty"`
+
+	// Deprecated: HandlingCode holds the first code element when an alert is
+	// parsed; use HandlingCodes, which holds all of them. It is only used as
+	// the alert's code when HandlingCodes is empty.
+	HandlingCode string `xml:"-"`
+
+	Note         string   `xml:"note,omitempty"`
+	ReferenceIDs []string `xml:"references,omitempty"`
+	IncidentIDs  []string `xml:"incidents,omitempty"`
+	Infos        []Info   `xml:"info,omitempty"`
+
+	// Extra and ExtraAttrs hold the child elements and attributes that are not
+	// modelled, such as signatures and extensions, written back after the
+	// known elements. Elements outside the CAP namespaces are always kept here,
+	// even when their local name matches a CAP element. The JSON form holds
+	// them in the extension and extensionAttr properties.
+	Extra      []RawElement `xml:",any"`
+	ExtraAttrs ExtraAttrs   `xml:",any,attr"`
 }
 
 // Alert11 is the same as Alert but using the CAP 1.1 namespace
@@ -56,6 +81,9 @@ type Info struct {
 	Parameters       []NamedValue `xml:"parameter,omitempty"`
 	Areas            []Area       `xml:"area,omitempty"`
 	Resources        []Resource   `xml:"resource,omitempty"`
+
+	Extra      []RawElement `xml:",any"`
+	ExtraAttrs ExtraAttrs   `xml:",any,attr"`
 }
 
 // Resource provides an optional reference to additional information related to Info
@@ -68,6 +96,9 @@ type Resource struct {
 	URI              string `xml:"uri,omitempty"`
 	DeereferencedURI string `xml:"derefUri,omitempty"`
 	Digest           string `xml:"digest,omitempty"`
+
+	Extra      []RawElement `xml:",any"`
+	ExtraAttrs ExtraAttrs   `xml:",any,attr"`
 }
 
 // Area describes a geographic area to which the Info segment applies
@@ -75,11 +106,24 @@ type Area struct {
 	XMLName xml.Name `xml:"area"`
 
 	Description string       `xml:"areaDesc"`
-	Polygon     string       `xml:"polygon,omitempty"`
-	Circle      string       `xml:"circle,omitempty"`
+	Polygons    []string     `xml:"polygon,omitempty"`
+	Circles     []string     `xml:"circle,omitempty"`
 	Geocodes    []NamedValue `xml:"geocode,omitempty"`
 	Altitude    string       `xml:"altitude,omitempty"`
 	Ceiling     string       `xml:"ceiling,omitempty"`
+
+	// Deprecated: Polygon and Circle hold the first polygon and circle
+	// elements when an area is parsed; use Polygons and Circles, which hold
+	// all of them. They are only used when Polygons or Circles is empty.
+	Polygon string `xml:"-"`
+	Circle  string `xml:"-"`
+
+	Extra      []RawElement `xml:",any"`
+	ExtraAttrs ExtraAttrs   `xml:",any,attr"`
+
+	// ResolvedGeometry holds the boundaries of the area's geocodes filled in by
+	// a GeometryResolver. It is not part of the XML or JSON forms.
+	ResolvedGeometry MultiPolygon `xml:"-"`
 }
 
 // NamedValue contains a name and a value associated with that name
@@ -89,7 +133,13 @@ type NamedValue struct {
 }
 
 // ParseAlert parses XML bytes into a CAP 1.2 Alert
+//
+// Documents exceeding DefaultLimits or declaring a DOCTYPE are rejected.
 func ParseAlert(xmlData []byte) (*Alert, error) {
+	if err := DefaultLimits.Check(xmlData); err != nil {
+		return nil, err
+	}
+
 	var alert Alert
 
 	err := xml.Unmarshal(xmlData, &alert)
@@ -102,7 +152,13 @@ func ParseAlert(xmlData []byte) (*Alert, error) {
 }
 
 // ParseAlert parses XML bytes into a CAP 1.1 Alert
+//
+// Documents exceeding DefaultLimits or declaring a DOCTYPE are rejected.
 func ParseAlert11(xmlData []byte) (*Alert11, error) {
+	if err := DefaultLimits.Check(xmlData); err != nil {
+		return nil, err
+	}
+
 	var alert Alert11
 
 	err := xml.Unmarshal(xmlData, &alert)
@@ -114,6 +170,80 @@ func ParseAlert11(xmlData []byte) (*Alert11, error) {
 	return &alert, nil
 }
 
+// decodeAlertElement decodes the alert element that start opens from d
+//
+// CAP 1.2 and CAP 1.1 alerts are supported, decoded the same way as ParseAlert
+// and ParseAlert11.
+func decodeAlertElement(d *xml.Decoder, start *xml.StartElement) (*Alert, error) {
+	switch start.Name.Space {
+	case CAP12Namespace:
+		var alert Alert
+
+		if err := d.DecodeElement(&alert, start); err != nil {
+			return nil, err
+		}
+
+		return &alert, nil
+	case CAP11Namespace:
+		var alert Alert11
+
+		if err := d.DecodeElement(&alert, start); err != nil {
+			return nil, err
+		}
+
+		return &alert.Alert, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported CAP namespace: %q", start.Name.Space)
+}
+
+// HasHandlingCode returns back true if one of the alert's code elements is code
+func (alert *Alert) HasHandlingCode(code string) bool {
+	for _, c := range alert.handlingCodes() {
+		if strings.TrimSpace(c) == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handlingCodes returns back the alert's code elements, or the deprecated
+// HandlingCode if there are none
+func (alert *Alert) handlingCodes() []string {
+	return orSingle(alert.HandlingCodes, alert.HandlingCode)
+}
+
+// polygons returns back the area's polygon elements, or the deprecated
+// Polygon if there are none
+func (area *Area) polygons() []string {
+	return orSingle(area.Polygons, area.Polygon)
+}
+
+// circles returns back the area's circle elements, or the deprecated Circle
+// if there are none
+func (area *Area) circles() []string {
+	return orSingle(area.Circles, area.Circle)
+}
+
+// orSingle returns back values, or single on its own if values is empty
+func orSingle(values []string, single string) []string {
+	if len(values) == 0 && single != "" {
+		return []string{single}
+	}
+
+	return values
+}
+
+// firstOf returns back the first of values, or an empty string if there are none
+func firstOf(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
 // search checks a slice of NamedValues for the first value with a specific name
 func search(nva *[]NamedValue, name string) string {
 	for _, element := range *nva {
@@ -172,3 +302,37 @@ const CAPDate string = "2006-01-02T15:04:05-07:00"
 func ParseCAPDate(dtValue string) (time.Time, error) {
 	return time.Parse(CAPDate, dtValue)
 }
+
+// Reference identifies an earlier message by its sender, identifier and sent date
+type Reference struct {
+	SenderID  string
+	MessageID string
+	SentDate  string
+}
+
+// ParseReferences parses a whitespace separated list of sender,identifier,sent references
+func ParseReferences(value string) ([]Reference, error) {
+	var refs []Reference
+
+	for _, field := range strings.Fields(value) {
+		parts := strings.Split(field, ",")
+
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("Invalid reference: %q", field)
+		}
+
+		refs = append(refs, Reference{SenderID: parts[0], MessageID: parts[1], SentDate: parts[2]})
+	}
+
+	return refs, nil
+}
+
+// String returns back the reference in sender,identifier,sent form
+func (r Reference) String() string {
+	return r.SenderID + "," + r.MessageID + "," + r.SentDate
+}
+
+// References returns back the earlier messages listed in the references element
+func (alert *Alert) References() ([]Reference, error) {
+	return ParseReferences(strings.Join(alert.ReferenceIDs, " "))
+}
@@ -198,7 +198,12 @@ func TestUnmarshalAlertInfoAreaHasProperValues(t *testing.T) {
 		"Description does not match!")
 
 	assertEqual(t,
-		area.Polygon,
+		len(area.Polygons),
+		1,
+		"Area does not have the proper number of Polygon elements")
+
+	assertEqual(t,
+		area.Polygons[0],
 		"35.1,-91.33 35.22,-91.28 35.39,-91.23 35.38,-91.13 35.21,-91.17 35.08,-91.22 35.1,-91.33",
 		"Polygon does not match!")
 
@@ -317,3 +322,19 @@ func TestParseAlert11ReturnsErrForInvalidXml(t *testing.T) {
 	_, err := ParseAlert11([]byte("invalid xml"))
 	assertEqual(t, "EOF", err.Error(), "Unexpected or missing error message")
 }
+
+func TestAlertReferencesReturnsParsedValues(t *testing.T) {
+	alert := Alert{ReferenceIDs: []string{"a@example.com,1,2015-08-15T20:45:00-05:00 b@example.com,2,2015-08-15T21:45:00-05:00"}}
+
+	refs, err := alert.References()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(refs), 2, "Two references should be found")
+	assertEqual(t, refs[1].SenderID, "b@example.com", "SenderID does not match!")
+	assertEqual(t, refs[1].MessageID, "2", "MessageID does not match!")
+	assertEqual(t, refs[1].SentDate, "2015-08-15T21:45:00-05:00", "SentDate does not match!")
+	assertEqual(t, refs[0].String(), "a@example.com,1,2015-08-15T20:45:00-05:00", "String does not match!")
+}
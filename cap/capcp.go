@@ -0,0 +1,192 @@
+package cap
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// The CAP-CP reference tables, embedded so lookups work offline
+var (
+	//go:embed capcp_events.csv
+	capcpEventsCSV []byte
+
+	//go:embed sgc_provinces.csv
+	sgcProvincesCSV []byte
+
+	//go:embed sgc_divisions.csv
+	sgcDivisionsCSV []byte
+)
+
+// CAPCPEvent is an entry of the CAP-CP Event References List
+type CAPCPEvent struct {
+	Code    string
+	English string
+	French  string
+}
+
+// Label returns back the French label for French language tags and the English label otherwise
+func (e CAPCPEvent) Label(lang language.Tag) string {
+	if base, _ := lang.Base(); base.String() == "fr" {
+		return e.French
+	}
+
+	return e.English
+}
+
+// SGCLocation is a Statistics Canada Standard Geographical Classification code
+// resolved to its province and census division
+//
+// SGC codes are two digits for a province or territory, four for a census
+// division and seven for a census subdivision. DivisionCode and Division are
+// empty for province codes, and Division is empty when the census division is
+// not in the embedded table.
+type SGCLocation struct {
+	Code                 string
+	ProvinceCode         string
+	ProvinceAbbreviation string
+	Province             string
+	ProvinceFrench       string
+	DivisionCode         string
+	Division             string
+}
+
+// ProvinceLabel returns back the province name in French for French language tags and English otherwise
+func (l SGCLocation) ProvinceLabel(lang language.Tag) string {
+	if base, _ := lang.Base(); base.String() == "fr" {
+		return l.ProvinceFrench
+	}
+
+	return l.Province
+}
+
+type sgcProvince struct {
+	abbreviation string
+	english      string
+	french       string
+}
+
+var (
+	capcpOnce      sync.Once
+	capcpEvents    map[string]CAPCPEvent
+	sgcProvinces   map[string]sgcProvince
+	sgcDivisions   map[string]string
+	capcpEventList []CAPCPEvent
+)
+
+// loadCAPCPTables parses the embedded reference tables the first time they are needed
+func loadCAPCPTables() {
+	capcpOnce.Do(func() {
+		capcpEvents = make(map[string]CAPCPEvent)
+		sgcProvinces = make(map[string]sgcProvince)
+		sgcDivisions = make(map[string]string)
+
+		for _, row := range readEmbeddedCSV(capcpEventsCSV) {
+			event := CAPCPEvent{Code: row[0], English: row[1], French: row[2]}
+			capcpEvents[event.Code] = event
+			capcpEventList = append(capcpEventList, event)
+		}
+
+		for _, row := range readEmbeddedCSV(sgcProvincesCSV) {
+			sgcProvinces[row[0]] = sgcProvince{abbreviation: row[1], english: row[2], french: row[3]}
+		}
+
+		for _, row := range readEmbeddedCSV(sgcDivisionsCSV) {
+			sgcDivisions[row[0]] = row[1]
+		}
+
+		sort.Slice(capcpEventList, func(i, j int) bool { return capcpEventList[i].Code < capcpEventList[j].Code })
+	})
+}
+
+// readEmbeddedCSV returns back the rows of an embedded table without its header
+func readEmbeddedCSV(data []byte) [][]string {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+
+	if err != nil {
+		panic(fmt.Sprintf("cap: invalid embedded table: %s", err))
+	}
+
+	return rows[1:]
+}
+
+// LookupCAPCPEvent returns back the CAP-CP event with the given code
+//
+// The CAP-CP profile accepts only the event codes found in the embedded list.
+func LookupCAPCPEvent(code string) (CAPCPEvent, bool) {
+	loadCAPCPTables()
+
+	event, ok := capcpEvents[strings.TrimSpace(code)]
+
+	return event, ok
+}
+
+// CAPCPEvents returns back every event of the CAP-CP Event References List, sorted by code
+func CAPCPEvents() []CAPCPEvent {
+	loadCAPCPTables()
+
+	return append([]CAPCPEvent(nil), capcpEventList...)
+}
+
+// LookupSGC resolves a two, four or seven digit SGC code
+func LookupSGC(code string) (SGCLocation, error) {
+	loadCAPCPTables()
+
+	code = strings.TrimSpace(code)
+
+	if !capcpLocationPattern.MatchString(code) {
+		return SGCLocation{}, fmt.Errorf("Invalid SGC code: %q", code)
+	}
+
+	province, ok := sgcProvinces[code[:2]]
+
+	if !ok {
+		return SGCLocation{}, fmt.Errorf("Unknown SGC province code: %q", code[:2])
+	}
+
+	location := SGCLocation{
+		Code:                 code,
+		ProvinceCode:         code[:2],
+		ProvinceAbbreviation: province.abbreviation,
+		Province:             province.english,
+		ProvinceFrench:       province.french,
+	}
+
+	if len(code) > 2 {
+		location.DivisionCode = code[:4]
+		location.Division = sgcDivisions[location.DivisionCode]
+	}
+
+	return location, nil
+}
+
+// CAPCPEvent returns back the Info's CAP-CP event, if it has a known profile:CAP-CP:Event:0.4 event code
+func (info *Info) CAPCPEvent() (CAPCPEvent, bool) {
+	for _, code := range info.EventCode {
+		if code.ValueName == CAPCPEventValueName {
+			return LookupCAPCPEvent(code.Value)
+		}
+	}
+
+	return CAPCPEvent{}, false
+}
+
+// SGCLocations returns back the resolved profile:CAP-CP:Location:0.3 geocodes
+// of the area, skipping codes that cannot be resolved
+func (area *Area) SGCLocations() []SGCLocation {
+	var locations []SGCLocation
+
+	for _, code := range area.GeocodeAll(CAPCPLocationValueName) {
+		if location, err := LookupSGC(code); err == nil {
+			locations = append(locations, location)
+		}
+	}
+
+	return locations
+}
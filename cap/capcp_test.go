@@ -0,0 +1,99 @@
+package cap
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestLookupCAPCPEvent(t *testing.T) {
+	event, ok := LookupCAPCPEvent("tornado")
+
+	assertEqual(t, ok, true, "tornado should be a CAP-CP event")
+	assertEqual(t, event.Label(language.CanadianFrench), "Tornade", "French label does not match!")
+	assertEqual(t, event.Label(language.English), "Tornado", "English label does not match!")
+
+	_, ok = LookupCAPCPEvent("sharknado")
+	assertEqual(t, ok, false, "Unknown events should not be found")
+}
+
+func TestCAPCPEventsAreSorted(t *testing.T) {
+	events := CAPCPEvents()
+
+	for i := 1; i < len(events); i++ {
+		if events[i-1].Code >= events[i].Code {
+			t.Fatalf("Events are not sorted: %q before %q", events[i-1].Code, events[i].Code)
+		}
+	}
+}
+
+func TestLookupSGCResolvesSubdivisions(t *testing.T) {
+	location, err := LookupSGC("3506008")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, location.ProvinceCode, "35", "Province code does not match!")
+	assertEqual(t, location.ProvinceAbbreviation, "ON", "Province abbreviation does not match!")
+	assertEqual(t, location.DivisionCode, "3506", "Division code does not match!")
+	assertEqual(t, location.Division, "Ottawa", "Division does not match!")
+
+	location, err = LookupSGC("24")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, location.ProvinceLabel(language.CanadianFrench), "Québec", "French province does not match!")
+	assertEqual(t, location.DivisionCode, "", "Province codes have no division")
+}
+
+func TestSGCDivisionsAreComplete(t *testing.T) {
+	loadCAPCPTables()
+
+	// The census divisions of each province and territory in SGC 2021
+	expected := map[string]int{
+		"10": 11, "11": 3, "12": 18, "13": 15, "24": 98, "35": 49, "46": 23,
+		"47": 18, "48": 19, "59": 29, "60": 1, "61": 6, "62": 3,
+	}
+
+	counts := make(map[string]int)
+
+	for code := range sgcDivisions {
+		counts[code[:2]]++
+	}
+
+	for province, count := range expected {
+		assertEqual(t, counts[province], count, "Unexpected number of divisions in province "+province)
+	}
+
+	assertEqual(t, len(sgcDivisions), 293, "Every census division should be listed")
+
+	location, _ := LookupSGC("2494068")
+
+	assertEqual(t, location.Division, "Le Saguenay-et-son-Fjord", "Division does not match!")
+}
+
+func TestLookupSGCRejectsInvalidCodes(t *testing.T) {
+	for _, code := range []string{"", "3", "350", "35060", "99", "ON"} {
+		if _, err := LookupSGC(code); err == nil {
+			t.Errorf("Expected an error for %q", code)
+		}
+	}
+}
+
+func TestInfoCAPCPLookups(t *testing.T) {
+	info := getCAPCPAlert().Infos[0]
+	info.Areas[0].AddGeocode(CAPCPLocationValueName, "bogus")
+
+	event, ok := info.CAPCPEvent()
+
+	assertEqual(t, ok, true, "Info should have a CAP-CP event")
+	assertEqual(t, event.English, "Tornado", "Event does not match!")
+
+	locations := info.Areas[0].SGCLocations()
+
+	assertEqual(t, len(locations), 1, "Invalid codes should be skipped")
+	assertEqual(t, locations[0].Province, "Ontario", "Province does not match!")
+}
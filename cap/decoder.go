@@ -0,0 +1,143 @@
+package cap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// DecodeError is returned by Decoder when the stream cannot be decoded
+type DecodeError struct {
+	// AlertOffset is the byte offset where the alert being decoded starts, or -1
+	// if the error happened outside of an alert
+	AlertOffset int64
+
+	// Offset is the byte offset the decoder reached when the error happened
+	Offset int64
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if e.AlertOffset < 0 {
+		return fmt.Sprintf("Error decoding stream at byte %d: %s", e.Offset, e.Err)
+	}
+
+	return fmt.Sprintf("Error decoding alert starting at byte %d (at byte %d): %s", e.AlertOffset, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads CAP alerts one at a time from a stream
+//
+// Alerts may be concatenated or wrapped in any container elements, such as an
+// Atom feed, an EDXL-DE envelope or an export's own root element. Only the
+// alert being decoded is held in memory; everything around the alerts is
+// skipped token by token.
+//
+// DefaultLimits are applied as the stream is read, as they are by ParseAlert:
+// depth, attributes, token lengths and DOCTYPE declarations everywhere, and
+// the element counts and MaxDocumentSize to each alert on its own, so
+// streams of any length can be decoded.
+type Decoder struct {
+	d      *xml.Decoder
+	raw    *xml.Decoder
+	offset int64
+}
+
+// NewDecoder returns back a Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	raw := xml.NewDecoder(r)
+	limited := &limitedTokenReader{d: raw, checker: newLimitChecker(DefaultLimits)}
+
+	return &Decoder{d: xml.NewTokenDecoder(limited), raw: raw, offset: -1}
+}
+
+// Decode returns back the next CAP 1.2 or 1.1 alert in the stream, or io.EOF
+// when there are no more
+//
+// Errors are returned as a *DecodeError. Malformed XML cannot be recovered
+// from, so the Decoder should not be used after a syntax error.
+func (dec *Decoder) Decode() (*Alert, error) {
+	for {
+		start := dec.raw.InputOffset()
+		tok, err := dec.d.Token()
+
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+
+		if err != nil {
+			return nil, &DecodeError{AlertOffset: -1, Offset: dec.raw.InputOffset(), Err: err}
+		}
+
+		t, ok := tok.(xml.StartElement)
+
+		if !ok || t.Name.Local != "alert" || (t.Name.Space != CAP12Namespace && t.Name.Space != CAP11Namespace) {
+			continue
+		}
+
+		dec.offset = start
+		alert, err := decodeAlertElement(dec.d, &t)
+
+		if err != nil {
+			return nil, &DecodeError{AlertOffset: start, Offset: dec.raw.InputOffset(), Err: err}
+		}
+
+		return alert, nil
+	}
+}
+
+// Offset returns back the byte offset where the alert last returned by Decode
+// starts, or -1 before the first alert
+func (dec *Decoder) Offset() int64 {
+	return dec.offset
+}
+
+// limitedTokenReader passes on the raw tokens of d, returning back an error
+// once they exceed the limits of checker
+//
+// Element counts and the document size start afresh at every alert element.
+type limitedTokenReader struct {
+	d          *xml.Decoder
+	checker    *limitChecker
+	alertDepth int
+	alertStart int64
+}
+
+func (r *limitedTokenReader) Token() (xml.Token, error) {
+	offset := r.d.InputOffset()
+	tok, err := r.d.RawToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	start, isStart := tok.(xml.StartElement)
+
+	if isStart && r.alertDepth == 0 && start.Name.Local == "alert" {
+		r.checker.resetCounts()
+		r.alertDepth = r.checker.depth + 1
+		r.alertStart = offset
+	}
+
+	if err := r.checker.check(tok, offset); err != nil {
+		return nil, err
+	}
+
+	if r.alertDepth > 0 {
+		max := r.checker.limits.MaxDocumentSize
+
+		if max > 0 && r.d.InputOffset()-r.alertStart > max {
+			return nil, &LimitError{Limit: "MaxDocumentSize", Max: max, Offset: r.alertStart + max}
+		}
+
+		if r.checker.depth < r.alertDepth {
+			r.alertDepth = 0
+		}
+	}
+
+	return tok, nil
+}
@@ -0,0 +1,114 @@
+package cap
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const decoderStream = `<?xml version="1.0" encoding="UTF-8"?>
+<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"><identifier>1</identifier></alert>
+<?xml version="1.0" encoding="UTF-8"?>
+<export>
+	<alert xmlns="urn:example:not-cap"><identifier>skipped</identifier></alert>
+	<batch><alert xmlns="urn:oasis:names:tc:emergency:cap:1.1"><identifier>2</identifier></alert></batch>
+</export>
+`
+
+func TestDecoderYieldsEveryAlert(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(decoderStream))
+
+	assertEqual(t, dec.Offset(), int64(-1), "Offset should be -1 before the first alert")
+
+	var ids []string
+	var offsets []int64
+
+	for {
+		alert, err := dec.Decode()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, alert.MessageID)
+		offsets = append(offsets, dec.Offset())
+	}
+
+	assertEqual(t, strings.Join(ids, " "), "1 2", "Decoded alerts do not match!")
+	assertEqual(t, offsets[0], int64(strings.Index(decoderStream, "<alert")), "First offset does not match!")
+	assertEqual(t, offsets[1], int64(strings.Index(decoderStream, `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.1"`)), "Second offset does not match!")
+}
+
+func TestDecoderReportsOffsetsOfErrors(t *testing.T) {
+	stream := `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"><identifier>1</identifier></alert>
+<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"><identifier>2</identifier></wrong>`
+
+	dec := NewDecoder(strings.NewReader(stream))
+
+	if _, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := dec.Decode()
+
+	var decodeErr *DecodeError
+
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Expected a *DecodeError but got %v", err)
+	}
+
+	assertEqual(t, decodeErr.AlertOffset, int64(strings.LastIndex(stream, "<alert")), "Alert offset does not match!")
+	assertStartsWith(t, err.Error(), "Error decoding alert starting at byte 87", "Error message does not match!")
+}
+
+func TestDecoderAppliesLimits(t *testing.T) {
+	_, err := NewDecoder(strings.NewReader(`<!DOCTYPE alert [<!ENTITY x "x">]><alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"/>`)).Decode()
+
+	if !errors.Is(err, ErrDTDNotAllowed) {
+		t.Errorf("Expected ErrDTDNotAllowed but got %v", err)
+	}
+
+	deep := strings.Repeat("<a>", DefaultLimits.MaxDepth+1)
+	_, err = NewDecoder(strings.NewReader(deep)).Decode()
+
+	var limitErr *LimitError
+
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxDepth" {
+		t.Errorf("Expected a MaxDepth LimitError but got %v", err)
+	}
+
+	long := `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"><note>` + strings.Repeat("x", DefaultLimits.MaxTokenLength+1) + `</note></alert>`
+	_, err = NewDecoder(strings.NewReader(long)).Decode()
+
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxTokenLength" {
+		t.Errorf("Expected a MaxTokenLength LimitError but got %v", err)
+	}
+}
+
+func TestDecoderCountsElementsPerAlert(t *testing.T) {
+	alert := func(infos int) string {
+		return `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">` + strings.Repeat("<info/>", infos) + `</alert>`
+	}
+
+	// Together the alerts hold more Info blocks than one alert may
+	dec := NewDecoder(strings.NewReader("<export>" + alert(DefaultLimits.MaxInfos) + alert(DefaultLimits.MaxInfos) + "</export>"))
+
+	for i := 0; i < 2; i++ {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := NewDecoder(strings.NewReader(alert(DefaultLimits.MaxInfos + 1))).Decode()
+
+	var limitErr *LimitError
+
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxInfos" {
+		t.Errorf("Expected a MaxInfos LimitError but got %v", err)
+	}
+}
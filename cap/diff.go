@@ -0,0 +1,304 @@
+package cap
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind describes how an element differs between two versions of an alert
+type ChangeKind string
+
+// The kinds of change reported by Diff
+const (
+	ChangeModified ChangeKind = "modified"
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeGrown    ChangeKind = "grown"
+	ChangeShrunk   ChangeKind = "shrunk"
+)
+
+// Change is a single difference between two versions of an alert
+//
+// Path locates the element the same way as ValidationError, using the index
+// of the new version for elements present in both. Old and New hold the
+// values of modified elements and the description of added and removed ones.
+// Geometry changes of an area are reported as grown or shrunk, with Old and
+// New holding its size in square degrees.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	Old  string
+	New  string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, c.Old)
+	case ChangeGrown, ChangeShrunk:
+		return fmt.Sprintf("~ %s: %s from %s to %s square degrees", c.Path, c.Kind, c.Old, c.New)
+	default:
+		return fmt.Sprintf("~ %s: %q -> %q", c.Path, c.Old, c.New)
+	}
+}
+
+// Changes is the list of differences returned by Diff
+type Changes []Change
+
+// String renders the changes one per line
+func (c Changes) String() string {
+	lines := make([]string, len(c))
+
+	for i, change := range c {
+		lines[i] = change.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Diff returns back what changed between two versions of an alert, such as an
+// Alert and the Update that supersedes it
+//
+// Info blocks are matched by language and event, and areas by description,
+// falling back to their order when several share the same key. Event codes,
+// parameters and geocodes are compared as sets of name and value pairs.
+func Diff(old, new *Alert) Changes {
+	var changes Changes
+
+	o, n := *old, *new
+	o.HandlingCodes, n.HandlingCodes = old.handlingCodes(), new.handlingCodes()
+	diffFields(&changes, "", reflect.ValueOf(&o).Elem(), reflect.ValueOf(&n).Elem())
+
+	oldInfos, newInfos := make([]interface{}, len(old.Infos)), make([]interface{}, len(new.Infos))
+
+	for i := range old.Infos {
+		oldInfos[i] = &old.Infos[i]
+	}
+
+	for i := range new.Infos {
+		newInfos[i] = &new.Infos[i]
+	}
+
+	matchElements(oldInfos, newInfos, infoKey, func(o, n interface{}, i int) {
+		path := fmt.Sprintf("info[%d]", i)
+
+		switch {
+		case o == nil:
+			changes = append(changes, Change{Kind: ChangeAdded, Path: path, New: infoKey(n)})
+		case n == nil:
+			changes = append(changes, Change{Kind: ChangeRemoved, Path: path, Old: infoKey(o)})
+		default:
+			diffInfo(&changes, path, o.(*Info), n.(*Info))
+		}
+	})
+
+	return changes
+}
+
+func diffInfo(changes *Changes, path string, old, new *Info) {
+	diffFields(changes, path+".", reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem())
+	diffNamedValues(changes, path+".eventCode", old.EventCode, new.EventCode)
+	diffNamedValues(changes, path+".parameter", old.Parameters, new.Parameters)
+
+	oldAreas, newAreas := make([]interface{}, len(old.Areas)), make([]interface{}, len(new.Areas))
+
+	for i := range old.Areas {
+		oldAreas[i] = &old.Areas[i]
+	}
+
+	for i := range new.Areas {
+		newAreas[i] = &new.Areas[i]
+	}
+
+	matchElements(oldAreas, newAreas, areaKey, func(o, n interface{}, i int) {
+		areaPath := fmt.Sprintf("%s.area[%d]", path, i)
+
+		switch {
+		case o == nil:
+			*changes = append(*changes, Change{Kind: ChangeAdded, Path: areaPath, New: areaKey(n)})
+		case n == nil:
+			*changes = append(*changes, Change{Kind: ChangeRemoved, Path: areaPath, Old: areaKey(o)})
+		default:
+			diffArea(changes, areaPath, o.(*Area), n.(*Area))
+		}
+	})
+
+	oldResources, newResources := make([]interface{}, len(old.Resources)), make([]interface{}, len(new.Resources))
+
+	for i := range old.Resources {
+		oldResources[i] = &old.Resources[i]
+	}
+
+	for i := range new.Resources {
+		newResources[i] = &new.Resources[i]
+	}
+
+	matchElements(oldResources, newResources, resourceKey, func(o, n interface{}, i int) {
+		resourcePath := fmt.Sprintf("%s.resource[%d]", path, i)
+
+		switch {
+		case o == nil:
+			*changes = append(*changes, Change{Kind: ChangeAdded, Path: resourcePath, New: resourceKey(n)})
+		case n == nil:
+			*changes = append(*changes, Change{Kind: ChangeRemoved, Path: resourcePath, Old: resourceKey(o)})
+		default:
+			diffFields(changes, resourcePath+".", reflect.ValueOf(o).Elem(), reflect.ValueOf(n).Elem())
+		}
+	})
+}
+
+func diffArea(changes *Changes, path string, old, new *Area) {
+	o, n := *old, *new
+	o.Polygons, n.Polygons = old.polygons(), new.polygons()
+	o.Circles, n.Circles = old.circles(), new.circles()
+	diffFields(changes, path+".", reflect.ValueOf(&o).Elem(), reflect.ValueOf(&n).Elem())
+	diffNamedValues(changes, path+".geocode", old.Geocodes, new.Geocodes)
+
+	oldSize, oldOK := geometrySize(old)
+	newSize, newOK := geometrySize(new)
+
+	if !oldOK || !newOK || oldSize == newSize {
+		return
+	}
+
+	kind := ChangeGrown
+
+	if newSize < oldSize {
+		kind = ChangeShrunk
+	}
+
+	*changes = append(*changes, Change{
+		Kind: kind,
+		Path: path,
+		Old:  formatSize(oldSize),
+		New:  formatSize(newSize),
+	})
+}
+
+// diffFields compares the string fields of two structs, using their XML element names in paths
+func diffFields(changes *Changes, prefix string, old, new reflect.Value) {
+	t := old.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("xml"), ",")[0]
+
+		// Fields outside the XML form, such as the deprecated single polygon, are not compared
+		if name == "-" {
+			continue
+		}
+
+		var o, n string
+
+		switch field.Type {
+		case reflect.TypeOf(""):
+			o, n = old.Field(i).String(), new.Field(i).String()
+		case reflect.TypeOf([]string(nil)):
+			o = strings.Join(old.Field(i).Interface().([]string), " ")
+			n = strings.Join(new.Field(i).Interface().([]string), " ")
+		default:
+			continue
+		}
+
+		if o != n {
+			*changes = append(*changes, Change{Kind: ChangeModified, Path: prefix + name, Old: o, New: n})
+		}
+	}
+}
+
+// diffNamedValues reports the name and value pairs only found in one of the lists
+func diffNamedValues(changes *Changes, path string, old, new []NamedValue) {
+	counts := make(map[NamedValue]int)
+
+	for _, v := range old {
+		counts[v]++
+	}
+
+	for _, v := range new {
+		counts[v]--
+	}
+
+	for _, v := range old {
+		if counts[v] > 0 {
+			counts[v]--
+			*changes = append(*changes, Change{Kind: ChangeRemoved, Path: path, Old: v.ValueName + "=" + v.Value})
+		}
+	}
+
+	for _, v := range new {
+		if counts[v] < 0 {
+			counts[v]++
+			*changes = append(*changes, Change{Kind: ChangeAdded, Path: path, New: v.ValueName + "=" + v.Value})
+		}
+	}
+}
+
+// matchElements pairs up elements with the same key in order, calling fn for
+// every pair with the index in new, then for every unmatched old element with
+// its index in old
+func matchElements(old, new []interface{}, key func(interface{}) string, fn func(o, n interface{}, i int)) {
+	used := make([]bool, len(old))
+
+	for i, n := range new {
+		var match interface{}
+
+		for j, o := range old {
+			if !used[j] && key(o) == key(n) {
+				used[j] = true
+				match = o
+				break
+			}
+		}
+
+		fn(match, n, i)
+	}
+
+	for j, o := range old {
+		if !used[j] {
+			fn(o, nil, j)
+		}
+	}
+}
+
+func infoKey(v interface{}) string {
+	info := v.(*Info)
+	return strings.TrimSpace(info.EventType + " (" + info.LanguageTag().String() + ")")
+}
+
+func areaKey(v interface{}) string {
+	return v.(*Area).Description
+}
+
+func resourceKey(v interface{}) string {
+	return v.(*Resource).Description
+}
+
+// geometrySize returns back the size of the area's polygons and circles in square degrees
+func geometrySize(area *Area) (float64, bool) {
+	size, ok := 0.0, false
+
+	for _, polygon := range area.polygons() {
+		if ring, err := parsePolygonRing(polygon); err == nil {
+			size, ok = size+math.Abs(ring.SignedArea()), true
+		}
+	}
+
+	for _, circle := range area.circles() {
+		if center, radius, err := parseCircle(circle); err == nil {
+			// The radius is in kilometers; a degree of latitude is about 111.32 km
+			// and degrees of longitude shrink with the cosine of the latitude
+			r := radius / 111.32
+			size, ok = size+math.Pi*r*r/math.Max(math.Cos(center[1]*math.Pi/180), 1e-6), true
+		}
+	}
+
+	return size, ok
+}
+
+func formatSize(size float64) string {
+	return fmt.Sprintf("%.4g", size)
+}
@@ -0,0 +1,90 @@
+package cap
+
+import (
+	"testing"
+)
+
+func getDiffAlerts() (*Alert, *Alert) {
+	old := &Alert{
+		MessageID:     "1",
+		SenderID:      "w-nws.webmaster@noaa.gov",
+		SentDate:      "2015-08-15T20:45:00-05:00",
+		MessageStatus: "Actual",
+		MessageType:   "Alert",
+		Scope:         "Public",
+		Infos: []Info{
+			{
+				Language:    "en-US",
+				EventType:   "Flood Warning",
+				Severity:    "Moderate",
+				ExpiresDate: "2015-08-16T02:45:00-05:00",
+				Parameters:  []NamedValue{{ValueName: "EAS-ORG", Value: "WXR"}},
+				Areas: []Area{
+					{Description: "Jackson", Polygons: []string{"35,-91 36,-91 36,-90 35,-90 35,-91"}, Geocodes: []NamedValue{{ValueName: "SAME", Value: "005067"}}},
+					{Description: "Woodruff"},
+				},
+			},
+			{Language: "es-US", EventType: "Aviso de Inundación"},
+		},
+	}
+
+	new := &Alert{
+		MessageID:     "2",
+		SenderID:      "w-nws.webmaster@noaa.gov",
+		SentDate:      "2015-08-15T22:45:00-05:00",
+		MessageStatus: "Actual",
+		MessageType:   "Update",
+		Scope:         "Public",
+		ReferenceIDs:  []string{"w-nws.webmaster@noaa.gov,1,2015-08-15T20:45:00-05:00"},
+		Infos: []Info{
+			{Language: "en-US", EventType: "Flash Flood Warning"},
+			{
+				Language:    "en-US",
+				EventType:   "Flood Warning",
+				Severity:    "Severe",
+				ExpiresDate: "2015-08-16T02:45:00-05:00",
+				Parameters:  []NamedValue{{ValueName: "EAS-ORG", Value: "WXR"}, {ValueName: "BLOCKCHANNEL", Value: "NWEM"}},
+				Areas: []Area{
+					{Description: "Jackson", Polygons: []string{"35,-91 37,-91 37,-90 35,-90 35,-91"}, Geocodes: []NamedValue{{ValueName: "SAME", Value: "005067"}}},
+				},
+			},
+		},
+	}
+
+	return old, new
+}
+
+func TestDiffReportsChanges(t *testing.T) {
+	old, new := getDiffAlerts()
+
+	expected := `~ identifier: "1" -> "2"
+~ sent: "2015-08-15T20:45:00-05:00" -> "2015-08-15T22:45:00-05:00"
+~ msgType: "Alert" -> "Update"
+~ references: "" -> "w-nws.webmaster@noaa.gov,1,2015-08-15T20:45:00-05:00"
++ info[0]: Flash Flood Warning (en-US)
+~ info[1].severity: "Moderate" -> "Severe"
++ info[1].parameter: BLOCKCHANNEL=NWEM
+~ info[1].area[0].polygon: "35,-91 36,-91 36,-90 35,-90 35,-91" -> "35,-91 37,-91 37,-90 35,-90 35,-91"
+~ info[1].area[0]: grown from 1 to 2 square degrees
+- info[1].area[1]: Woodruff
+- info[1]: Aviso de Inundación (es-US)`
+
+	assertEqual(t, Diff(old, new).String(), expected, "Rendered diff does not match!")
+}
+
+func TestDiffOfSameAlertIsEmpty(t *testing.T) {
+	old, _ := getDiffAlerts()
+
+	assertEqual(t, len(Diff(old, old)), 0, "An alert should not differ from itself")
+}
+
+func TestDiffReportsShrunkCircles(t *testing.T) {
+	old := &Alert{Infos: []Info{{Areas: []Area{{Description: "Test", Circles: []string{"35,-91 20"}}}}}}
+	new := &Alert{Infos: []Info{{Areas: []Area{{Description: "Test", Circles: []string{"35,-91 10"}}}}}}
+
+	changes := Diff(old, new)
+
+	assertEqual(t, len(changes), 2, "Expected circle and size changes")
+	assertEqual(t, changes[1].Kind, ChangeShrunk, "Circle should have shrunk")
+	assertEqual(t, changes[1].Path, "info[0].area[0]", "Path does not match!")
+}
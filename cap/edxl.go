@@ -0,0 +1,671 @@
+package cap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// EDXLDE10Namespace is the XML namespace of EDXL Distribution Element 1.0 envelopes
+const EDXLDE10Namespace string = "urn:oasis:names:tc:emergency:EDXL:DE:1.0"
+
+// EDXLDE20Namespace is the XML namespace of EDXL Distribution Element 2.0 envelopes
+const EDXLDE20Namespace string = "urn:oasis:names:tc:emergency:EDXL:DE:2.0"
+
+// DefaultConfidentiality is the combined confidentiality of a public distribution
+const DefaultConfidentiality string = "UNCLASSIFIED AND NOT SENSITIVE"
+
+// defaultDistributionLifetime is how long a distribution of alerts without an expiry is kept
+const defaultDistributionLifetime = 24 * time.Hour
+
+// Distribution is an EDXL Distribution Element (EDXL-DE) envelope carrying CAP alerts
+//
+// The same Distribution can be written as EDXL-DE 1.0 or 2.0. Fields that only
+// exist in one version are ignored when writing the other.
+type Distribution struct {
+	DistributionID string
+	SenderID       string
+	SentDate       string
+
+	// ExpiresDate is only used by EDXL-DE 2.0, where it is required
+	ExpiresDate string
+
+	// Status is one of Actual, Exercise, System or Test
+	Status string
+
+	// Type is the distribution type, such as Report, Update or Cancel
+	Type string
+
+	// Confidentiality is only used by EDXL-DE 1.0
+	Confidentiality string
+
+	Language    string
+	Keywords    []ValueList
+	TargetAreas []TargetArea
+	Alerts      []*Alert
+}
+
+// ValueList is a list of values from a managed list identified by a URN
+type ValueList struct {
+	ListURN string
+	Values  []string
+}
+
+// TargetArea describes the area a Distribution is targeted at
+//
+// Circles and polygons use the CAP string forms.
+type TargetArea struct {
+	Circles      []string
+	Polygons     []string
+	Countries    []string
+	Subdivisions []string
+	LocCodeUN    []string
+}
+
+// NewDistribution creates a Distribution from a sender carrying the alerts
+//
+// The distribution identifier, status and type are taken from the first alert,
+// and the target area contains the polygons and circles of every alert. The
+// distribution expires with the last of the alerts' Info blocks, or a day after
+// it is sent if none of them expire.
+func NewDistribution(senderID string, alerts ...*Alert) *Distribution {
+	now := time.Now()
+	expires := now.Add(defaultDistributionLifetime)
+	var latest time.Time
+
+	for _, alert := range alerts {
+		for _, info := range alert.Infos {
+			if t, err := ParseCAPDate(info.ExpiresDate); err == nil && t.After(latest) {
+				latest = t
+			}
+		}
+	}
+
+	if !latest.IsZero() {
+		expires = latest
+	}
+
+	d := Distribution{
+		SenderID:        senderID,
+		SentDate:        now.Format(CAPDate),
+		ExpiresDate:     expires.Format(CAPDate),
+		Status:          "Actual",
+		Type:            "Report",
+		Confidentiality: DefaultConfidentiality,
+		Alerts:          alerts,
+	}
+
+	if len(alerts) == 0 {
+		return &d
+	}
+
+	d.DistributionID = alerts[0].MessageID
+
+	switch alerts[0].MessageStatus {
+	case "Exercise", "System", "Test":
+		d.Status = alerts[0].MessageStatus
+	}
+
+	switch alerts[0].MessageType {
+	case "Update", "Cancel", "Ack", "Error":
+		d.Type = alerts[0].MessageType
+	}
+
+	var area TargetArea
+
+	for _, alert := range alerts {
+		for _, info := range alert.Infos {
+			for _, a := range info.Areas {
+				area.Polygons = append(area.Polygons, a.polygons()...)
+				area.Circles = append(area.Circles, a.circles()...)
+			}
+		}
+	}
+
+	if len(area.Polygons) > 0 || len(area.Circles) > 0 {
+		d.TargetAreas = []TargetArea{area}
+	}
+
+	return &d
+}
+
+type edxl10Distribution struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:emergency:EDXL:DE:1.0 EDXLDistribution"`
+
+	DistributionID          string              `xml:"distributionID"`
+	SenderID                string              `xml:"senderID"`
+	DateTimeSent            string              `xml:"dateTimeSent"`
+	DistributionStatus      string              `xml:"distributionStatus"`
+	DistributionType        string              `xml:"distributionType"`
+	CombinedConfidentiality string              `xml:"combinedConfidentiality"`
+	Language                string              `xml:"language,omitempty"`
+	Keywords                []edxl10ValueList   `xml:"keyword,omitempty"`
+	TargetAreas             []edxl10TargetArea  `xml:"targetArea,omitempty"`
+	ContentObjects          []edxlContentObject `xml:"contentObject,omitempty"`
+}
+
+type edxl10ValueList struct {
+	ListURN string   `xml:"valueListUrn"`
+	Values  []string `xml:"value"`
+}
+
+type edxl10TargetArea struct {
+	Circles      []string `xml:"circle,omitempty"`
+	Polygons     []string `xml:"polygon,omitempty"`
+	Countries    []string `xml:"country,omitempty"`
+	Subdivisions []string `xml:"subdivision,omitempty"`
+	LocCodeUN    []string `xml:"locCodeUN,omitempty"`
+}
+
+type edxlContentObject struct {
+	XMLContent edxlXMLContent `xml:"xmlContent"`
+}
+
+type edxlXMLContent struct {
+	Embedded []edxlEmbeddedContent `xml:"embeddedXMLContent"`
+}
+
+type edxlEmbeddedContent struct {
+	Alert *Alert `xml:"urn:oasis:names:tc:emergency:cap:1.2 alert"`
+}
+
+type edxl20Distribution struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:emergency:EDXL:DE:2.0 EDXLDistribution"`
+
+	DistributionID     string             `xml:"distributionID"`
+	SenderID           string             `xml:"senderID"`
+	DateTimeSent       string             `xml:"dateTimeSent"`
+	DateTimeExpires    string             `xml:"dateTimeExpires"`
+	DistributionStatus edxl20StatusKind   `xml:"distributionStatus"`
+	DistributionKind   edxl20KindDefault  `xml:"distributionKind"`
+	Descriptor         *edxl20Descriptor  `xml:"descriptor,omitempty"`
+	TargetAreas        []edxl20TargetArea `xml:"targetArea,omitempty"`
+	Content            edxl20Content      `xml:"content"`
+}
+
+type edxl20StatusKind struct {
+	Default string `xml:"StatusKindDefault"`
+}
+
+type edxl20KindDefault struct {
+	Default string `xml:"DistributionKindDefault"`
+}
+
+type edxl20Descriptor struct {
+	Language string            `xml:"language,omitempty"`
+	Keywords []edxl20ValueList `xml:"keyword,omitempty"`
+}
+
+type edxl20ValueList struct {
+	ListURI string   `xml:"urn:oasis:names:tc:emergency:edxl:ct:1.0 ValueListURI"`
+	Values  []string `xml:"urn:oasis:names:tc:emergency:edxl:ct:1.0 Value"`
+}
+
+type edxl20TargetArea struct {
+	GeoLocations      []edxl20GeoLocation      `xml:"urn:oasis:names:tc:emergency:edxl:ct:1.0 EDXLGeoLocation,omitempty"`
+	PoliticalLocation *edxl20PoliticalLocation `xml:"urn:oasis:names:tc:emergency:edxl:ct:1.0 EDXLGeoPoliticalLocation,omitempty"`
+}
+
+type edxl20GeoLocation struct {
+	Polygon *gmlPolygon `xml:"http://www.opengis.net/gml/3.2 Polygon,omitempty"`
+	Circle  *gmlCircle  `xml:"http://www.opengis.net/gml/3.2 CircleByCenterPoint,omitempty"`
+}
+
+type gmlPolygon struct {
+	PosList string `xml:"http://www.opengis.net/gml/3.2 exterior>LinearRing>posList"`
+}
+
+type gmlCircle struct {
+	NumArc string    `xml:"numArc,attr"`
+	Pos    string    `xml:"http://www.opengis.net/gml/3.2 pos"`
+	Radius gmlRadius `xml:"http://www.opengis.net/gml/3.2 radius"`
+}
+
+type gmlRadius struct {
+	UOM   string `xml:"uom,attr"`
+	Value string `xml:",chardata"`
+}
+
+type edxl20PoliticalLocation struct {
+	GeoCodes []edxl20GeoCode `xml:"urn:oasis:names:tc:emergency:edxl:ct:1.0 GeoCode"`
+}
+
+type edxl20GeoCode struct {
+	ListURI string `xml:"urn:oasis:names:tc:emergency:edxl:ct:1.0 ValueListURI"`
+	Value   string `xml:"urn:oasis:names:tc:emergency:edxl:ct:1.0 Value"`
+}
+
+type edxl20Content struct {
+	ContentObjects []edxlContentObject `xml:"contentObject"`
+}
+
+// Value list URIs used by EDXL-DE 2.0
+const (
+	edxl20CountryListURI     string = "urn:oasis:names:tc:emergency:edxl:ct:1.0:country"
+	edxl20SubdivisionListURI string = "urn:oasis:names:tc:emergency:edxl:ct:1.0:subdivision"
+	edxl20LocCodeUNListURI   string = "urn:oasis:names:tc:emergency:edxl:ct:1.0:locCodeUN"
+)
+
+// MarshalEDXL10 encodes the Distribution as an EDXL-DE 1.0 envelope
+func (d *Distribution) MarshalEDXL10() ([]byte, error) {
+	v := edxl10Distribution{
+		DistributionID:          d.DistributionID,
+		SenderID:                d.SenderID,
+		DateTimeSent:            d.SentDate,
+		DistributionStatus:      d.Status,
+		DistributionType:        d.Type,
+		CombinedConfidentiality: d.Confidentiality,
+		Language:                d.Language,
+		ContentObjects:          edxlContentObjects(d.Alerts),
+	}
+
+	if v.CombinedConfidentiality == "" {
+		v.CombinedConfidentiality = DefaultConfidentiality
+	}
+
+	for _, keyword := range d.Keywords {
+		v.Keywords = append(v.Keywords, edxl10ValueList{ListURN: keyword.ListURN, Values: keyword.Values})
+	}
+
+	for _, area := range d.TargetAreas {
+		v.TargetAreas = append(v.TargetAreas, edxl10TargetArea(area))
+	}
+
+	return marshalEDXL(v)
+}
+
+// MarshalEDXL20 encodes the Distribution as an EDXL-DE 2.0 envelope
+//
+// EDXL-DE 2.0 requires an expiry, so an error is returned without an ExpiresDate.
+func (d *Distribution) MarshalEDXL20() ([]byte, error) {
+	if d.ExpiresDate == "" {
+		return nil, fmt.Errorf("EDXL-DE 2.0 distributions require an ExpiresDate")
+	}
+
+	v := edxl20Distribution{
+		DistributionID:     d.DistributionID,
+		SenderID:           d.SenderID,
+		DateTimeSent:       d.SentDate,
+		DateTimeExpires:    d.ExpiresDate,
+		DistributionStatus: edxl20StatusKind{d.Status},
+		DistributionKind:   edxl20KindDefault{d.Type},
+		Content:            edxl20Content{edxlContentObjects(d.Alerts)},
+	}
+
+	if d.Language != "" || len(d.Keywords) > 0 {
+		v.Descriptor = &edxl20Descriptor{Language: d.Language}
+
+		for _, keyword := range d.Keywords {
+			v.Descriptor.Keywords = append(v.Descriptor.Keywords, edxl20ValueList{ListURI: keyword.ListURN, Values: keyword.Values})
+		}
+	}
+
+	for _, area := range d.TargetAreas {
+		target, err := newEDXL20TargetArea(area)
+
+		if err != nil {
+			return nil, err
+		}
+
+		v.TargetAreas = append(v.TargetAreas, target)
+	}
+
+	return marshalEDXL(v)
+}
+
+func marshalEDXL(v interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+func edxlContentObjects(alerts []*Alert) []edxlContentObject {
+	objects := make([]edxlContentObject, 0, len(alerts))
+
+	for _, alert := range alerts {
+		objects = append(objects, edxlContentObject{
+			XMLContent: edxlXMLContent{Embedded: []edxlEmbeddedContent{{Alert: alert}}},
+		})
+	}
+
+	return objects
+}
+
+func newEDXL20TargetArea(area TargetArea) (edxl20TargetArea, error) {
+	var target edxl20TargetArea
+
+	for _, polygon := range area.Polygons {
+		posList, err := capToGMLPosList(polygon)
+
+		if err != nil {
+			return target, err
+		}
+
+		target.GeoLocations = append(target.GeoLocations, edxl20GeoLocation{Polygon: &gmlPolygon{PosList: posList}})
+	}
+
+	for _, circle := range area.Circles {
+		fields := strings.Fields(circle)
+
+		if len(fields) != 2 {
+			return target, fmt.Errorf("Invalid circle: %q", circle)
+		}
+
+		pos, err := capToGMLPosList(fields[0])
+
+		if err != nil {
+			return target, err
+		}
+
+		target.GeoLocations = append(target.GeoLocations, edxl20GeoLocation{Circle: &gmlCircle{
+			NumArc: "1",
+			Pos:    pos,
+			Radius: gmlRadius{UOM: "km", Value: fields[1]},
+		}})
+	}
+
+	var codes []edxl20GeoCode
+
+	for _, country := range area.Countries {
+		codes = append(codes, edxl20GeoCode{ListURI: edxl20CountryListURI, Value: country})
+	}
+
+	for _, subdivision := range area.Subdivisions {
+		codes = append(codes, edxl20GeoCode{ListURI: edxl20SubdivisionListURI, Value: subdivision})
+	}
+
+	for _, code := range area.LocCodeUN {
+		codes = append(codes, edxl20GeoCode{ListURI: edxl20LocCodeUNListURI, Value: code})
+	}
+
+	if len(codes) > 0 {
+		target.PoliticalLocation = &edxl20PoliticalLocation{GeoCodes: codes}
+	}
+
+	return target, nil
+}
+
+// capToGMLPosList converts "lat,lon lat,lon" pairs into a GML "lat lon lat lon" position list
+func capToGMLPosList(value string) (string, error) {
+	var coords []string
+
+	for _, pair := range strings.Fields(value) {
+		parts := strings.Split(pair, ",")
+
+		if len(parts) != 2 {
+			return "", fmt.Errorf("Invalid coordinate pair: %q", pair)
+		}
+
+		coords = append(coords, parts[0], parts[1])
+	}
+
+	return strings.Join(coords, " "), nil
+}
+
+// gmlToCAPPosList converts a GML "lat lon lat lon" position list into "lat,lon lat,lon" pairs
+func gmlToCAPPosList(value string) (string, error) {
+	coords := strings.Fields(value)
+
+	if len(coords)%2 != 0 {
+		return "", fmt.Errorf("Invalid GML position list: %q", value)
+	}
+
+	pairs := make([]string, 0, len(coords)/2)
+
+	for i := 0; i < len(coords); i += 2 {
+		pairs = append(pairs, coords[i]+","+coords[i+1])
+	}
+
+	return strings.Join(pairs, " "), nil
+}
+
+// ParseDistribution parses an EDXL-DE 1.0 or 2.0 envelope and the CAP alerts embedded in it
+//
+// Envelopes exceeding DefaultLimits or declaring a DOCTYPE are rejected, and
+// every embedded alert is parsed with ParseAlert or ParseAlert11.
+func ParseDistribution(xmlData []byte) (*Distribution, error) {
+	if err := DefaultLimits.Check(xmlData); err != nil {
+		return nil, err
+	}
+
+	root, err := edxlRootNamespace(xmlData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var d *Distribution
+
+	switch root {
+	case EDXLDE10Namespace:
+		d, err = parseEDXL10(xmlData)
+	case EDXLDE20Namespace:
+		d, err = parseEDXL20(xmlData)
+	default:
+		return nil, fmt.Errorf("Unsupported EDXL-DE namespace: %q", root)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Alerts, err = ExtractAlerts(xmlData); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func edxlRootNamespace(xmlData []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(xmlData))
+
+	for {
+		tok, err := d.Token()
+
+		if err != nil {
+			return "", err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			if start.Name.Local != "EDXLDistribution" {
+				return "", fmt.Errorf("Expected an EDXLDistribution element but found %q", start.Name.Local)
+			}
+
+			return start.Name.Space, nil
+		}
+	}
+}
+
+func parseEDXL10(xmlData []byte) (*Distribution, error) {
+	var v edxl10Distribution
+
+	if err := xml.Unmarshal(xmlData, &v); err != nil {
+		return nil, err
+	}
+
+	d := Distribution{
+		DistributionID:  v.DistributionID,
+		SenderID:        v.SenderID,
+		SentDate:        v.DateTimeSent,
+		Status:          v.DistributionStatus,
+		Type:            v.DistributionType,
+		Confidentiality: v.CombinedConfidentiality,
+		Language:        v.Language,
+	}
+
+	for _, keyword := range v.Keywords {
+		d.Keywords = append(d.Keywords, ValueList{ListURN: keyword.ListURN, Values: keyword.Values})
+	}
+
+	for _, area := range v.TargetAreas {
+		d.TargetAreas = append(d.TargetAreas, TargetArea(area))
+	}
+
+	return &d, nil
+}
+
+func parseEDXL20(xmlData []byte) (*Distribution, error) {
+	var v edxl20Distribution
+
+	if err := xml.Unmarshal(xmlData, &v); err != nil {
+		return nil, err
+	}
+
+	d := Distribution{
+		DistributionID: v.DistributionID,
+		SenderID:       v.SenderID,
+		SentDate:       v.DateTimeSent,
+		ExpiresDate:    v.DateTimeExpires,
+		Status:         strings.TrimSpace(v.DistributionStatus.Default),
+		Type:           strings.TrimSpace(v.DistributionKind.Default),
+	}
+
+	if v.Descriptor != nil {
+		d.Language = v.Descriptor.Language
+
+		for _, keyword := range v.Descriptor.Keywords {
+			d.Keywords = append(d.Keywords, ValueList{ListURN: keyword.ListURI, Values: keyword.Values})
+		}
+	}
+
+	for _, target := range v.TargetAreas {
+		var area TargetArea
+
+		for _, location := range target.GeoLocations {
+			if location.Polygon != nil {
+				polygon, err := gmlToCAPPosList(location.Polygon.PosList)
+
+				if err != nil {
+					return nil, err
+				}
+
+				area.Polygons = append(area.Polygons, polygon)
+			}
+
+			if location.Circle != nil {
+				center, err := gmlToCAPPosList(location.Circle.Pos)
+
+				if err != nil {
+					return nil, err
+				}
+
+				area.Circles = append(area.Circles, center+" "+strings.TrimSpace(location.Circle.Radius.Value))
+			}
+		}
+
+		if target.PoliticalLocation != nil {
+			for _, code := range target.PoliticalLocation.GeoCodes {
+				switch code.ListURI {
+				case edxl20CountryListURI:
+					area.Countries = append(area.Countries, code.Value)
+				case edxl20SubdivisionListURI:
+					area.Subdivisions = append(area.Subdivisions, code.Value)
+				case edxl20LocCodeUNListURI:
+					area.LocCodeUN = append(area.LocCodeUN, code.Value)
+				}
+			}
+		}
+
+		d.TargetAreas = append(d.TargetAreas, area)
+	}
+
+	return &d, nil
+}
+
+// ExtractAlerts returns back the CAP alerts embedded in an EDXL-DE envelope
+//
+// Every alert element found inside an embeddedXMLContent element is decoded,
+// regardless of the EDXL-DE version, in document order. CAP 1.2 and CAP 1.1
+// alerts are supported.
+//
+// Envelopes exceeding DefaultLimits or declaring a DOCTYPE are rejected, and
+// every alert is parsed with ParseAlert or ParseAlert11, so the same checks
+// apply as to a standalone alert.
+func ExtractAlerts(xmlData []byte) ([]*Alert, error) {
+	if err := DefaultLimits.Check(xmlData); err != nil {
+		return nil, err
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(xmlData))
+
+	var (
+		alerts []*Alert
+		depth  int
+	)
+
+	for {
+		tok, err := d.Token()
+
+		if err == io.EOF {
+			return alerts, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth > 0 && t.Name.Local == "alert" {
+				alert, err := parseEmbeddedAlert(d, t)
+
+				if err != nil {
+					return nil, err
+				}
+
+				alerts = append(alerts, alert)
+				continue
+			}
+
+			if t.Name.Local == "embeddedXMLContent" || depth > 0 {
+				depth++
+			}
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+}
+
+// parseEmbeddedAlert parses the alert element that start opens from d with
+// ParseAlert or ParseAlert11
+//
+// The element is written out on its own first, declaring any namespaces it
+// inherited from the envelope.
+func parseEmbeddedAlert(d *xml.Decoder, start xml.StartElement) (*Alert, error) {
+	if start.Name.Space != CAP12Namespace && start.Name.Space != CAP11Namespace {
+		return nil, fmt.Errorf("Unsupported CAP namespace: %q", start.Name.Space)
+	}
+
+	var raw RawElement
+
+	if err := raw.UnmarshalXML(d, start); err != nil {
+		return nil, err
+	}
+
+	xmlData, err := xml.Marshal(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if start.Name.Space == CAP11Namespace {
+		alert, err := ParseAlert11(xmlData)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &alert.Alert, nil
+	}
+
+	return ParseAlert(xmlData)
+}
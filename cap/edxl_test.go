@@ -0,0 +1,278 @@
+package cap
+
+import (
+	"strings"
+	"testing"
+)
+
+func getEDXLDistributionExample() (*Distribution, error) {
+	alert, err := getCAPAlertExample()
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewDistribution("sender@example.com", &alert.Alert)
+	d.Keywords = []ValueList{{ListURN: "urn:example:keywords", Values: []string{"flood", "river"}}}
+	d.TargetAreas[0].Countries = []string{"US"}
+	d.TargetAreas[0].Subdivisions = []string{"US-AR"}
+
+	return d, nil
+}
+
+func TestNewDistributionUsesAlertValues(t *testing.T) {
+	d, err := getEDXLDistributionExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, d.DistributionID, d.Alerts[0].MessageID, "DistributionID does not match!")
+	assertEqual(t, d.Status, "Actual", "Status does not match!")
+	assertEqual(t, d.Type, "Report", "Type does not match!")
+	assertEqual(t, d.ExpiresDate, "2015-08-16T11:45:00-05:00", "The distribution should expire with the alert")
+	assertEqual(t,
+		d.TargetAreas[0].Polygons[0],
+		"35.1,-91.33 35.22,-91.28 35.39,-91.23 35.38,-91.13 35.21,-91.17 35.08,-91.22 35.1,-91.33",
+		"TargetArea polygon does not match!")
+}
+
+func TestDistributionRoundTripsEDXL10(t *testing.T) {
+	d, err := getEDXLDistributionExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := d.MarshalEDXL10()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertStartsWith(t, string(data), xmlHeaderPrefix, "XML header is missing")
+
+	parsed, err := ParseDistribution(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, parsed.SenderID, "sender@example.com", "SenderID does not match!")
+	assertEqual(t, parsed.Confidentiality, DefaultConfidentiality, "Confidentiality does not match!")
+	assertEqual(t, parsed.Keywords[0].Values[1], "river", "Keyword does not match!")
+	assertEqual(t, parsed.TargetAreas[0].Polygons[0], d.TargetAreas[0].Polygons[0], "TargetArea polygon does not match!")
+	assertEqual(t, parsed.TargetAreas[0].Subdivisions[0], "US-AR", "TargetArea subdivision does not match!")
+	assertEqual(t, len(parsed.Alerts), 1, "One alert should be embedded")
+	assertEqual(t, parsed.Alerts[0].MessageID, d.Alerts[0].MessageID, "Embedded alert does not match!")
+}
+
+func TestDistributionRoundTripsEDXL20(t *testing.T) {
+	d, err := getEDXLDistributionExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.ExpiresDate = "2015-08-16T11:45:00-05:00"
+	d.TargetAreas[0].Circles = []string{"35.1,-91.33 10"}
+
+	data, err := d.MarshalEDXL20()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseDistribution(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, parsed.Status, "Actual", "Status does not match!")
+	assertEqual(t, parsed.Type, "Report", "Type does not match!")
+	assertEqual(t, parsed.ExpiresDate, "2015-08-16T11:45:00-05:00", "ExpiresDate does not match!")
+	assertEqual(t, parsed.Keywords[0].ListURN, "urn:example:keywords", "Keyword list does not match!")
+	assertEqual(t, parsed.TargetAreas[0].Polygons[0], d.TargetAreas[0].Polygons[0], "TargetArea polygon does not match!")
+	assertEqual(t, parsed.TargetAreas[0].Circles[0], "35.1,-91.33 10", "TargetArea circle does not match!")
+	assertEqual(t, parsed.TargetAreas[0].Countries[0], "US", "TargetArea country does not match!")
+	assertEqual(t, len(parsed.Alerts), 1, "One alert should be embedded")
+	assertEqual(t, parsed.Alerts[0].Infos[0].EventType, "Flood Warning", "Embedded alert does not match!")
+}
+
+func TestExtractAlertsSupportsCAP11(t *testing.T) {
+	xmlData := []byte(`<EDXLDistribution xmlns="urn:oasis:names:tc:emergency:EDXL:DE:1.0">
+	<distributionID>1</distributionID>
+	<contentObject><xmlContent><embeddedXMLContent>
+		<alert xmlns="urn:oasis:names:tc:emergency:cap:1.1"><identifier>a</identifier></alert>
+	</embeddedXMLContent></xmlContent></contentObject>
+	<contentObject><xmlContent><embeddedXMLContent>
+		<cap:alert xmlns:cap="urn:oasis:names:tc:emergency:cap:1.2"><cap:identifier>b</cap:identifier></cap:alert>
+	</embeddedXMLContent></xmlContent></contentObject>
+</EDXLDistribution>`)
+
+	alerts, err := ExtractAlerts(xmlData)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(alerts), 2, "Two alerts should be extracted")
+	assertEqual(t, alerts[0].MessageID, "a", "CAP 1.1 alert does not match!")
+	assertEqual(t, alerts[1].MessageID, "b", "CAP 1.2 alert does not match!")
+}
+
+func TestParseDistributionReturnsErrForOtherDocuments(t *testing.T) {
+	_, err := ParseDistribution([]byte(`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"/>`))
+
+	if err == nil || !strings.Contains(err.Error(), "EDXLDistribution") {
+		t.Errorf("Unexpected or missing error: %v", err)
+	}
+}
+
+func TestExtractAlertsUsesEnvelopeNamespaces(t *testing.T) {
+	alerts, err := ExtractAlerts([]byte(`<EDXLDistribution xmlns="urn:oasis:names:tc:emergency:EDXL:DE:1.0" xmlns:cap="urn:oasis:names:tc:emergency:cap:1.2">
+	<contentObject><xmlContent><embeddedXMLContent>
+		<cap:alert><cap:identifier>a</cap:identifier><cap:info><cap:event>Flood Warning</cap:event></cap:info></cap:alert>
+	</embeddedXMLContent></xmlContent></contentObject>
+</EDXLDistribution>`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, alerts[0].MessageID, "a", "Identifier does not match!")
+	assertEqual(t, alerts[0].Infos[0].EventType, "Flood Warning", "Event does not match!")
+}
+
+func TestParseDistributionAppliesLimits(t *testing.T) {
+	_, err := ParseDistribution([]byte(`<!DOCTYPE EDXLDistribution [<!ENTITY x "x">]>
+<EDXLDistribution xmlns="urn:oasis:names:tc:emergency:EDXL:DE:1.0"/>`))
+
+	assertEqual(t, err, ErrDTDNotAllowed, "DOCTYPE declarations should be rejected")
+
+	var b strings.Builder
+
+	b.WriteString(`<EDXLDistribution xmlns="urn:oasis:names:tc:emergency:EDXL:DE:1.0"><contentObject><xmlContent><embeddedXMLContent><alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">`)
+
+	for i := 0; i <= DefaultLimits.MaxInfos; i++ {
+		b.WriteString(`<info/>`)
+	}
+
+	b.WriteString(`</alert></embeddedXMLContent></xmlContent></contentObject></EDXLDistribution>`)
+
+	_, err = ExtractAlerts([]byte(b.String()))
+
+	if _, ok := err.(*LimitError); !ok {
+		t.Errorf("Expected a LimitError but got %v", err)
+	}
+}
+
+func TestMarshalEDXL20WritesKeywordsBeforeTargetAreas(t *testing.T) {
+	d, err := getEDXLDistributionExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := d.MarshalEDXL20()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyword, target := strings.Index(string(data), "<keyword>"), strings.Index(string(data), "<targetArea>")
+
+	assertEqual(t, keyword >= 0 && target > keyword, true, "The schema sequence puts keyword before targetArea")
+}
+
+// edxl20Sample follows the EDXL-DE 2.0 example distribution, with its
+// descriptor, bare status and kind values and Common Types keywords
+const edxl20Sample = `<?xml version="1.0" encoding="UTF-8"?>
+<EDXLDistribution xmlns="urn:oasis:names:tc:emergency:EDXL:DE:2.0" xmlns:ct="urn:oasis:names:tc:emergency:edxl:ct:1.0" xmlns:gml="http://www.opengis.net/gml/3.2">
+	<distributionID>urn:example:de:12332</distributionID>
+	<senderID>person@example.com</senderID>
+	<dateTimeSent>2015-08-15T20:45:00-05:00</dateTimeSent>
+	<dateTimeExpires>2015-08-16T11:45:00-05:00</dateTimeExpires>
+	<distributionStatus>
+		<StatusKindDefault>Exercise</StatusKindDefault>
+	</distributionStatus>
+	<distributionKind>
+		<DistributionKindDefault>Update</DistributionKindDefault>
+	</distributionKind>
+	<descriptor>
+		<language>en-US</language>
+		<keyword>
+			<ct:ValueListURI>urn:example:keywords</ct:ValueListURI>
+			<ct:Value>flood</ct:Value>
+		</keyword>
+	</descriptor>
+	<targetArea>
+		<ct:EDXLGeoPoliticalLocation>
+			<ct:GeoCode>
+				<ct:ValueListURI>urn:oasis:names:tc:emergency:edxl:ct:1.0:country</ct:ValueListURI>
+				<ct:Value>US</ct:Value>
+			</ct:GeoCode>
+		</ct:EDXLGeoPoliticalLocation>
+	</targetArea>
+	<content>
+		<contentObject>
+			<xmlContent>
+				<embeddedXMLContent>
+					<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"><identifier>1</identifier></alert>
+				</embeddedXMLContent>
+			</xmlContent>
+		</contentObject>
+	</content>
+</EDXLDistribution>`
+
+func TestParseDistributionReadsEDXL20Sample(t *testing.T) {
+	d, err := ParseDistribution([]byte(edxl20Sample))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, d.Status, "Exercise", "Status does not match!")
+	assertEqual(t, d.Type, "Update", "Type does not match!")
+	assertEqual(t, d.ExpiresDate, "2015-08-16T11:45:00-05:00", "ExpiresDate does not match!")
+	assertEqual(t, d.Language, "en-US", "The language should be read from the descriptor")
+	assertEqual(t, d.Keywords[0].ListURN, "urn:example:keywords", "The keyword should be read from the descriptor")
+	assertEqual(t, d.Keywords[0].Values[0], "flood", "Keyword does not match!")
+	assertEqual(t, d.TargetAreas[0].Countries[0], "US", "TargetArea country does not match!")
+	assertEqual(t, d.Alerts[0].MessageID, "1", "Embedded alert does not match!")
+}
+
+func TestMarshalEDXL20FollowsTheSchema(t *testing.T) {
+	d, err := ParseDistribution([]byte(edxl20Sample))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := d.MarshalEDXL20()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"<dateTimeExpires>2015-08-16T11:45:00-05:00</dateTimeExpires>",
+		"<distributionStatus>\n    <StatusKindDefault>Exercise</StatusKindDefault>\n  </distributionStatus>",
+		"<distributionKind>\n    <DistributionKindDefault>Update</DistributionKindDefault>\n  </distributionKind>",
+		"<descriptor>\n    <language>en-US</language>\n    <keyword>",
+		`<ValueListURI xmlns="urn:oasis:names:tc:emergency:edxl:ct:1.0">urn:example:keywords</ValueListURI>`,
+		`<Value xmlns="urn:oasis:names:tc:emergency:edxl:ct:1.0">flood</Value>`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected the envelope to contain %s but got\n%s", expected, data)
+		}
+	}
+
+	d.ExpiresDate = ""
+	_, err = d.MarshalEDXL20()
+
+	assertEqual(t, err.Error(), "EDXL-DE 2.0 distributions require an ExpiresDate", "The expiry should be required")
+}
+
+const xmlHeaderPrefix = `<?xml version="1.0"`
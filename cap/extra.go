@@ -0,0 +1,297 @@
+package cap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// RawElement is an XML element that is not modelled by the CAP structs, kept
+// as its tokens so it can be written back out
+//
+// The element's namespaces are preserved, but their prefixes may be renamed
+// when it is marshalled, since encoding/xml declares namespaces itself.
+type RawElement struct {
+	// Tokens starts with the element's xml.StartElement and ends with its xml.EndElement
+	Tokens []xml.Token
+}
+
+// Name returns back the name of the element
+func (r RawElement) Name() xml.Name {
+	if len(r.Tokens) == 0 {
+		return xml.Name{}
+	}
+
+	start, _ := r.Tokens[0].(xml.StartElement)
+
+	return start.Name
+}
+
+// UnmarshalXML records the tokens of the element
+func (r *RawElement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	r.Tokens = append(r.Tokens[:0], stripNamespaceDeclarations(start))
+
+	for depth := 1; depth > 0; {
+		tok, err := d.Token()
+
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			tok = stripNamespaceDeclarations(t)
+		case xml.EndElement:
+			depth--
+		default:
+			tok = xml.CopyToken(t)
+		}
+
+		r.Tokens = append(r.Tokens, tok)
+	}
+
+	return nil
+}
+
+// MarshalXML writes the recorded tokens of the element
+func (r RawElement) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(r.Tokens) == 0 {
+		return fmt.Errorf("Cannot marshal an empty RawElement")
+	}
+
+	for _, tok := range r.Tokens {
+		if err := e.EncodeToken(tok); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExtraAttrs holds the attributes of an element that are not modelled
+//
+// Namespace declarations are left out, since encoding/xml declares the
+// namespaces it needs when marshalling.
+type ExtraAttrs []xml.Attr
+
+// UnmarshalXMLAttr records attr unless it is a namespace declaration
+func (a *ExtraAttrs) UnmarshalXMLAttr(attr xml.Attr) error {
+	if !isNamespaceDeclaration(attr) {
+		*a = append(*a, attr)
+	}
+
+	return nil
+}
+
+// stripNamespaceDeclarations returns back a copy of start without its xmlns attributes
+func stripNamespaceDeclarations(start xml.StartElement) xml.StartElement {
+	copied := xml.StartElement{Name: start.Name}
+
+	for _, attr := range start.Attr {
+		if !isNamespaceDeclaration(attr) {
+			copied.Attr = append(copied.Attr, attr)
+		}
+	}
+
+	return copied
+}
+
+func isNamespaceDeclaration(attr xml.Attr) bool {
+	return attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns")
+}
+
+// The CAP types decoded with their modelled fields restricted to the CAP
+// namespaces, see decodeCAPElement
+type (
+	alertElement    Alert
+	infoElement     Info
+	resourceElement Resource
+	areaElement     Area
+)
+
+// UnmarshalXML decodes a CAP 1.2 alert, keeping elements outside the CAP namespaces in Extra
+func (alert *Alert) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	err := decodeCAPElement(d, start, start.Name, (*alertElement)(alert), &alert.Extra)
+	alert.HandlingCode = firstOf(alert.HandlingCodes)
+
+	return err
+}
+
+// MarshalXML encodes a CAP 1.2 alert, writing the deprecated HandlingCode if it has no HandlingCodes
+func (alert Alert) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	alert.HandlingCodes = alert.handlingCodes()
+	start.Name = xml.Name{Space: CAP12Namespace, Local: "alert"}
+
+	return e.EncodeElement((*alertElement)(&alert), start)
+}
+
+// UnmarshalXML decodes a CAP 1.1 alert, keeping elements outside the CAP namespaces in Extra
+func (alert *Alert11) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if start.Name.Local != "alert" {
+		return xml.UnmarshalError("expected element type <alert> but have <" + start.Name.Local + ">")
+	}
+
+	if start.Name.Space != CAP11Namespace {
+		space := start.Name.Space
+
+		if space == "" {
+			space = "no name space"
+		}
+
+		return xml.UnmarshalError("expected element <alert> in name space " + CAP11Namespace + " but have " + space)
+	}
+
+	// The embedded Alert only accepts the CAP 1.2 name
+	err := decodeCAPElement(d, start, xml.Name{Space: CAP12Namespace, Local: "alert"}, (*alertElement)(&alert.Alert), &alert.Alert.Extra)
+
+	alert.XMLName = start.Name
+	alert.Alert.XMLName = xml.Name{}
+	alert.HandlingCode = firstOf(alert.HandlingCodes)
+
+	return err
+}
+
+// MarshalXML encodes a CAP 1.1 alert, writing the deprecated HandlingCode if it has no HandlingCodes
+func (alert Alert11) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	inner := alert.Alert
+	inner.HandlingCodes = inner.handlingCodes()
+	start.Name = xml.Name{Space: CAP11Namespace, Local: "alert"}
+
+	return e.EncodeElement((*alertElement)(&inner), start)
+}
+
+// UnmarshalXML decodes the Info block, keeping elements outside the CAP namespaces in Extra
+func (info *Info) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return decodeCAPElement(d, start, start.Name, (*infoElement)(info), &info.Extra)
+}
+
+// UnmarshalXML decodes the Resource, keeping elements outside the CAP namespaces in Extra
+func (r *Resource) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return decodeCAPElement(d, start, start.Name, (*resourceElement)(r), &r.Extra)
+}
+
+// UnmarshalXML decodes the Area, keeping elements outside the CAP namespaces in Extra
+func (a *Area) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	err := decodeCAPElement(d, start, start.Name, (*areaElement)(a), &a.Extra)
+	a.Polygon = firstOf(a.Polygons)
+	a.Circle = firstOf(a.Circles)
+
+	return err
+}
+
+// MarshalXML encodes the Area, writing the deprecated Polygon and Circle if it has no Polygons or Circles
+func (a Area) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a.Polygons = a.polygons()
+	a.Circles = a.circles()
+	start.Name = xml.Name{Local: "area"}
+
+	return e.EncodeElement((*areaElement)(&a), start)
+}
+
+// isCAPNamespace returns back true for the CAP 1.2 and 1.1 namespaces, and for elements without one
+func isCAPNamespace(space string) bool {
+	return space == "" || space == CAP12Namespace || space == CAP11Namespace
+}
+
+// foreignPlaceholder stands in for a child element outside the CAP namespaces
+// while the modelled fields are decoded. It is not a valid XML name, so it
+// cannot match a CAP element.
+var foreignPlaceholder = xml.Name{Local: "#foreign"}
+
+// decodeCAPElement decodes the element that start opens into v, which has
+// the fields of a CAP type but not its UnmarshalXML method
+//
+// encoding/xml matches fields by local name alone, so an extension such as
+// ext:note would be decoded into the note element. Children outside the CAP
+// namespaces are replaced by placeholders while v is decoded, and put back
+// into extra in their place afterwards. The element is decoded as if it were
+// named root.
+func decodeCAPElement(d *xml.Decoder, start xml.StartElement, root xml.Name, v interface{}, extra *[]RawElement) error {
+	r := &capElementReader{d: d, root: root}
+	first := stripNamespaceDeclarations(start)
+	first.Name = root
+	r.pending = first
+
+	inner := xml.NewTokenDecoder(r)
+
+	if _, err := inner.Token(); err != nil {
+		return err
+	}
+
+	if err := inner.DecodeElement(v, &first); err != nil {
+		return err
+	}
+
+	next := 0
+
+	for i := range *extra {
+		if next < len(r.foreign) && (*extra)[i].Name() == foreignPlaceholder {
+			(*extra)[i] = r.foreign[next]
+			next++
+		}
+	}
+
+	return nil
+}
+
+// capElementReader passes on the tokens of a CAP element, setting aside its
+// children outside the CAP namespaces, see decodeCAPElement
+type capElementReader struct {
+	d       *xml.Decoder
+	root    xml.Name
+	depth   int
+	pending xml.Token
+	foreign []RawElement
+}
+
+func (r *capElementReader) Token() (xml.Token, error) {
+	if r.pending != nil {
+		tok := r.pending
+		r.pending = nil
+
+		if _, ok := tok.(xml.StartElement); ok {
+			r.depth++
+		}
+
+		return tok, nil
+	}
+
+	if r.depth == 0 {
+		return nil, io.EOF
+	}
+
+	tok, err := r.d.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case xml.StartElement:
+		if r.depth == 1 && !isCAPNamespace(t.Name.Space) {
+			var raw RawElement
+
+			if err := raw.UnmarshalXML(r.d, t); err != nil {
+				return nil, err
+			}
+
+			r.foreign = append(r.foreign, raw)
+			r.pending = xml.EndElement{Name: foreignPlaceholder}
+
+			return xml.StartElement{Name: foreignPlaceholder}, nil
+		}
+
+		r.depth++
+
+		return stripNamespaceDeclarations(t), nil
+	case xml.EndElement:
+		if r.depth--; r.depth == 0 {
+			t.Name = r.root
+		}
+
+		return t, nil
+	}
+
+	return xml.CopyToken(tok), nil
+}
@@ -0,0 +1,223 @@
+package cap
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+const extraAlert = `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2" xmlns:v="urn:example:vendor" v:channel="relay">
+	<identifier>1</identifier>
+	<info>
+		<event>Flood Warning</event>
+		<v:priority level="2">high</v:priority>
+		<area v:zone="A">
+			<areaDesc>Jackson</areaDesc>
+			<v:shape><v:point>35,-91</v:point></v:shape>
+		</area>
+		<resource><resourceDesc>Map</resourceDesc><v:checksum>abc</v:checksum></resource>
+	</info>
+	<futureField>value</futureField>
+	<Signature xmlns="http://www.w3.org/2000/09/xmldsig#"><SignedInfo/><SignatureValue>AAAA</SignatureValue></Signature>
+</alert>`
+
+func extraNames(elements []RawElement) string {
+	names := make([]string, len(elements))
+
+	for i, e := range elements {
+		names[i] = e.Name().Local
+	}
+
+	return strings.Join(names, " ")
+}
+
+func TestParseAlertKeepsUnknownElements(t *testing.T) {
+	alert, err := ParseAlert([]byte(extraAlert))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := alert.Infos[0]
+
+	assertEqual(t, extraNames(alert.Extra), "futureField Signature", "Alert extras do not match!")
+	assertEqual(t, extraNames(info.Extra), "priority", "Info extras do not match!")
+	assertEqual(t, extraNames(info.Areas[0].Extra), "shape", "Area extras do not match!")
+	assertEqual(t, extraNames(info.Resources[0].Extra), "checksum", "Resource extras do not match!")
+	assertEqual(t, len(alert.ExtraAttrs), 1, "Namespace declarations should not be kept")
+	assertEqual(t, alert.ExtraAttrs[0], xml.Attr{Name: xml.Name{Space: "urn:example:vendor", Local: "channel"}, Value: "relay"}, "Alert attribute does not match!")
+	assertEqual(t, info.Areas[0].ExtraAttrs[0].Value, "A", "Area attribute does not match!")
+}
+
+func TestMarshalWritesUnknownElementsBack(t *testing.T) {
+	alert, err := ParseAlert([]byte(extraAlert))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xmlData, err := xml.Marshal(alert)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reparsed, err := ParseAlert(xmlData)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, extraNames(reparsed.Extra), "futureField Signature", "Alert extras do not match!")
+	assertEqual(t, reparsed.Extra[1].Name().Space, "http://www.w3.org/2000/09/xmldsig#", "Signature namespace does not match!")
+	assertEqual(t, reparsed.ExtraAttrs[0].Value, "relay", "Alert attribute does not match!")
+
+	shape := reparsed.Infos[0].Areas[0].Extra[0].Tokens
+	point := shape[1].(xml.StartElement)
+
+	assertEqual(t, point.Name, xml.Name{Space: "urn:example:vendor", Local: "point"}, "Nested element does not match!")
+	assertEqual(t, string(shape[2].(xml.CharData)), "35,-91", "Nested text does not match!")
+	assertEqual(t, reparsed.Infos[0].Extra[0].Tokens[0].(xml.StartElement).Attr[0].Value, "2", "Element attribute does not match!")
+}
+
+func TestParseAlertKeepsForeignElementsNamedLikeCAPElements(t *testing.T) {
+	alert, err := ParseAlert([]byte(`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2" xmlns:ext="urn:example:ext">
+	<identifier>1</identifier>
+	<ext:note>vendor note</ext:note>
+	<note>CAP note</note>
+	<info>
+		<ext:event>vendor event</ext:event>
+		<event>Flood Warning</event>
+		<area><areaDesc>Jackson</areaDesc><ext:polygon>0,0 0,1 1,1 0,0</ext:polygon></area>
+	</info>
+</alert>`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := alert.Infos[0]
+
+	assertEqual(t, alert.Note, "CAP note", "The foreign note should not be decoded as the CAP note")
+	assertEqual(t, extraNames(alert.Extra), "note", "The foreign note should be kept")
+	assertEqual(t, alert.Extra[0].Name().Space, "urn:example:ext", "The foreign note should keep its namespace")
+	assertEqual(t, info.EventType, "Flood Warning", "The foreign event should not be decoded as the CAP event")
+	assertEqual(t, extraNames(info.Extra), "event", "The foreign event should be kept")
+	assertEqual(t, len(info.Areas[0].Polygons), 0, "The foreign polygon should not be decoded as a CAP polygon")
+	assertEqual(t, extraNames(info.Areas[0].Extra), "polygon", "The foreign polygon should be kept")
+
+	xmlData, err := xml.Marshal(alert)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reparsed, err := ParseAlert(xmlData)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, reparsed.Note, "CAP note", "The CAP note should survive a round trip")
+	assertEqual(t, reparsed.Extra[0].Name().Space, "urn:example:ext", "The foreign note should survive a round trip")
+}
+
+func TestParseAlertKeepsRepeatedElements(t *testing.T) {
+	alert, err := ParseAlert([]byte(`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+	<identifier>1</identifier>
+	<code>profile:CAP-CP:0.4</code>
+	<code>layer:SOREM:1.0</code>
+	<info>
+		<event>Flood Warning</event>
+		<area>
+			<areaDesc>Two shapes</areaDesc>
+			<polygon>0,0 0,1 1,1 0,0</polygon>
+			<polygon>5,5 5,6 6,6 5,5</polygon>
+			<circle>10,10 5</circle>
+			<circle>20,20 5</circle>
+		</area>
+	</info>
+</alert>`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xmlData, err := xml.Marshal(alert)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reparsed, err := ParseAlert(xmlData)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	area := reparsed.Infos[0].Areas[0]
+
+	assertEqual(t, strings.Join(reparsed.HandlingCodes, " "), "profile:CAP-CP:0.4 layer:SOREM:1.0", "Every code should be kept")
+	assertEqual(t, reparsed.HasHandlingCode("layer:SOREM:1.0"), true, "The second code should be found")
+	assertEqual(t, strings.Join(area.Polygons, "|"), "0,0 0,1 1,1 0,0|5,5 5,6 6,6 5,5", "Every polygon should be kept")
+	assertEqual(t, strings.Join(area.Circles, "|"), "10,10 5|20,20 5", "Every circle should be kept")
+	assertEqual(t, area.Contains(5.5, 5.5), true, "The second polygon should be used")
+	assertEqual(t, area.Contains(20, 20), true, "The second circle should be used")
+}
+
+func TestDeprecatedSingleFieldsStillWork(t *testing.T) {
+	alert := &Alert{
+		MessageID:    "1",
+		HandlingCode: "IPAWSv1.0",
+		Infos:        []Info{{Areas: []Area{{Description: "Old style", Polygon: "0,0 0,1 1,1 0,0", Circle: "10,10 5"}}}},
+	}
+
+	assertEqual(t, alert.HasHandlingCode("IPAWSv1.0"), true, "The deprecated code should be used")
+	assertEqual(t, alert.Infos[0].Areas[0].Contains(10, 10), true, "The deprecated circle should be used")
+
+	xmlData, err := xml.Marshal(alert)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reparsed, err := ParseAlert(xmlData)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	area := reparsed.Infos[0].Areas[0]
+
+	assertEqual(t, strings.Join(reparsed.HandlingCodes, " "), "IPAWSv1.0", "The deprecated code should be written")
+	assertEqual(t, reparsed.HandlingCode, "IPAWSv1.0", "The first code should be parsed into the deprecated field")
+	assertEqual(t, strings.Join(area.Polygons, "|"), "0,0 0,1 1,1 0,0", "The deprecated polygon should be written")
+	assertEqual(t, area.Polygon, "0,0 0,1 1,1 0,0", "The first polygon should be parsed into the deprecated field")
+	assertEqual(t, area.Circle, "10,10 5", "The first circle should be parsed into the deprecated field")
+
+	reparsed.HandlingCodes = []string{"profile:CAP-CP:0.4"}
+
+	assertEqual(t, reparsed.HasHandlingCode("IPAWSv1.0"), false, "The deprecated code should be ignored once HandlingCodes is set")
+}
+
+func TestParseAlert11KeepsForeignElements(t *testing.T) {
+	alert, err := ParseAlert11([]byte(`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.1" xmlns:ext="urn:example:ext">
+	<identifier>1</identifier>
+	<ext:note>vendor note</ext:note>
+	<info><event>Flood Warning</event></info>
+</alert>`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, alert.MessageID, "1", "Identifier does not match!")
+	assertEqual(t, alert.Note, "", "The foreign note should not be decoded as the CAP note")
+	assertEqual(t, extraNames(alert.Extra), "note", "The foreign note should be kept")
+	assertEqual(t, alert.Infos[0].EventType, "Flood Warning", "Event does not match!")
+
+	var wrong Alert11
+	err = xml.Unmarshal([]byte(`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"/>`), &wrong)
+
+	assertEqual(t, err.Error(), "expected element <alert> in name space urn:oasis:names:tc:emergency:cap:1.1 but have urn:oasis:names:tc:emergency:cap:1.2", "CAP 1.2 alerts should be rejected")
+}
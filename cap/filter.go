@@ -0,0 +1,751 @@
+package cap
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Filter is a compiled filter expression that selects alerts
+//
+// Filter expressions compare fields of an alert with values, for example:
+//
+//	severity in (Severe, Extreme) and event ~ "Tornado" and geocode.FIPS6 startswith "005"
+//
+// Fields are named after the CAP elements they read. Alert fields (identifier,
+// sender, sent, status, msgType, source, scope, restriction, addresses, code,
+// note, references, incidents), Info fields (language, category, event,
+// responseType, urgency, severity, certainty, audience, effective, expires,
+// onset, senderName, headline, description, instruction, web, contact) and Area
+// fields (areaDesc, polygon, circle, altitude, ceiling) are supported, along
+// with the named lookups parameter.NAME, eventCode.NAME and geocode.NAME. Names
+// that are not valid identifiers can be looked up with parameter("NAME"),
+// eventCode("NAME") and geocode("NAME").
+//
+// The operators are =, !=, ~ (regular expression), !~, <, <=, >, >= (numeric),
+// in (...), startswith, endswith and contains, combined with and, or, not and
+// parentheses. Values are quoted strings or bare words. Comparisons are case
+// sensitive; keywords and field names are not.
+//
+// An alert matches if any of its Info blocks, together with any one of that
+// Info's Areas, satisfies the whole expression. A field with several values,
+// such as a repeated geocode, satisfies a comparison if any of its values does,
+// except for != and !~, which require that none do.
+type Filter struct {
+	expr string
+	eval filterFunc
+}
+
+// FilterSyntaxError describes a problem found while compiling a filter expression
+type FilterSyntaxError struct {
+	// Offset is the byte offset of the problem in the expression
+	Offset int
+
+	// Column is the 1-based column of the problem in the expression
+	Column int
+
+	Message string
+}
+
+func (e *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("Filter syntax error at column %d: %s", e.Column, e.Message)
+}
+
+// filterTarget is the combination of elements a compiled filter is evaluated against
+type filterTarget struct {
+	alert *Alert
+	info  *Info
+	area  *Area
+	entry *NWSAtomEntry
+}
+
+type filterFunc func(t *filterTarget) bool
+
+type filterAccessor func(t *filterTarget) []string
+
+// CompileFilter parses a filter expression into a Filter
+func CompileFilter(expr string) (*Filter, error) {
+	p := filterParser{expr: expr}
+
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+
+	eval, err := p.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != filterEOF {
+		return nil, p.errorAt(tok, fmt.Sprintf("unexpected %s", tok))
+	}
+
+	return &Filter{expr: expr, eval: eval}, nil
+}
+
+// MustCompileFilter is like CompileFilter but panics if the expression cannot be compiled
+func MustCompileFilter(expr string) *Filter {
+	f, err := CompileFilter(expr)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return f
+}
+
+// String returns back the source expression of the filter
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// MatchAlert returns back whether the alert is selected by the filter
+func (f *Filter) MatchAlert(alert *Alert) bool {
+	if len(alert.Infos) == 0 {
+		return f.eval(&filterTarget{alert: alert})
+	}
+
+	for i := range alert.Infos {
+		if f.MatchInfo(alert, &alert.Infos[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchInfo returns back whether an Info block of the alert is selected by the filter
+func (f *Filter) MatchInfo(alert *Alert, info *Info) bool {
+	target := filterTarget{alert: alert, info: info}
+
+	if len(info.Areas) == 0 {
+		return f.eval(&target)
+	}
+
+	for i := range info.Areas {
+		target.area = &info.Areas[i]
+
+		if f.eval(&target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchEntry returns back whether the NWS Atom feed entry is selected by the filter
+//
+// Entries carry only a subset of the alert fields. The headline field reads
+// the entry title and the description field reads the entry summary.
+func (f *Filter) MatchEntry(entry *NWSAtomEntry) bool {
+	return f.eval(&filterTarget{entry: entry})
+}
+
+// filterFields maps lower-cased field names to their accessors
+var filterFields = map[string]filterAccessor{
+	"identifier":  alertField(func(a *Alert) string { return a.MessageID }, func(e *NWSAtomEntry) string { return e.ID }),
+	"sender":      alertField(func(a *Alert) string { return a.SenderID }, nil),
+	"sent":        alertField(func(a *Alert) string { return a.SentDate }, func(e *NWSAtomEntry) string { return e.PublishedDate }),
+	"status":      alertField(func(a *Alert) string { return a.MessageStatus }, func(e *NWSAtomEntry) string { return e.MessageStatus }),
+	"msgtype":     alertField(func(a *Alert) string { return a.MessageType }, func(e *NWSAtomEntry) string { return e.MessageType }),
+	"source":      alertField(func(a *Alert) string { return a.Source }, nil),
+	"scope":       alertField(func(a *Alert) string { return a.Scope }, nil),
+	"restriction": alertField(func(a *Alert) string { return a.Restriction }, nil),
+	"addresses":   alertField(func(a *Alert) string { return a.Addresses }, nil),
+	"note":        alertField(func(a *Alert) string { return a.Note }, nil),
+	"code": func(t *filterTarget) []string {
+		if t.alert == nil {
+			return nil
+		}
+
+		return t.alert.handlingCodes()
+	},
+	"references": func(t *filterTarget) []string {
+		if t.alert == nil {
+			return nil
+		}
+
+		return strings.Fields(strings.Join(t.alert.ReferenceIDs, " "))
+	},
+	"incidents": func(t *filterTarget) []string {
+		if t.alert == nil {
+			return nil
+		}
+
+		return strings.Fields(strings.Join(t.alert.IncidentIDs, " "))
+	},
+
+	"language":     infoField(func(i *Info) string { return i.Language }, nil),
+	"category":     infoField(func(i *Info) string { return i.EventCategory }, func(e *NWSAtomEntry) string { return e.EventCategory }),
+	"event":        infoField(func(i *Info) string { return i.EventType }, func(e *NWSAtomEntry) string { return e.EventType }),
+	"responsetype": infoField(func(i *Info) string { return i.ResponseType }, nil),
+	"urgency":      infoField(func(i *Info) string { return i.Urgency }, func(e *NWSAtomEntry) string { return e.Urgency }),
+	"severity":     infoField(func(i *Info) string { return i.Severity }, func(e *NWSAtomEntry) string { return e.Severity }),
+	"certainty":    infoField(func(i *Info) string { return i.Certainty }, func(e *NWSAtomEntry) string { return e.Certainty }),
+	"audience":     infoField(func(i *Info) string { return i.Audience }, nil),
+	"effective":    infoField(func(i *Info) string { return i.EffectiveDate }, func(e *NWSAtomEntry) string { return e.EffectiveDate }),
+	"expires":      infoField(func(i *Info) string { return i.ExpiresDate }, func(e *NWSAtomEntry) string { return e.ExpiresDate }),
+	"onset":        infoField(func(i *Info) string { return i.OnsetDate }, nil),
+	"sendername":   infoField(func(i *Info) string { return i.SenderName }, nil),
+	"headline":     infoField(func(i *Info) string { return i.Headline }, func(e *NWSAtomEntry) string { return e.Title }),
+	"description":  infoField(func(i *Info) string { return i.EventDescription }, func(e *NWSAtomEntry) string { return e.Summary }),
+	"instruction":  infoField(func(i *Info) string { return i.Instruction }, nil),
+	"web":          infoField(func(i *Info) string { return i.InformationURL }, nil),
+	"contact":      infoField(func(i *Info) string { return i.ContactInfo }, nil),
+
+	"areadesc": areaField(func(a *Area) string { return a.Description }, func(e *NWSAtomEntry) string { return e.AreaDescription }),
+	"polygon":  areaList(func(a *Area) []string { return a.polygons() }, func(e *NWSAtomEntry) string { return e.Polygon }),
+	"circle":   areaList(func(a *Area) []string { return a.circles() }, func(e *NWSAtomEntry) string { return e.Circle }),
+	"altitude": areaField(func(a *Area) string { return a.Altitude }, nil),
+	"ceiling":  areaField(func(a *Area) string { return a.Ceiling }, nil),
+}
+
+// filterLookups maps lower-cased lookup names to functions building their accessors
+var filterLookups = map[string]func(name string) filterAccessor{
+	"parameter": func(name string) filterAccessor {
+		return func(t *filterTarget) []string {
+			switch {
+			case t.info != nil:
+				return searchAll(&t.info.Parameters, name)
+			case t.entry != nil:
+				return searchAll(&t.entry.Parameters, name)
+			}
+
+			return nil
+		}
+	},
+	"eventcode": func(name string) filterAccessor {
+		return func(t *filterTarget) []string {
+			if t.info == nil {
+				return nil
+			}
+
+			return searchAll(&t.info.EventCode, name)
+		}
+	},
+	"geocode": func(name string) filterAccessor {
+		return func(t *filterTarget) []string {
+			switch {
+			case t.area != nil:
+				return t.area.GeocodeAll(name)
+			case t.entry != nil:
+				return t.entry.Geocode.GetValues(name)
+			}
+
+			return nil
+		}
+	},
+}
+
+func alertField(fromAlert func(*Alert) string, fromEntry func(*NWSAtomEntry) string) filterAccessor {
+	return func(t *filterTarget) []string {
+		switch {
+		case t.alert != nil:
+			return []string{fromAlert(t.alert)}
+		case t.entry != nil && fromEntry != nil:
+			return []string{fromEntry(t.entry)}
+		}
+
+		return nil
+	}
+}
+
+func infoField(fromInfo func(*Info) string, fromEntry func(*NWSAtomEntry) string) filterAccessor {
+	return func(t *filterTarget) []string {
+		switch {
+		case t.info != nil:
+			return []string{fromInfo(t.info)}
+		case t.entry != nil && fromEntry != nil:
+			return []string{fromEntry(t.entry)}
+		}
+
+		return nil
+	}
+}
+
+func areaField(fromArea func(*Area) string, fromEntry func(*NWSAtomEntry) string) filterAccessor {
+	return func(t *filterTarget) []string {
+		switch {
+		case t.area != nil:
+			return []string{fromArea(t.area)}
+		case t.entry != nil && fromEntry != nil:
+			return []string{fromEntry(t.entry)}
+		}
+
+		return nil
+	}
+}
+
+// areaList is areaField for repeated elements
+func areaList(fromArea func(*Area) []string, fromEntry func(*NWSAtomEntry) string) filterAccessor {
+	return func(t *filterTarget) []string {
+		switch {
+		case t.area != nil:
+			return fromArea(t.area)
+		case t.entry != nil && fromEntry != nil:
+			return []string{fromEntry(t.entry)}
+		}
+
+		return nil
+	}
+}
+
+type filterTokenKind int
+
+const (
+	filterEOF filterTokenKind = iota
+	filterWord
+	filterString
+	filterOperator
+	filterLParen
+	filterRParen
+	filterComma
+)
+
+type filterToken struct {
+	kind  filterTokenKind
+	text  string
+	value string
+	pos   int
+}
+
+func (t filterToken) String() string {
+	switch t.kind {
+	case filterEOF:
+		return "end of expression"
+	case filterString:
+		return "string " + t.text
+	}
+
+	return fmt.Sprintf("%q", t.text)
+}
+
+// keyword returns back the lower-cased text of a word token, or "" for other tokens
+func (t filterToken) keyword() string {
+	if t.kind != filterWord {
+		return ""
+	}
+
+	return strings.ToLower(t.text)
+}
+
+type filterParser struct {
+	expr   string
+	tokens []filterToken
+	next   int
+}
+
+func (p *filterParser) errorAt(tok filterToken, message string) error {
+	return &FilterSyntaxError{
+		Offset:  tok.pos,
+		Column:  len([]rune(p.expr[:tok.pos])) + 1,
+		Message: message,
+	}
+}
+
+func isFilterWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-+:", r)
+}
+
+// lex splits the expression into tokens
+func (p *filterParser) lex() error {
+	s := p.expr
+	i := 0
+
+	for i < len(s) {
+		r, _ := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+		case r == '(':
+			p.tokens = append(p.tokens, filterToken{kind: filterLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			p.tokens = append(p.tokens, filterToken{kind: filterRParen, text: ")", pos: i})
+			i++
+		case r == ',':
+			p.tokens = append(p.tokens, filterToken{kind: filterComma, text: ",", pos: i})
+			i++
+		case r == '"' || r == '\'':
+			end, value, err := lexFilterString(s, i)
+
+			if err != nil {
+				return p.errorAt(filterToken{pos: i}, err.Error())
+			}
+
+			p.tokens = append(p.tokens, filterToken{kind: filterString, text: s[i:end], value: value, pos: i})
+			i = end
+		case strings.ContainsRune("=!~<>&|", r):
+			op := lexFilterOperator(s[i:])
+
+			if op == "" {
+				return p.errorAt(filterToken{pos: i}, fmt.Sprintf("unknown operator %q", string(r)))
+			}
+
+			p.tokens = append(p.tokens, filterToken{kind: filterOperator, text: op, pos: i})
+			i += len(op)
+		default:
+			start := i
+
+			for i < len(s) {
+				wr, size := utf8.DecodeRuneInString(s[i:])
+
+				if !isFilterWordRune(wr) {
+					break
+				}
+
+				i += size
+			}
+
+			if i == start {
+				return p.errorAt(filterToken{pos: i}, fmt.Sprintf("unexpected character %q", r))
+			}
+
+			p.tokens = append(p.tokens, filterToken{kind: filterWord, text: s[start:i], value: s[start:i], pos: start})
+		}
+	}
+
+	p.tokens = append(p.tokens, filterToken{kind: filterEOF, pos: len(s)})
+
+	return nil
+}
+
+func lexFilterOperator(s string) string {
+	for _, op := range []string{"==", "!=", "!~", "<=", ">=", "&&", "||", "=", "~", "<", ">", "!"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+
+	return ""
+}
+
+// lexFilterString reads a quoted string starting at s[start], returning the end offset and unquoted value
+func lexFilterString(s string, start int) (int, string, error) {
+	quote := s[start]
+	var value strings.Builder
+
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case quote:
+			return i + 1, value.String(), nil
+		case '\\':
+			if i+1 == len(s) {
+				return 0, "", fmt.Errorf("unterminated string")
+			}
+
+			i++
+
+			switch s[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			default:
+				value.WriteByte(s[i])
+			}
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+
+	return 0, "", fmt.Errorf("unterminated string")
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.next]
+}
+
+func (p *filterParser) take() filterToken {
+	tok := p.tokens[p.next]
+
+	if tok.kind != filterEOF {
+		p.next++
+	}
+
+	return tok
+}
+
+func (p *filterParser) expect(kind filterTokenKind, what string) (filterToken, error) {
+	tok := p.take()
+
+	if tok.kind != kind {
+		return tok, p.errorAt(tok, fmt.Sprintf("expected %s but found %s", what, tok))
+	}
+
+	return tok, nil
+}
+
+func (p *filterParser) parseOr() (filterFunc, error) {
+	left, err := p.parseAnd()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for tok := p.peek(); tok.keyword() == "or" || (tok.kind == filterOperator && tok.text == "||"); tok = p.peek() {
+		p.take()
+		right, err := p.parseAnd()
+
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(t *filterTarget) bool { return l(t) || right(t) }
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterFunc, error) {
+	left, err := p.parseNot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for tok := p.peek(); tok.keyword() == "and" || (tok.kind == filterOperator && tok.text == "&&"); tok = p.peek() {
+		p.take()
+		right, err := p.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(t *filterTarget) bool { return l(t) && right(t) }
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterFunc, error) {
+	if tok := p.peek(); tok.keyword() == "not" || (tok.kind == filterOperator && tok.text == "!") {
+		p.take()
+		inner, err := p.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return func(t *filterTarget) bool { return !inner(t) }, nil
+	}
+
+	if p.peek().kind == filterLParen {
+		p.take()
+		inner, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(filterRParen, `")"`); err != nil {
+			return nil, err
+		}
+
+		return inner, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseField() (filterAccessor, error) {
+	tok, err := p.expect(filterWord, "a field name")
+
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(tok.text)
+
+	// Lookup call form: parameter("NAME")
+	if p.peek().kind == filterLParen {
+		lookup, ok := filterLookups[name]
+
+		if !ok {
+			return nil, p.errorAt(tok, fmt.Sprintf("unknown lookup %q", tok.text))
+		}
+
+		p.take()
+		arg := p.take()
+
+		if arg.kind != filterString && arg.kind != filterWord {
+			return nil, p.errorAt(arg, fmt.Sprintf("expected a name but found %s", arg))
+		}
+
+		if _, err := p.expect(filterRParen, `")"`); err != nil {
+			return nil, err
+		}
+
+		return lookup(arg.value), nil
+	}
+
+	// Lookup dotted form: parameter.NAME
+	if dot := strings.Index(tok.text, "."); dot >= 0 {
+		lookup, ok := filterLookups[strings.ToLower(tok.text[:dot])]
+
+		if !ok || dot == len(tok.text)-1 {
+			return nil, p.errorAt(tok, fmt.Sprintf("unknown field %q", tok.text))
+		}
+
+		return lookup(tok.text[dot+1:]), nil
+	}
+
+	accessor, ok := filterFields[name]
+
+	if !ok {
+		return nil, p.errorAt(tok, fmt.Sprintf("unknown field %q", tok.text))
+	}
+
+	return accessor, nil
+}
+
+func (p *filterParser) parseValue() (string, error) {
+	tok := p.take()
+
+	if tok.kind != filterString && tok.kind != filterWord {
+		return "", p.errorAt(tok, fmt.Sprintf("expected a value but found %s", tok))
+	}
+
+	return tok.value, nil
+}
+
+func (p *filterParser) parseComparison() (filterFunc, error) {
+	field, err := p.parseField()
+
+	if err != nil {
+		return nil, err
+	}
+
+	opTok := p.take()
+	op := opTok.text
+
+	if opTok.kind == filterWord {
+		op = opTok.keyword()
+	} else if opTok.kind != filterOperator {
+		return nil, p.errorAt(opTok, fmt.Sprintf("expected an operator but found %s", opTok))
+	}
+
+	if op == "in" {
+		return p.parseIn(field)
+	}
+
+	valueTok := p.peek()
+	value, err := p.parseValue()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "=", "==":
+		return anyValue(field, func(v string) bool { return v == value }), nil
+	case "!=":
+		return noValue(field, func(v string) bool { return v == value }), nil
+	case "startswith":
+		return anyValue(field, func(v string) bool { return strings.HasPrefix(v, value) }), nil
+	case "endswith":
+		return anyValue(field, func(v string) bool { return strings.HasSuffix(v, value) }), nil
+	case "contains":
+		return anyValue(field, func(v string) bool { return strings.Contains(v, value) }), nil
+	case "~", "!~":
+		re, err := regexp.Compile(value)
+
+		if err != nil {
+			return nil, p.errorAt(valueTok, fmt.Sprintf("invalid regular expression: %s", err))
+		}
+
+		if op == "!~" {
+			return noValue(field, re.MatchString), nil
+		}
+
+		return anyValue(field, re.MatchString), nil
+	case "<", "<=", ">", ">=":
+		n, err := strconv.ParseFloat(value, 64)
+
+		if err != nil {
+			return nil, p.errorAt(valueTok, fmt.Sprintf("expected a number but found %s", valueTok))
+		}
+
+		return anyValue(field, numericComparison(op, n)), nil
+	}
+
+	return nil, p.errorAt(opTok, fmt.Sprintf("unknown operator %s", opTok))
+}
+
+func (p *filterParser) parseIn(field filterAccessor) (filterFunc, error) {
+	if _, err := p.expect(filterLParen, `"("`); err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool)
+
+	for {
+		value, err := p.parseValue()
+
+		if err != nil {
+			return nil, err
+		}
+
+		set[value] = true
+
+		tok := p.take()
+
+		if tok.kind == filterRParen {
+			break
+		}
+
+		if tok.kind != filterComma {
+			return nil, p.errorAt(tok, fmt.Sprintf(`expected "," or ")" but found %s`, tok))
+		}
+	}
+
+	return anyValue(field, func(v string) bool { return set[v] }), nil
+}
+
+func numericComparison(op string, n float64) func(string) bool {
+	return func(v string) bool {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+
+		if err != nil {
+			return false
+		}
+
+		switch op {
+		case "<":
+			return f < n
+		case "<=":
+			return f <= n
+		case ">":
+			return f > n
+		}
+
+		return f >= n
+	}
+}
+
+func anyValue(field filterAccessor, pred func(string) bool) filterFunc {
+	return func(t *filterTarget) bool {
+		for _, v := range field(t) {
+			if pred(v) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func noValue(field filterAccessor, pred func(string) bool) filterFunc {
+	match := anyValue(field, pred)
+
+	return func(t *filterTarget) bool {
+		return !match(t)
+	}
+}
@@ -0,0 +1,128 @@
+package cap
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFilterMatchesAlertExample(t *testing.T) {
+	alert, err := getCAPAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`event = "Flood Warning"`, true},
+		{`event = "Tornado Warning"`, false},
+		{`severity in (Moderate, Severe, Extreme)`, true},
+		{`severity in (Severe, Extreme)`, false},
+		{`event ~ "^Flood"`, true},
+		{`event !~ "Flood"`, false},
+		{`geocode.FIPS6 startswith "005"`, true},
+		{`geocode("FIPS6") = "005147"`, true},
+		{`geocode.FIPS6 != "005147"`, false},
+		{`parameter.UGC endswith "147"`, true},
+		{`Parameter("VTEC") contains "KLZK"`, true},
+		{`eventCode.SAME = ""`, true},
+		{`status = Actual and msgType = Alert`, true},
+		{`not (status = Actual) or areaDesc contains "Jackson"`, true},
+		{`event = "Flood Warning" && !(geocode.UGC = "ARC001")`, true},
+		{`sender = "nobody" OR event = "Flood Warning"`, true},
+		{`geocode.NOTREAL = "1"`, false},
+	}
+
+	for _, test := range tests {
+		f, err := CompileFilter(test.expr)
+
+		if err != nil {
+			t.Errorf("%s: %s", test.expr, err)
+			continue
+		}
+
+		assertEqual(t, f.MatchAlert(&alert.Alert), test.expected, fmt.Sprintf("Unexpected result for %s", test.expr))
+	}
+}
+
+func TestFilterEvaluatesInfoAndAreaTogether(t *testing.T) {
+	alert := Alert{Infos: []Info{
+		{Severity: "Minor", Areas: []Area{{Description: "A"}}},
+		{Severity: "Extreme", Areas: []Area{{Description: "B"}}},
+	}}
+
+	f := MustCompileFilter(`severity = Extreme and areaDesc = A`)
+
+	assertEqual(t, f.MatchAlert(&alert), false, "Fields from different Info blocks should not be combined")
+	assertEqual(t, f.MatchInfo(&alert, &alert.Infos[1]), false, "The second Info should not match")
+}
+
+func TestFilterNumericComparison(t *testing.T) {
+	var info Info
+	info.AddParameter("magnitude", "5.4")
+	alert := Alert{Infos: []Info{info}}
+
+	assertEqual(t, MustCompileFilter(`parameter.magnitude >= 5`).MatchAlert(&alert), true, ">= should match")
+	assertEqual(t, MustCompileFilter(`parameter.magnitude < 5`).MatchAlert(&alert), false, "< should not match")
+}
+
+func TestFilterMatchesNWSAtomEntry(t *testing.T) {
+	feed, err := getNwsAtomFeedExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := MustCompileFilter(`event = "Flood Warning" and geocode.FIPS6 = "005067" and parameter.VTEC contains "FL.W"`)
+
+	assertEqual(t, f.MatchEntry(&feed.Entries[0]), true, "The first entry should match")
+	assertEqual(t, f.String(), `event = "Flood Warning" and geocode.FIPS6 = "005067" and parameter.VTEC contains "FL.W"`, "String does not match the source")
+}
+
+func TestCompileFilterReturnsPositionedErrors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		column int
+	}{
+		{`severity = `, 12},
+		{`bogus = 1`, 1},
+		{`severity in (Severe Extreme)`, 21},
+		{`(event = "x"`, 13},
+		{`event ~ "("`, 9},
+		{`event = "unterminated`, 9},
+		{`event = x extra`, 11},
+		{`headline = é and é`, 18},
+		{`severity $ 1`, 10},
+	}
+
+	for _, test := range tests {
+		_, err := CompileFilter(test.expr)
+
+		syntaxErr, ok := err.(*FilterSyntaxError)
+
+		if !ok {
+			t.Errorf("%s: expected a FilterSyntaxError but got %v", test.expr, err)
+			continue
+		}
+
+		assertEqual(t, syntaxErr.Column, test.column, fmt.Sprintf("Unexpected column for %s: %s", test.expr, err))
+	}
+}
+
+func BenchmarkFilterMatchAlert(b *testing.B) {
+	alert, err := getCAPAlertExample()
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	f := MustCompileFilter(`severity in (Severe, Extreme, Moderate) and event ~ "Flood" and geocode.FIPS6 startswith "005"`)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f.MatchAlert(&alert.Alert)
+	}
+}
@@ -0,0 +1,106 @@
+package cap
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"testing"
+)
+
+func addExampleSeeds(f *testing.F, paths ...string) {
+	for _, path := range paths {
+		xmlData, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		f.Add(xmlData)
+	}
+
+	f.Add([]byte(""))
+	f.Add([]byte("<alert"))
+	f.Add([]byte(`<!DOCTYPE a [<!ENTITY b "c">]><a>&b;</a>`))
+}
+
+func FuzzParseAlert(f *testing.F) {
+	addExampleSeeds(f, "../examples/ipaws_alert.xml")
+
+	f.Fuzz(func(t *testing.T, xmlData []byte) {
+		alert, err := ParseAlert(xmlData)
+
+		if err != nil {
+			return
+		}
+
+		// Anything that parses must be writable again
+		if _, err := xml.Marshal(alert); err != nil {
+			t.Errorf("Parsed alert cannot be marshalled: %v", err)
+		}
+
+		alert.Validate()
+		alert.GeoJSON()
+	})
+}
+
+func FuzzParseAlert11(f *testing.F) {
+	addExampleSeeds(f, "../examples/nws_alert.xml")
+
+	f.Fuzz(func(t *testing.T, xmlData []byte) {
+		alert, err := ParseAlert11(xmlData)
+
+		if err != nil {
+			return
+		}
+
+		for i := range alert.Infos {
+			alert.Infos[i].VTEC()
+		}
+	})
+}
+
+func FuzzParseAlertWithOptions(f *testing.F) {
+	addExampleSeeds(f, "../examples/ipaws_alert.xml", "../examples/nws_alert.xml")
+
+	f.Fuzz(func(t *testing.T, xmlData []byte) {
+		ParseAlertWithOptions(xmlData, ParseOptions{Strict: true})
+	})
+}
+
+func FuzzPolygon(f *testing.F) {
+	f.Add("35.1,-91.33 35.22,-91.28 35.39,-91.23 35.1,-91.33")
+	f.Add("1,1 2,2 3,3")
+	f.Add("91,0 0,0 0,0 91,0")
+	f.Add("NaN,NaN 1e308,-1e308 , ,,")
+	f.Add("NaN,NaN NaN,NaN NaN,NaN NaN,NaN")
+
+	f.Fuzz(func(t *testing.T, polygon string) {
+		ring, err := parsePolygonRing(polygon)
+
+		if err != nil {
+			return
+		}
+
+		if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+			t.Errorf("Accepted polygon %q is not a closed ring: %v", polygon, ring)
+		}
+
+		geometrySize(&Area{Polygons: []string{polygon}})
+	})
+}
+
+func FuzzParseNWSAtomFeed(f *testing.F) {
+	addExampleSeeds(f, "../examples/nws_atom.xml")
+
+	f.Fuzz(func(t *testing.T, xmlData []byte) {
+		feed, err := ParseNWSAtomFeed(xmlData)
+
+		if err != nil {
+			return
+		}
+
+		for i := range feed.Entries {
+			feed.Entries[i].VTEC()
+			feed.Entries[i].Geocode.GetValues("FIPS6")
+		}
+	})
+}
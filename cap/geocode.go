@@ -0,0 +1,425 @@
+package cap
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Geocode value names in common use besides the CAP-CP location codes
+const (
+	FIPS6ValueName  string = "FIPS6"
+	SAMEValueName   string = "SAME"
+	UGCValueName    string = "UGC"
+	EMMAIDValueName string = "EMMA_ID"
+	NUTS3ValueName  string = "NUTS3"
+)
+
+// GeocodeScheme describes a kind of geocode, identified by the valueName of the geocodes using it
+type GeocodeScheme struct {
+	// ValueName is the valueName of the scheme's geocodes, such as SAME
+	ValueName string
+
+	Description string
+
+	// Validate returns back an error if code is not a valid code of the
+	// scheme, nil when any code is accepted
+	Validate func(code string) error
+
+	// Describe returns back a short human readable description of where code
+	// is and true, or false if the code is unknown, nil when the scheme has no
+	// descriptions. Descriptions name the place where the scheme's data has
+	// the name, and otherwise only the enclosing state or province, such as
+	// "County 067, Arkansas".
+	Describe func(code string) (string, bool)
+
+	// Geometry looks up the boundaries of codes, nil when no dataset is attached
+	Geometry GeometrySource
+}
+
+// GeometrySource looks up the boundary of a geocode
+type GeometrySource interface {
+	// Geometry returns back the boundary of code and true, or false if the source does not have it
+	Geometry(code string) (MultiPolygon, bool)
+}
+
+// GeometrySet is a GeometrySource held in memory, keyed by code
+type GeometrySet map[string]MultiPolygon
+
+// Geometry returns back the boundary of code and true, or false if the set does not have it
+func (s GeometrySet) Geometry(code string) (MultiPolygon, bool) {
+	geometry, ok := s[code]
+
+	return geometry, ok
+}
+
+var (
+	geocodeRegistryMu sync.RWMutex
+	geocodeRegistry   = make(map[string]GeocodeScheme)
+)
+
+var (
+	//go:embed us_states.csv
+	usStatesCSV []byte
+
+	usStatesOnce     sync.Once
+	usStatesByFIPS   map[string]string
+	usStatesByPostal map[string]string
+)
+
+var (
+	countyCodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+	ugcPattern        = regexp.MustCompile(`^[A-Z]{2}[CZ][0-9]{3}$`)
+	emmaIDPattern     = regexp.MustCompile(`^[A-Z]{2}[0-9]{3}$`)
+	nuts3Pattern      = regexp.MustCompile(`^[A-Z]{2}[0-9A-Z]{3}$`)
+)
+
+func init() {
+	RegisterProfile(GeocodeProfile)
+
+	RegisterGeocodeScheme(GeocodeScheme{
+		ValueName:   FIPS6ValueName,
+		Description: "US county, as a state and county FIPS code prefixed with 0",
+		Validate:    patternValidator(countyCodePattern, "FIPS6"),
+		Describe:    describeCountyCode,
+	})
+
+	RegisterGeocodeScheme(GeocodeScheme{
+		ValueName:   SAMEValueName,
+		Description: "US county or part of one, as a SAME (PSSCCC) location code",
+		Validate:    patternValidator(countyCodePattern, "SAME"),
+		Describe:    describeCountyCode,
+	})
+
+	RegisterGeocodeScheme(GeocodeScheme{
+		ValueName:   UGCValueName,
+		Description: "NWS Universal Geographic Code for a county (C) or forecast zone (Z)",
+		Validate:    patternValidator(ugcPattern, "UGC"),
+		Describe:    describeUGC,
+	})
+
+	RegisterGeocodeScheme(GeocodeScheme{
+		ValueName:   EMMAIDValueName,
+		Description: "Meteoalarm warning region",
+		Validate:    patternValidator(emmaIDPattern, "EMMA_ID"),
+	})
+
+	RegisterGeocodeScheme(GeocodeScheme{
+		ValueName:   NUTS3ValueName,
+		Description: "Eurostat NUTS level 3 region",
+		Validate:    patternValidator(nuts3Pattern, "NUTS3"),
+	})
+
+	RegisterGeocodeScheme(GeocodeScheme{
+		ValueName:   CAPCPLocationValueName,
+		Description: "Statistics Canada Standard Geographical Classification code",
+		Validate: func(code string) error {
+			_, err := LookupSGC(code)
+			return err
+		},
+		Describe: func(code string) (string, bool) {
+			location, err := LookupSGC(code)
+
+			if err != nil {
+				return "", false
+			}
+
+			if location.Division != "" {
+				return location.Division + ", " + location.ProvinceAbbreviation, true
+			}
+
+			return location.Province, true
+		},
+	})
+}
+
+// RegisterGeocodeScheme makes a geocode scheme available to LookupGeocodeScheme
+//
+// Value names are matched case-insensitively. RegisterGeocodeScheme panics if
+// a scheme with the same value name is already registered.
+func RegisterGeocodeScheme(scheme GeocodeScheme) {
+	geocodeRegistryMu.Lock()
+	defer geocodeRegistryMu.Unlock()
+
+	key := strings.ToLower(scheme.ValueName)
+
+	if _, exists := geocodeRegistry[key]; exists {
+		panic(fmt.Sprintf("cap: geocode scheme %q is already registered", scheme.ValueName))
+	}
+
+	geocodeRegistry[key] = scheme
+}
+
+// LookupGeocodeScheme returns back the registered scheme with the given value name
+func LookupGeocodeScheme(valueName string) (GeocodeScheme, bool) {
+	geocodeRegistryMu.RLock()
+	defer geocodeRegistryMu.RUnlock()
+
+	scheme, ok := geocodeRegistry[strings.ToLower(strings.TrimSpace(valueName))]
+
+	return scheme, ok
+}
+
+// GeocodeSchemes returns back the value names of the registered schemes, sorted
+func GeocodeSchemes() []string {
+	geocodeRegistryMu.RLock()
+	defer geocodeRegistryMu.RUnlock()
+
+	names := make([]string, 0, len(geocodeRegistry))
+
+	for _, scheme := range geocodeRegistry {
+		names = append(names, scheme.ValueName)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// SetGeocodeGeometry attaches a source of boundaries to a registered scheme,
+// replacing any attached before, or detaches it when source is nil
+func SetGeocodeGeometry(valueName string, source GeometrySource) error {
+	geocodeRegistryMu.Lock()
+	defer geocodeRegistryMu.Unlock()
+
+	key := strings.ToLower(strings.TrimSpace(valueName))
+	scheme, ok := geocodeRegistry[key]
+
+	if !ok {
+		return fmt.Errorf("Unknown geocode scheme: %q", valueName)
+	}
+
+	scheme.Geometry = source
+	geocodeRegistry[key] = scheme
+
+	return nil
+}
+
+// DescribeGeocode returns back the human readable description of a geocode, if
+// its scheme is registered and describes it, see GeocodeScheme.Describe
+func DescribeGeocode(valueName, code string) (string, bool) {
+	scheme, ok := LookupGeocodeScheme(valueName)
+
+	if !ok || scheme.Describe == nil {
+		return "", false
+	}
+
+	return scheme.Describe(strings.TrimSpace(code))
+}
+
+// GeocodeProfile checks every geocode of a registered scheme is a valid code of that scheme
+//
+// Geocodes of unregistered schemes are not checked.
+var GeocodeProfile Profile = NewProfile("Geocodes", func(alert *Alert, errs *ValidationErrors) {
+	for i := range alert.Infos {
+		for j := range alert.Infos[i].Areas {
+			for k, geocode := range alert.Infos[i].Areas[j].Geocodes {
+				scheme, ok := LookupGeocodeScheme(geocode.ValueName)
+
+				if !ok || scheme.Validate == nil {
+					continue
+				}
+
+				if err := scheme.Validate(strings.TrimSpace(geocode.Value)); err != nil {
+					errs.Add(fmt.Sprintf("info[%d].area[%d].geocode[%d]", i, j, k), "%s", err)
+				}
+			}
+		}
+	}
+})
+
+// geocodeGeometry returns back the boundaries of the area's geocodes, either
+// filled in by a GeometryResolver or found in the geometry sources of their schemes
+//
+// The boundaries are only a fallback: nil is returned for areas with a valid
+// polygon or circle, since their own geometry is more precise.
+func (area *Area) geocodeGeometry() MultiPolygon {
+	if area.hasShape() {
+		return nil
+	}
+
+	if area.ResolvedGeometry != nil {
+		return area.ResolvedGeometry
+	}
+
+	var geometry MultiPolygon
+
+	for _, geocode := range area.Geocodes {
+		scheme, ok := LookupGeocodeScheme(geocode.ValueName)
+
+		if !ok || scheme.Geometry == nil {
+			continue
+		}
+
+		if boundary, ok := scheme.Geometry.Geometry(strings.TrimSpace(geocode.Value)); ok {
+			geometry = append(geometry, boundary...)
+		}
+	}
+
+	return geometry
+}
+
+// hasShape returns back true if the area has a valid polygon or circle
+func (area *Area) hasShape() bool {
+	for _, polygon := range area.polygons() {
+		if _, err := parsePolygonRing(polygon); err == nil {
+			return true
+		}
+	}
+
+	for _, circle := range area.circles() {
+		if _, _, err := parseCircle(circle); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadGeoJSONGeometries reads the Polygon and MultiPolygon features of a
+// GeoJSON FeatureCollection into a GeometrySet, keyed by the named property
+//
+// The property may be a string or a number. Features without it, or with other
+// geometry types, are skipped, and features sharing a code are merged.
+func ReadGeoJSONGeometries(r io.Reader, property string) (GeometrySet, error) {
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry *struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+
+	d := json.NewDecoder(r)
+	d.UseNumber()
+
+	if err := d.Decode(&collection); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("Invalid GeoJSON type: %q, expected FeatureCollection", collection.Type)
+	}
+
+	set := make(GeometrySet)
+
+	for i, feature := range collection.Features {
+		code := geoJSONPropertyString(feature.Properties[property])
+
+		if code == "" || feature.Geometry == nil {
+			continue
+		}
+
+		var geometry MultiPolygon
+
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon Polygon
+
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("Invalid coordinates in feature %d: %s", i, err)
+			}
+
+			geometry = MultiPolygon{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &geometry); err != nil {
+				return nil, fmt.Errorf("Invalid coordinates in feature %d: %s", i, err)
+			}
+		default:
+			continue
+		}
+
+		set[code] = append(set[code], geometry...)
+	}
+
+	return set, nil
+}
+
+// geoJSONPropertyString returns back a string or number property as a string
+func geoJSONPropertyString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	default:
+		return ""
+	}
+}
+
+// patternValidator returns back a GeocodeScheme.Validate function matching codes against pattern
+func patternValidator(pattern *regexp.Regexp, scheme string) func(string) error {
+	return func(code string) error {
+		if !pattern.MatchString(code) {
+			return fmt.Errorf("Invalid %s code: %q", scheme, code)
+		}
+
+		return nil
+	}
+}
+
+func loadUSStates() {
+	usStatesOnce.Do(func() {
+		usStatesByFIPS = make(map[string]string)
+		usStatesByPostal = make(map[string]string)
+
+		for _, row := range readEmbeddedCSV(usStatesCSV) {
+			usStatesByFIPS[row[0]] = row[2]
+			usStatesByPostal[row[1]] = row[2]
+		}
+	})
+}
+
+// describeCountyCode names the state of a FIPS6 or SAME code along with its
+// county number, such as "County 067, Arkansas", since county names are not embedded
+func describeCountyCode(code string) (string, bool) {
+	if !countyCodePattern.MatchString(code) {
+		return "", false
+	}
+
+	loadUSStates()
+
+	state, ok := usStatesByFIPS[code[1:3]]
+
+	if !ok {
+		return "", false
+	}
+
+	if code[3:] == "000" {
+		return state, true
+	}
+
+	return fmt.Sprintf("County %s, %s", code[3:], state), true
+}
+
+// describeUGC names the state of a UGC code along with its county or zone
+// number, such as "Zone 010, Arizona"
+func describeUGC(code string) (string, bool) {
+	if !ugcPattern.MatchString(code) {
+		return "", false
+	}
+
+	loadUSStates()
+
+	state, ok := usStatesByPostal[code[:2]]
+
+	if !ok {
+		return "", false
+	}
+
+	kind := "County"
+
+	if code[2] == 'Z' {
+		kind = "Zone"
+	}
+
+	return fmt.Sprintf("%s %s, %s", kind, code[3:], state), true
+}
@@ -0,0 +1,194 @@
+package cap
+
+import (
+	"strings"
+	"testing"
+)
+
+const geocodeTestGeoJSON string = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"properties": {"UGC": "ARC067"},
+			"geometry": {"type": "Polygon", "coordinates": [[[-91.5, 35.4], [-91.0, 35.4], [-91.0, 35.9], [-91.5, 35.9], [-91.5, 35.4]]]}
+		},
+		{
+			"type": "Feature",
+			"properties": {"UGC": "ARC147"},
+			"geometry": {"type": "MultiPolygon", "coordinates": [[[[-91.5, 34.9], [-91.0, 34.9], [-91.0, 35.4], [-91.5, 35.4], [-91.5, 34.9]]]]}
+		},
+		{
+			"type": "Feature",
+			"properties": {"UGC": "ARC999"},
+			"geometry": {"type": "Point", "coordinates": [-91.0, 35.0]}
+		},
+		{
+			"type": "Feature",
+			"properties": {},
+			"geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}
+		}
+	]
+}`
+
+func TestBuiltInGeocodeSchemesAreRegistered(t *testing.T) {
+	names := GeocodeSchemes()
+
+	for _, name := range []string{"FIPS6", "SAME", "UGC", "EMMA_ID", "NUTS3", CAPCPLocationValueName} {
+		assertIn(t, name, names, name+" should be registered")
+	}
+
+	scheme, ok := LookupGeocodeScheme("same")
+
+	assertEqual(t, ok, true, "Lookup should ignore case")
+	assertEqual(t, scheme.ValueName, "SAME", "Wrong scheme returned")
+}
+
+func TestGeocodeSchemeValidators(t *testing.T) {
+	cases := []struct {
+		valueName string
+		code      string
+		valid     bool
+	}{
+		{"SAME", "005067", true},
+		{"SAME", "5067", false},
+		{"FIPS6", "005147", true},
+		{"UGC", "ARC067", true},
+		{"UGC", "AZZ010", true},
+		{"UGC", "ARC067-147", false},
+		{"EMMA_ID", "FR433", true},
+		{"EMMA_ID", "FR43", false},
+		{"NUTS3", "UKI31", true},
+		{"NUTS3", "DE1", false},
+		{CAPCPLocationValueName, "4806", true},
+		{CAPCPLocationValueName, "9906", false},
+	}
+
+	for _, c := range cases {
+		scheme, _ := LookupGeocodeScheme(c.valueName)
+		err := scheme.Validate(c.code)
+
+		assertEqual(t, err == nil, c.valid, "Unexpected result validating "+c.valueName+" "+c.code)
+	}
+}
+
+func TestDescribeGeocode(t *testing.T) {
+	cases := map[[2]string]string{
+		{"FIPS6", "005067"}:                 "County 067, Arkansas",
+		{"SAME", "072000"}:                  "Puerto Rico",
+		{"UGC", "AZZ010"}:                   "Zone 010, Arizona",
+		{"UGC", "ARC147"}:                   "County 147, Arkansas",
+		{CAPCPLocationValueName, "48"}:      "Alberta",
+		{CAPCPLocationValueName, "4806001"}: "Division No. 6, AB",
+	}
+
+	for geocode, expected := range cases {
+		description, ok := DescribeGeocode(geocode[0], geocode[1])
+
+		assertEqual(t, ok, true, "The geocode should have a description: "+geocode[1])
+		assertEqual(t, description, expected, "Unexpected description")
+	}
+
+	_, ok := DescribeGeocode("NUTS3", "UKI31")
+
+	assertEqual(t, ok, false, "NUTS3 has no descriptions")
+
+	_, ok = DescribeGeocode("UGC", "XXZ010")
+
+	assertEqual(t, ok, false, "Unknown states have no descriptions")
+}
+
+func TestGeocodeProfileReportsInvalidCodes(t *testing.T) {
+	alert := Alert{Infos: []Info{{Areas: []Area{{Geocodes: []NamedValue{
+		{ValueName: "UGC", Value: "ARC067"},
+		{ValueName: "UGC", Value: "ARC067-147"},
+		{ValueName: "LOCAL", Value: "anything"},
+	}}}}}}
+
+	var errs ValidationErrors
+
+	GeocodeProfile.Validate(&alert, &errs)
+
+	assertEqual(t, len(errs), 1, "Only the invalid UGC code should be reported")
+	assertEqual(t, errs[0].Error(), `info[0].area[0].geocode[1]: Invalid UGC code: "ARC067-147"`, "Unexpected error")
+
+	_, ok := LookupProfile("geocodes")
+
+	assertEqual(t, ok, true, "The profile should be registered")
+}
+
+func TestReadGeoJSONGeometries(t *testing.T) {
+	set, err := ReadGeoJSONGeometries(strings.NewReader(geocodeTestGeoJSON), "UGC")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(set), 2, "Only polygon features with the property should be read")
+	assertEqual(t, len(set["ARC147"]), 1, "The multipolygon should be read")
+	assertEqual(t, set["ARC067"][0][0][1], [2]float64{-91.0, 35.4}, "Positions should be [longitude, latitude]")
+
+	_, err = ReadGeoJSONGeometries(strings.NewReader(`{"type": "Feature"}`), "UGC")
+
+	assertStartsWith(t, err.Error(), "Invalid GeoJSON type", "Only feature collections should be accepted")
+}
+
+func TestGeocodeOnlyAreaUsesAttachedGeometry(t *testing.T) {
+	set, err := ReadGeoJSONGeometries(strings.NewReader(geocodeTestGeoJSON), "UGC")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	area := Area{Description: "Jackson; Woodruff", Geocodes: []NamedValue{{ValueName: "UGC", Value: "ARC067"}}}
+
+	assertEqual(t, area.Contains(35.6, -91.2), false, "Without geometry the area should contain nothing")
+
+	if err := SetGeocodeGeometry("UGC", set); err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetGeocodeGeometry("UGC", nil)
+
+	assertEqual(t, len(area.Geometry()), 1, "The geocode boundary should be the geometry")
+	assertEqual(t, area.Contains(35.6, -91.2), true, "The point should be inside the county")
+	assertEqual(t, area.Contains(35.1, -91.2), false, "The point should be outside the county")
+
+	err = SetGeocodeGeometry("NOPE", set)
+
+	assertEqual(t, err.Error(), `Unknown geocode scheme: "NOPE"`, "Unknown schemes should be reported")
+}
+
+func TestAreaWithShapesIgnoresGeocodeGeometry(t *testing.T) {
+	set, err := ReadGeoJSONGeometries(strings.NewReader(geocodeTestGeoJSON), "UGC")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetGeocodeGeometry("UGC", set); err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetGeocodeGeometry("UGC", nil)
+
+	geocodes := []NamedValue{{ValueName: "UGC", Value: "ARC067"}}
+	area := Area{Polygons: []string{"10,10 10,11 11,11 10,10"}, Geocodes: geocodes}
+
+	assertEqual(t, len(area.Geometry()), 1, "Only the polygon should be the geometry")
+	assertEqual(t, area.Contains(35.6, -91.2), false, "The county should not widen the polygon")
+
+	alert := &Alert{MessageID: "1", Infos: []Info{{Areas: []Area{area}}}}
+
+	assertEqual(t, alert.GeoJSON().Features[0].Geometry.Type, "Polygon", "The GeoJSON should only hold the polygon")
+
+	idx := NewSpatialIndex()
+	idx.Insert(alert)
+
+	assertEqual(t, len(idx.Query(35.6, -91.2)), 0, "The index should not find the county")
+
+	area = Area{Polygons: []string{"10,10"}, Circles: []string{"bad"}, Geocodes: geocodes}
+
+	assertEqual(t, len(area.Geometry()), 1, "Invalid shapes should fall back to the county")
+	assertEqual(t, area.Contains(35.6, -91.2), true, "The point should be inside the county")
+}
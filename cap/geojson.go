@@ -0,0 +1,158 @@
+package cap
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// GeoJSONFeatureCollection is a GeoJSON (RFC 7946) FeatureCollection
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature is a GeoJSON Feature
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *GeoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONGeometry is a GeoJSON Point, Polygon, MultiPolygon or GeometryCollection
+type GeoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates interface{}       `json:"coordinates,omitempty"`
+	Geometries  []GeoJSONGeometry `json:"geometries,omitempty"`
+}
+
+// GeoJSON returns back a FeatureCollection with a Feature for every area of every Info block
+//
+// Polygons become GeoJSON Polygons with [longitude, latitude] positions.
+// Circles become Points with a "radius" property in kilometers, since GeoJSON
+// has no circle geometry. For areas with neither, the boundaries of geocodes,
+// when a GeometryResolver has filled them in or their scheme has a geometry
+// source, become a MultiPolygon. Areas with several of these are GeometryCollections, and areas
+// without any have a null geometry. Each Feature carries the event, severity,
+// urgency, certainty, headline, dates, area description and geocodes of its
+// Info block and area.
+func (alert *Alert) GeoJSON() *GeoJSONFeatureCollection {
+	collection := &GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+
+	for i := range alert.Infos {
+		info := &alert.Infos[i]
+
+		for j := range info.Areas {
+			area := &info.Areas[j]
+			properties := map[string]interface{}{
+				"identifier": alert.MessageID,
+				"sender":     alert.SenderID,
+				"sent":       alert.SentDate,
+				"event":      info.EventType,
+				"urgency":    info.Urgency,
+				"severity":   info.Severity,
+				"certainty":  info.Certainty,
+				"areaDesc":   area.Description,
+			}
+
+			for name, value := range map[string]string{
+				"language":  info.Language,
+				"headline":  info.Headline,
+				"effective": info.EffectiveDate,
+				"onset":     info.OnsetDate,
+				"expires":   info.ExpiresDate,
+			} {
+				if value != "" {
+					properties[name] = value
+				}
+			}
+
+			if len(area.Geocodes) > 0 {
+				geocodes := make(map[string][]string)
+
+				for _, g := range area.Geocodes {
+					geocodes[g.ValueName] = append(geocodes[g.ValueName], g.Value)
+				}
+
+				properties["geocode"] = geocodes
+			}
+
+			var geometries []GeoJSONGeometry
+
+			for _, polygon := range area.polygons() {
+				if ring, err := parsePolygonRing(polygon); err == nil {
+					geometries = append(geometries, GeoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{ring}})
+				}
+			}
+
+			var radii []float64
+
+			for _, circle := range area.circles() {
+				if center, radius, err := parseCircle(circle); err == nil {
+					geometries = append(geometries, GeoJSONGeometry{Type: "Point", Coordinates: center})
+					radii = append(radii, radius)
+				}
+			}
+
+			switch len(radii) {
+			case 0:
+			case 1:
+				properties["radius"] = radii[0]
+			default:
+				properties["radius"] = radii
+			}
+
+			if boundaries := area.geocodeGeometry(); len(boundaries) > 0 {
+				geometries = append(geometries, GeoJSONGeometry{Type: "MultiPolygon", Coordinates: boundaries})
+			}
+
+			feature := GeoJSONFeature{Type: "Feature", Properties: properties}
+
+			switch len(geometries) {
+			case 0:
+			case 1:
+				feature.Geometry = &geometries[0]
+			default:
+				feature.Geometry = &GeoJSONGeometry{Type: "GeometryCollection", Geometries: geometries}
+			}
+
+			collection.Features = append(collection.Features, feature)
+		}
+	}
+
+	return collection
+}
+
+// MarshalGeoJSON returns back the GeoJSON encoding of the alert's areas
+func (alert *Alert) MarshalGeoJSON() ([]byte, error) {
+	return json.Marshal(alert.GeoJSON())
+}
+
+// parsePolygonRing parses a valid CAP polygon into [longitude, latitude] positions
+func parsePolygonRing(value string) (Ring, error) {
+	if err := validatePolygon(value); err != nil {
+		return nil, err
+	}
+
+	var ring Ring
+
+	for _, pair := range strings.Fields(value) {
+		lat, lon, _ := parseCoordinatePair(pair)
+		ring = append(ring, [2]float64{lon, lat})
+	}
+
+	return ring, nil
+}
+
+// parseCircle parses a valid CAP circle into its [longitude, latitude] center and radius
+func parseCircle(value string) ([2]float64, float64, error) {
+	if err := validateCircle(value); err != nil {
+		return [2]float64{}, 0, err
+	}
+
+	fields := strings.Fields(value)
+	lat, lon, _ := parseCoordinatePair(fields[0])
+	radius, _ := strconv.ParseFloat(fields[1], 64)
+
+	return [2]float64{lon, lat}, radius, nil
+}
@@ -0,0 +1,57 @@
+package cap
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGeoJSONConvertsAreas(t *testing.T) {
+	alert := &Alert{
+		MessageID: "1",
+		Infos: []Info{{
+			EventType: "Flood Warning",
+			Severity:  "Moderate",
+			Areas: []Area{
+				{Description: "Polygon", Polygons: []string{"35,-91 36,-91 36,-90 35,-91"}},
+				{Description: "Circle", Circles: []string{"35,-91 10"}},
+				{Description: "Both", Polygons: []string{"35,-91 36,-91 36,-90 35,-91"}, Circles: []string{"35,-91 10"}},
+				{Description: "Geocode", Geocodes: []NamedValue{{ValueName: "SAME", Value: "005067"}}},
+			},
+		}},
+	}
+
+	collection := alert.GeoJSON()
+
+	assertEqual(t, len(collection.Features), 4, "Every area should be a feature")
+	assertEqual(t, collection.Features[0].Geometry.Type, "Polygon", "Polygon type does not match!")
+	assertEqual(t, collection.Features[1].Geometry.Type, "Point", "Circle type does not match!")
+	assertEqual(t, collection.Features[1].Properties["radius"], 10.0, "Radius does not match!")
+	assertEqual(t, collection.Features[2].Geometry.Type, "GeometryCollection", "Combined type does not match!")
+
+	if collection.Features[3].Geometry != nil {
+		t.Error("Areas without shapes should have a null geometry")
+	}
+
+	data, err := alert.MarshalGeoJSON()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Features []struct {
+			Geometry struct {
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t,
+		string(decoded.Features[0].Geometry.Coordinates),
+		"[[[-91,35],[-91,36],[-90,36],[-91,35]]]",
+		"Positions should be longitude first")
+}
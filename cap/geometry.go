@@ -0,0 +1,306 @@
+package cap
+
+import (
+	"math"
+)
+
+// Ring is a closed ring of [longitude, latitude] positions, in the same order as GeoJSON
+type Ring [][2]float64
+
+// Polygon is an outer ring followed by any holes
+type Polygon []Ring
+
+// MultiPolygon is a set of polygons describing one area
+type MultiPolygon []Polygon
+
+// BBox is a bounding box in degrees
+type BBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+// earthRadius is the mean radius of the Earth in kilometers
+const earthRadius float64 = 6371.0088
+
+// circleSegments is the number of positions used to approximate a circle
+const circleSegments int = 64
+
+// Contains returns back true if the point is inside the ring or on its boundary
+func (r Ring) Contains(lat, lon float64) bool {
+	inside := false
+
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		x1, y1 := r[i][0], r[i][1]
+		x2, y2 := r[j][0], r[j][1]
+
+		if onSegment(lon, lat, x1, y1, x2, y2) {
+			return true
+		}
+
+		if (y1 > lat) != (y2 > lat) && lon < (x2-x1)*(lat-y1)/(y2-y1)+x1 {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// SignedArea returns back the area of the ring in square degrees, positive when
+// it is wound counterclockwise and negative when clockwise
+func (r Ring) SignedArea() float64 {
+	sum := 0.0
+
+	for i := 0; i < len(r)-1; i++ {
+		sum += r[i][0]*r[i+1][1] - r[i+1][0]*r[i][1]
+	}
+
+	return sum / 2
+}
+
+// Simplify returns back the ring simplified with the Douglas-Peucker algorithm,
+// dropping positions closer than tolerance degrees to the simplified outline
+//
+// The ring is returned unchanged if simplifying it would leave fewer than four
+// positions.
+func (r Ring) Simplify(tolerance float64) Ring {
+	if tolerance <= 0 || len(r) <= 4 {
+		return r
+	}
+
+	if simplified := r.simplify(tolerance); len(simplified) >= 4 {
+		return simplified
+	}
+
+	return r
+}
+
+// simplify returns back the positions Douglas-Peucker keeps at tolerance, however few
+func (r Ring) simplify(tolerance float64) Ring {
+	keep := make([]bool, len(r))
+	keep[0], keep[len(r)-1] = true, true
+	douglasPeucker(r, 0, len(r)-1, tolerance, keep)
+
+	simplified := make(Ring, 0, len(r))
+
+	for i, pos := range r {
+		if keep[i] {
+			simplified = append(simplified, pos)
+		}
+	}
+
+	return simplified
+}
+
+// douglasPeucker marks the positions of r between first and last to keep
+func douglasPeucker(r Ring, first, last int, tolerance float64, keep []bool) {
+	index, max := -1, tolerance
+
+	for i := first + 1; i < last; i++ {
+		if d := segmentDistance(r[i], r[first], r[last]); d > max {
+			index, max = i, d
+		}
+	}
+
+	if index < 0 {
+		return
+	}
+
+	keep[index] = true
+	douglasPeucker(r, first, index, tolerance, keep)
+	douglasPeucker(r, index, last, tolerance, keep)
+}
+
+// segmentDistance returns back the planar distance from p to the segment from a to b
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+
+	if dx != 0 || dy != 0 {
+		t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/(dx*dx+dy*dy)))
+	}
+
+	return math.Hypot(p[0]-a[0]-t*dx, p[1]-a[1]-t*dy)
+}
+
+// Simplify returns back the polygons with every ring simplified, see Ring.Simplify
+func (m MultiPolygon) Simplify(tolerance float64) MultiPolygon {
+	simplified := make(MultiPolygon, len(m))
+
+	for i, p := range m {
+		simplified[i] = make(Polygon, len(p))
+
+		for j, ring := range p {
+			simplified[i][j] = ring.Simplify(tolerance)
+		}
+	}
+
+	return simplified
+}
+
+// onSegment returns back true if (x, y) lies on the segment from (x1, y1) to (x2, y2)
+func onSegment(x, y, x1, y1, x2, y2 float64) bool {
+	if (x2-x1)*(y-y1)-(y2-y1)*(x-x1) != 0 {
+		return false
+	}
+
+	return math.Min(x1, x2) <= x && x <= math.Max(x1, x2) && math.Min(y1, y2) <= y && y <= math.Max(y1, y2)
+}
+
+// Contains returns back true if the point is inside the outer ring and outside every hole
+func (p Polygon) Contains(lat, lon float64) bool {
+	if len(p) == 0 || !p[0].Contains(lat, lon) {
+		return false
+	}
+
+	for _, hole := range p[1:] {
+		if hole.Contains(lat, lon) && !onRing(hole, lat, lon) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// onRing returns back true if the point lies on the boundary of the ring
+func onRing(r Ring, lat, lon float64) bool {
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		if onSegment(lon, lat, r[i][0], r[i][1], r[j][0], r[j][1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Contains returns back true if any of the polygons contains the point
+func (m MultiPolygon) Contains(lat, lon float64) bool {
+	for _, p := range m {
+		if p.Contains(lat, lon) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bounds returns back the bounding box of the polygons' outer rings, and false if they are empty
+func (m MultiPolygon) Bounds() (BBox, bool) {
+	box := BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	found := false
+
+	for _, p := range m {
+		if len(p) == 0 {
+			continue
+		}
+
+		for _, pos := range p[0] {
+			box = box.extend(pos[0], pos[1])
+			found = true
+		}
+	}
+
+	return box, found
+}
+
+// Contains returns back true if the point is inside the box or on its edge
+func (b BBox) Contains(lat, lon float64) bool {
+	return b.MinLat <= lat && lat <= b.MaxLat && b.MinLon <= lon && lon <= b.MaxLon
+}
+
+// Intersects returns back true if the boxes overlap or touch
+func (b BBox) Intersects(other BBox) bool {
+	return b.MinLon <= other.MaxLon && other.MinLon <= b.MaxLon && b.MinLat <= other.MaxLat && other.MinLat <= b.MaxLat
+}
+
+// Union returns back the smallest box containing both boxes
+func (b BBox) Union(other BBox) BBox {
+	return BBox{
+		MinLon: math.Min(b.MinLon, other.MinLon),
+		MinLat: math.Min(b.MinLat, other.MinLat),
+		MaxLon: math.Max(b.MaxLon, other.MaxLon),
+		MaxLat: math.Max(b.MaxLat, other.MaxLat),
+	}
+}
+
+func (b BBox) extend(lon, lat float64) BBox {
+	return b.Union(BBox{MinLon: lon, MinLat: lat, MaxLon: lon, MaxLat: lat})
+}
+
+// distance returns back the great circle distance between two points in kilometers
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dPhi := phi2 - phi1
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// circleRing approximates a circle of radius kilometers around a [longitude, latitude] center
+func circleRing(center [2]float64, radius float64, segments int) Ring {
+	lat1 := center[1] * math.Pi / 180
+	lon1 := center[0] * math.Pi / 180
+	d := radius / earthRadius
+	ring := make(Ring, 0, segments+1)
+
+	for i := 0; i < segments; i++ {
+		bearing := 2 * math.Pi * float64(i) / float64(segments)
+		lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(bearing))
+		lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+
+		ring = append(ring, [2]float64{math.Remainder(lon2*180/math.Pi, 360), lat2 * 180 / math.Pi})
+	}
+
+	return append(ring, ring[0])
+}
+
+// Geometry returns back the union of the area's polygons and circles, or the
+// boundaries of its geocodes if it has neither
+//
+// Circles are approximated by 64-sided polygons. Geocodes are only used for
+// areas without a valid polygon or circle, since those are more precise than
+// the boundaries of the counties or zones they touch. They contribute the
+// ResolvedGeometry filled in by a GeometryResolver, or otherwise the boundaries
+// from the geometry sources attached to their schemes with SetGeocodeGeometry.
+// Codes missing from the sources are skipped, as are invalid polygons and
+// circles.
+func (area *Area) Geometry() MultiPolygon {
+	var geometry MultiPolygon
+
+	for _, polygon := range area.polygons() {
+		if ring, err := parsePolygonRing(polygon); err == nil {
+			geometry = append(geometry, Polygon{ring})
+		}
+	}
+
+	for _, circle := range area.circles() {
+		if center, radius, err := parseCircle(circle); err == nil {
+			geometry = append(geometry, Polygon{circleRing(center, radius, circleSegments)})
+		}
+	}
+
+	return append(geometry, area.geocodeGeometry()...)
+}
+
+// Contains returns back true if the point is inside one of the area's
+// polygons or circles, or the boundaries of its geocodes if it has neither
+//
+// Circles are checked exactly using the great circle distance from their center.
+func (area *Area) Contains(lat, lon float64) bool {
+	for _, polygon := range area.polygons() {
+		if ring, err := parsePolygonRing(polygon); err == nil && ring.Contains(lat, lon) {
+			return true
+		}
+	}
+
+	for _, circle := range area.circles() {
+		if center, radius, err := parseCircle(circle); err == nil && distance(center[1], center[0], lat, lon) <= radius {
+			return true
+		}
+	}
+
+	return area.geocodeGeometry().Contains(lat, lon)
+}
@@ -0,0 +1,76 @@
+package cap
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRingContains(t *testing.T) {
+	ring := Ring{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+
+	assertEqual(t, ring.Contains(5, 5), true, "The center should be inside")
+	assertEqual(t, ring.Contains(0, 5), true, "The edge should be inside")
+	assertEqual(t, ring.Contains(10, 10), true, "A corner should be inside")
+	assertEqual(t, ring.Contains(11, 5), false, "Points above should be outside")
+	assertEqual(t, ring.Contains(5, -1), false, "Points to the west should be outside")
+}
+
+func TestPolygonContainsExcludesHoles(t *testing.T) {
+	polygon := Polygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}},
+	}
+
+	assertEqual(t, polygon.Contains(2, 2), true, "Points outside the hole should be inside")
+	assertEqual(t, polygon.Contains(5, 5), false, "Points in the hole should be outside")
+	assertEqual(t, polygon.Contains(4, 5), true, "The edge of the hole should be inside")
+}
+
+func TestMultiPolygonBounds(t *testing.T) {
+	m := MultiPolygon{
+		{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+		{{{-5, 2}, {-4, 2}, {-4, 3}, {-5, 2}}},
+	}
+
+	box, ok := m.Bounds()
+
+	assertEqual(t, ok, true, "The bounds should be found")
+	assertEqual(t, box, BBox{MinLon: -5, MinLat: 0, MaxLon: 1, MaxLat: 3}, "Unexpected bounds")
+
+	_, ok = MultiPolygon{}.Bounds()
+
+	assertEqual(t, ok, false, "Empty geometry has no bounds")
+}
+
+func TestBBoxIntersects(t *testing.T) {
+	a := BBox{MinLon: 0, MinLat: 0, MaxLon: 2, MaxLat: 2}
+
+	assertEqual(t, a.Intersects(BBox{MinLon: 1, MinLat: 1, MaxLon: 3, MaxLat: 3}), true, "Overlapping boxes intersect")
+	assertEqual(t, a.Intersects(BBox{MinLon: 2, MinLat: 2, MaxLon: 3, MaxLat: 3}), true, "Touching boxes intersect")
+	assertEqual(t, a.Intersects(BBox{MinLon: 3, MinLat: 0, MaxLon: 4, MaxLat: 2}), false, "Separate boxes do not intersect")
+}
+
+func TestCircleRingRadius(t *testing.T) {
+	ring := circleRing([2]float64{-97.5, 35.5}, 10, circleSegments)
+
+	assertEqual(t, len(ring), circleSegments+1, "The ring should be closed")
+	assertEqual(t, ring[0], ring[len(ring)-1], "The ring should be closed")
+
+	for _, pos := range ring {
+		d := distance(35.5, -97.5, pos[1], pos[0])
+
+		assertEqual(t, math.Abs(d-10) < 0.001, true, "Every position should be 10 km from the center")
+	}
+}
+
+func TestAreaContainsPolygonAndCircle(t *testing.T) {
+	area := Area{
+		Polygons: []string{"38.47,-120.14 38.34,-119.95 38.52,-119.74 38.62,-119.89 38.47,-120.14"},
+		Circles:  []string{"32.9525,-115.5527 2"},
+	}
+
+	assertEqual(t, area.Contains(38.47, -119.95), true, "The point should be inside the polygon")
+	assertEqual(t, area.Contains(32.96, -115.55), true, "The point should be inside the circle")
+	assertEqual(t, area.Contains(32.99, -115.55), false, "The point should be outside the circle")
+	assertEqual(t, len(area.Geometry()), 2, "The polygon and circle should both be included")
+}
@@ -0,0 +1,246 @@
+package cap
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// IdentifierGenerator creates identifiers for new alerts
+type IdentifierGenerator interface {
+	NewIdentifier() (string, error)
+}
+
+// Counter hands out increasing sequence numbers for identifiers
+type Counter interface {
+	Next() (uint64, error)
+}
+
+// MemoryCounter is a Counter held in memory, starting again at 1 with every process
+//
+// The zero value is ready to use and is safe for concurrent use.
+type MemoryCounter struct {
+	mu    sync.Mutex
+	value uint64
+}
+
+// Next returns back the next sequence number
+func (c *MemoryCounter) Next() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.value++
+
+	return c.value, nil
+}
+
+// FileCounter is a Counter persisted in a file, so sequence numbers are not
+// reused when the process restarts
+//
+// The file holds the last number handed out as decimal text and is replaced
+// atomically on every call. A missing file starts the sequence at 1.
+type FileCounter struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileCounter returns back a FileCounter stored at path
+func NewFileCounter(path string) *FileCounter {
+	return &FileCounter{path: path}
+}
+
+// Next returns back the next sequence number, saving it before returning
+func (c *FileCounter) Next() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var value uint64
+
+	raw, err := ioutil.ReadFile(c.path)
+
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return 0, err
+	default:
+		value, err = strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+
+		if err != nil {
+			return 0, fmt.Errorf("Invalid counter file %q: %s", c.path, err)
+		}
+	}
+
+	value++
+
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatUint(value, 10) + "\n"); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+// UUIDGenerator creates identifiers from random (version 4) UUIDs
+type UUIDGenerator struct {
+	// Prefix is put before every UUID, such as "urn:uuid:"
+	Prefix string
+}
+
+// NewIdentifier returns back the prefix followed by a new random UUID
+func (g UUIDGenerator) NewIdentifier() (string, error) {
+	var b [16]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%s%x-%x-%x-%x-%x", g.Prefix, b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// oidPattern matches an object identifier in dotted decimal form
+var oidPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)(\.(0|[1-9][0-9]*))+$`)
+
+// OIDGenerator creates identifiers under an object identifier assigned to the
+// sender, as recommended by the WMO register of alerting authorities
+//
+// Identifiers take the form urn:oid:<OID>.<yyyymmddhhmmss>.<n> using the UTC
+// time and the next value of the counter, such as
+// urn:oid:2.49.0.1.124.20150815204500.1.
+type OIDGenerator struct {
+	oid     string
+	counter Counter
+
+	// Now returns back the current time, time.Now when nil
+	Now func() time.Time
+}
+
+// NewOIDGenerator returns back an OIDGenerator for oid, numbering identifiers with counter
+func NewOIDGenerator(oid string, counter Counter) (*OIDGenerator, error) {
+	oid = strings.TrimPrefix(oid, "urn:oid:")
+
+	if !oidPattern.MatchString(oid) {
+		return nil, fmt.Errorf("Invalid OID: %q", oid)
+	}
+
+	return &OIDGenerator{oid: oid, counter: counter}, nil
+}
+
+// NewIdentifier returns back the next identifier under the OID
+func (g *OIDGenerator) NewIdentifier() (string, error) {
+	now := time.Now
+
+	if g.Now != nil {
+		now = g.Now
+	}
+
+	n, err := g.counter.Next()
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("urn:oid:%s.%s.%d", g.oid, now().UTC().Format("20060102150405"), n), nil
+}
+
+// SequenceGenerator creates identifiers from a prefix and the next value of a
+// counter, such as NWS-IDP-PROD-0001234
+type SequenceGenerator struct {
+	Prefix  string
+	Counter Counter
+
+	// Width zero pads the sequence number to at least this many digits
+	Width int
+}
+
+// NewIdentifier returns back the prefix followed by the next sequence number
+func (g SequenceGenerator) NewIdentifier() (string, error) {
+	n, err := g.Counter.Next()
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%0*d", g.Prefix, g.Width, n), nil
+}
+
+// identifierProblem returns back why value breaks the character rules of CAP 1.2
+// section 3.2.1 for identifier and sender, or an empty string if it follows them
+func identifierProblem(value string) string {
+	for _, r := range value {
+		switch {
+		case unicode.IsSpace(r):
+			return "must not contain spaces"
+		case r == ',':
+			return "must not contain commas"
+		case r == '<' || r == '&':
+			return fmt.Sprintf("must not contain the restricted character %q", r)
+		case unicode.IsControl(r):
+			return "must not contain control characters"
+		}
+	}
+
+	return ""
+}
+
+// ValidateIdentifier checks an alert identifier is not empty and has no spaces,
+// commas or restricted characters (< and &)
+func ValidateIdentifier(identifier string) error {
+	if identifier == "" {
+		return fmt.Errorf("Invalid identifier: must not be empty")
+	}
+
+	if problem := identifierProblem(identifier); problem != "" {
+		return fmt.Errorf("Invalid identifier %q: %s", identifier, problem)
+	}
+
+	return nil
+}
+
+// ValidateSender checks an alert sender is not empty and has no spaces, commas
+// or restricted characters (< and &)
+func ValidateSender(sender string) error {
+	if sender == "" {
+		return fmt.Errorf("Invalid sender: must not be empty")
+	}
+
+	if problem := identifierProblem(sender); problem != "" {
+		return fmt.Errorf("Invalid sender %q: %s", sender, problem)
+	}
+
+	return nil
+}
+
+// Reference returns back the reference other messages use to refer to the alert
+//
+// Its String method gives the sender,identifier,sent form used in the
+// references element.
+func (alert *Alert) Reference() Reference {
+	return Reference{SenderID: alert.SenderID, MessageID: alert.MessageID, SentDate: alert.SentDate}
+}
@@ -0,0 +1,153 @@
+package cap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestUUIDGeneratorCreatesVersion4UUIDs(t *testing.T) {
+	g := UUIDGenerator{Prefix: "urn:uuid:"}
+	pattern := regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	first, err := g.NewIdentifier()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := g.NewIdentifier()
+
+	assertEqual(t, pattern.MatchString(first), true, "The identifier should be a prefixed version 4 UUID: "+first)
+	assertEqual(t, first != second, true, "Identifiers should be unique")
+	assertEqual(t, ValidateIdentifier(first), nil, "Generated identifiers should be valid")
+}
+
+func TestOIDGeneratorUsesTimeAndCounter(t *testing.T) {
+	g, err := NewOIDGenerator("urn:oid:2.49.0.1.124", &MemoryCounter{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.Now = func() time.Time {
+		return time.Date(2015, 8, 15, 20, 45, 0, 0, time.FixedZone("CDT", -5*60*60))
+	}
+
+	first, _ := g.NewIdentifier()
+	second, _ := g.NewIdentifier()
+
+	assertEqual(t, first, "urn:oid:2.49.0.1.124.20150816014500.1", "The identifier should use the UTC time")
+	assertEqual(t, second, "urn:oid:2.49.0.1.124.20150816014500.2", "The counter should make identifiers unique")
+}
+
+func TestNewOIDGeneratorRejectsInvalidOIDs(t *testing.T) {
+	for _, oid := range []string{"", "2", "2.49.", "2.049.0", "2.a.0"} {
+		_, err := NewOIDGenerator(oid, &MemoryCounter{})
+
+		assertEqual(t, err != nil, true, "The OID should be rejected: "+oid)
+	}
+}
+
+func TestSequenceGeneratorPadsNumbers(t *testing.T) {
+	g := SequenceGenerator{Prefix: "NWS-IDP-PROD-", Counter: &MemoryCounter{}, Width: 7}
+
+	first, _ := g.NewIdentifier()
+	second, _ := g.NewIdentifier()
+
+	assertEqual(t, first, "NWS-IDP-PROD-0000001", "The first identifier should be padded")
+	assertEqual(t, second, "NWS-IDP-PROD-0000002", "The counter should advance")
+}
+
+func TestFileCounterPersistsAcrossInstances(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cap-counter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "counter")
+
+	for i := uint64(1); i <= 3; i++ {
+		n, err := NewFileCounter(path).Next()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertEqual(t, n, i, "The counter should continue from the saved value")
+	}
+
+	raw, _ := ioutil.ReadFile(path)
+
+	assertEqual(t, string(raw), "3\n", "The last value should be saved")
+
+	files, _ := ioutil.ReadDir(dir)
+
+	assertEqual(t, len(files), 1, "No temporary files should be left behind")
+}
+
+func TestFileCounterRejectsCorruptFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cap-counter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "counter")
+	ioutil.WriteFile(path, []byte("twelve"), 0644)
+
+	_, err = NewFileCounter(path).Next()
+
+	assertStartsWith(t, err.Error(), "Invalid counter file", "A corrupt counter should not restart the sequence")
+}
+
+func TestValidateIdentifierAndSender(t *testing.T) {
+	assertEqual(t, ValidateIdentifier("KSTO1055887203"), nil, "A plain identifier should be valid")
+	assertEqual(t, ValidateSender("w-nws.webmaster@noaa.gov"), nil, "An email address should be a valid sender")
+
+	cases := map[string]string{
+		"":        `Invalid identifier: must not be empty`,
+		"KSTO 1":  `Invalid identifier "KSTO 1": must not contain spaces`,
+		"KSTO,1":  `Invalid identifier "KSTO,1": must not contain commas`,
+		"KSTO<1":  `Invalid identifier "KSTO<1": must not contain the restricted character '<'`,
+		"KSTO&1":  `Invalid identifier "KSTO&1": must not contain the restricted character '&'`,
+		"KSTO\t1": `Invalid identifier "KSTO\t1": must not contain spaces`,
+	}
+
+	for identifier, expected := range cases {
+		assertEqual(t, ValidateIdentifier(identifier).Error(), expected, "Unexpected error message")
+	}
+
+	assertEqual(t, ValidateSender("a b").Error(), `Invalid sender "a b": must not contain spaces`, "Unexpected error message")
+}
+
+func TestValidateChecksIdentifierCharacters(t *testing.T) {
+	alert, err := getCAPAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alert.MessageID = "KSTO 1"
+	alert.SenderID = "a,b"
+
+	errs := alert.Validate().(ValidationErrors)
+
+	assertEqual(t, len(errs), 2, "Both the identifier and sender should be reported")
+	assertEqual(t, errs[0].Error(), `identifier: "KSTO 1" must not contain spaces`, "Unexpected error message")
+	assertEqual(t, errs[1].Error(), `sender: "a,b" must not contain commas`, "Unexpected error message")
+}
+
+func TestAlertReference(t *testing.T) {
+	alert := Alert{MessageID: "KSTO1055887203", SenderID: "KSTO@NWS.NOAA.GOV", SentDate: "2003-06-17T14:57:00-07:00"}
+
+	assertEqual(t, alert.Reference().String(), "KSTO@NWS.NOAA.GOV,KSTO1055887203,2003-06-17T14:57:00-07:00", "Unexpected reference")
+}
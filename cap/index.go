@@ -0,0 +1,603 @@
+package cap
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// AlertInfo is an Info block of an alert whose areas matched a spatial query
+type AlertInfo struct {
+	Alert *Alert
+	Info  *Info
+}
+
+// SpatialIndex answers which alerts cover a point or box, using an R-tree over
+// the bounding boxes of every area
+//
+// Each area is indexed with its polygons and circles, or the boundaries of its
+// geocodes if it has neither, as given by Area.Geometry, so run a
+// GeometryResolver over geocode-only alerts before inserting them. Candidates found in the tree are
+// checked against the exact shapes, and circles use the great circle distance
+// from their center. Areas crossing the antimeridian are not supported.
+//
+// Alerts are keyed by sender and identifier; inserting an alert again replaces
+// it. Pass Apply to Store.OnChange to keep the index in step with a Store as
+// alerts are updated, cancelled and expire. A SpatialIndex is safe for
+// concurrent use.
+type SpatialIndex struct {
+	mu     sync.RWMutex
+	tree   rtree
+	alerts map[string][]*indexItem
+}
+
+// indexItem is an area of an Info block held in the R-tree
+type indexItem struct {
+	box  BBox
+	leaf *rtreeNode
+
+	alert     *Alert
+	info      *Info
+	infoIndex int
+	sent      int64
+
+	polygons MultiPolygon
+	circles  []indexCircle
+}
+
+type indexCircle struct {
+	lat    float64
+	lon    float64
+	radius float64
+}
+
+// NewSpatialIndex returns back an empty SpatialIndex
+func NewSpatialIndex() *SpatialIndex {
+	return &SpatialIndex{tree: newRTree(), alerts: make(map[string][]*indexItem)}
+}
+
+// Insert indexes every area of the alert, replacing the alert with the same
+// sender and identifier if it was already indexed
+//
+// Areas without a valid polygon, circle or geocode boundary cannot be found by
+// queries and are skipped.
+func (idx *SpatialIndex) Insert(alert *Alert) {
+	var items []*indexItem
+
+	for i := range alert.Infos {
+		info := &alert.Infos[i]
+
+		for j := range info.Areas {
+			if item := newIndexItem(alert, info, i, &info.Areas[j]); item != nil {
+				items = append(items, item)
+			}
+		}
+	}
+
+	key := storeKey(alert.SenderID, alert.MessageID)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(key)
+
+	for _, item := range items {
+		idx.tree.insert(item)
+	}
+
+	idx.alerts[key] = items
+}
+
+// Remove drops the alert with the specified sender and identifier, returning
+// back false if it was not indexed
+func (idx *SpatialIndex) Remove(senderID, messageID string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.remove(storeKey(senderID, messageID))
+}
+
+func (idx *SpatialIndex) remove(key string) bool {
+	items, exists := idx.alerts[key]
+
+	if !exists {
+		return false
+	}
+
+	for _, item := range items {
+		idx.tree.remove(item)
+	}
+
+	delete(idx.alerts, key)
+
+	return true
+}
+
+// Apply updates the index with a change made to a Store
+//
+// New alerts and updates are inserted, and the alerts they supersede, cancel
+// or that expire are removed. Acknowledgements and errors change nothing.
+func (idx *SpatialIndex) Apply(event StoreEvent) {
+	switch event.Type {
+	case StoreAdded:
+		idx.Insert(event.Alert)
+	case StoreUpdated:
+		idx.Remove(event.Previous.SenderID, event.Previous.MessageID)
+		idx.Insert(event.Alert)
+	case StoreCancelled:
+		idx.Remove(event.Previous.SenderID, event.Previous.MessageID)
+	case StoreExpired:
+		idx.Remove(event.Alert.SenderID, event.Alert.MessageID)
+	}
+}
+
+// Len returns back the number of alerts in the index
+func (idx *SpatialIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.alerts)
+}
+
+// Query returns back the Info blocks with an area containing the point,
+// ordered by the sent date of their alerts
+func (idx *SpatialIndex) Query(lat, lon float64) []AlertInfo {
+	return idx.query(BBox{MinLon: lon, MinLat: lat, MaxLon: lon, MaxLat: lat}, func(item *indexItem) bool {
+		return item.contains(lat, lon)
+	})
+}
+
+// QueryBBox returns back the Info blocks with an area overlapping the box,
+// ordered by the sent date of their alerts
+func (idx *SpatialIndex) QueryBBox(box BBox) []AlertInfo {
+	return idx.query(box, func(item *indexItem) bool {
+		return item.intersects(box)
+	})
+}
+
+func (idx *SpatialIndex) query(box BBox, match func(*indexItem) bool) []AlertInfo {
+	idx.mu.RLock()
+
+	var found []*indexItem
+
+	seen := make(map[*Info]bool)
+
+	idx.tree.search(box, func(item *indexItem) {
+		if !seen[item.info] && match(item) {
+			seen[item.info] = true
+			found = append(found, item)
+		}
+	})
+
+	idx.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+
+		if a.alert != b.alert {
+			if a.sent != b.sent {
+				return a.sent < b.sent
+			}
+
+			return storeKey(a.alert.SenderID, a.alert.MessageID) < storeKey(b.alert.SenderID, b.alert.MessageID)
+		}
+
+		return a.infoIndex < b.infoIndex
+	})
+
+	results := make([]AlertInfo, len(found))
+
+	for i, item := range found {
+		results[i] = AlertInfo{Alert: item.alert, Info: item.info}
+	}
+
+	return results
+}
+
+// newIndexItem returns back the indexed form of an area, or nil if it has no geometry
+func newIndexItem(alert *Alert, info *Info, infoIndex int, area *Area) *indexItem {
+	item := &indexItem{alert: alert, info: info, infoIndex: infoIndex, sent: sentUnix(alert)}
+
+	for _, polygon := range area.polygons() {
+		if ring, err := parsePolygonRing(polygon); err == nil {
+			item.polygons = append(item.polygons, Polygon{ring})
+		}
+	}
+
+	item.polygons = append(item.polygons, area.geocodeGeometry()...)
+	box, found := item.polygons.Bounds()
+
+	for _, c := range area.circles() {
+		center, radius, err := parseCircle(c)
+
+		if err != nil {
+			continue
+		}
+
+		circle := indexCircle{lat: center[1], lon: center[0], radius: radius}
+		item.circles = append(item.circles, circle)
+
+		if found {
+			box = box.Union(circle.bounds())
+		} else {
+			box, found = circle.bounds(), true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	item.box = box
+
+	return item
+}
+
+func (item *indexItem) contains(lat, lon float64) bool {
+	for _, c := range item.circles {
+		if distance(c.lat, c.lon, lat, lon) <= c.radius {
+			return true
+		}
+	}
+
+	return item.polygons.Contains(lat, lon)
+}
+
+func (item *indexItem) intersects(box BBox) bool {
+	for _, c := range item.circles {
+		// The nearest point of the box to the center
+		lat := math.Max(box.MinLat, math.Min(c.lat, box.MaxLat))
+		lon := math.Max(box.MinLon, math.Min(c.lon, box.MaxLon))
+
+		if distance(c.lat, c.lon, lat, lon) <= c.radius {
+			return true
+		}
+	}
+
+	for _, p := range item.polygons {
+		if polygonIntersectsBox(p, box) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bounds returns back a box around the circle, widening the longitude span
+// with the latitude and capping it at the whole globe near the poles
+func (c indexCircle) bounds() BBox {
+	dLat := c.radius / 111.32
+	dLon := 180.0
+
+	if cos := math.Cos(c.lat * math.Pi / 180); cos > 1e-6 {
+		dLon = math.Min(180, dLat/cos)
+	}
+
+	return BBox{MinLon: c.lon - dLon, MinLat: c.lat - dLat, MaxLon: c.lon + dLon, MaxLat: c.lat + dLat}
+}
+
+// polygonIntersectsBox returns back true if the polygon and box share any point
+func polygonIntersectsBox(p Polygon, box BBox) bool {
+	if len(p) == 0 {
+		return false
+	}
+
+	if bounds, _ := (MultiPolygon{p}).Bounds(); !bounds.Intersects(box) {
+		return false
+	}
+
+	// A position of the outer ring inside the box, or a corner of the box inside the polygon
+	for _, pos := range p[0] {
+		if box.Contains(pos[1], pos[0]) {
+			return true
+		}
+	}
+
+	corners := [4][2]float64{{box.MinLon, box.MinLat}, {box.MaxLon, box.MinLat}, {box.MaxLon, box.MaxLat}, {box.MinLon, box.MaxLat}}
+
+	for _, corner := range corners {
+		if p.Contains(corner[1], corner[0]) {
+			return true
+		}
+	}
+
+	// Otherwise an edge of the outer ring must cross an edge of the box
+	for i := 0; i < len(p[0])-1; i++ {
+		for j := range corners {
+			if segmentsIntersect(p[0][i], p[0][i+1], corners[j], corners[(j+1)%4]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// segmentsIntersect returns back true if the segment from a to b crosses or touches the segment from c to d
+func segmentsIntersect(a, b, c, d [2]float64) bool {
+	d1 := cross(c, d, a)
+	d2 := cross(c, d, b)
+	d3 := cross(a, b, c)
+	d4 := cross(a, b, d)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+
+	return (d1 == 0 && onSegment(a[0], a[1], c[0], c[1], d[0], d[1])) ||
+		(d2 == 0 && onSegment(b[0], b[1], c[0], c[1], d[0], d[1])) ||
+		(d3 == 0 && onSegment(c[0], c[1], a[0], a[1], b[0], b[1])) ||
+		(d4 == 0 && onSegment(d[0], d[1], a[0], a[1], b[0], b[1]))
+}
+
+// cross returns back the cross product of (b - a) and (c - a)
+func cross(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// R-tree node capacities
+const (
+	rtreeMaxEntries int = 16
+	rtreeMinEntries int = 6
+)
+
+// rtree is an R-tree with quadratic splits, as described by Guttman
+type rtree struct {
+	root *rtreeNode
+}
+
+type rtreeNode struct {
+	parent  *rtreeNode
+	leaf    bool
+	entries []rtreeEntry
+}
+
+// rtreeEntry is a child node of an internal node, or an item of a leaf
+type rtreeEntry struct {
+	box   BBox
+	child *rtreeNode
+	item  *indexItem
+}
+
+func newRTree() rtree {
+	return rtree{root: &rtreeNode{leaf: true}}
+}
+
+func (t *rtree) search(box BBox, fn func(*indexItem)) {
+	t.root.search(box, fn)
+}
+
+func (n *rtreeNode) search(box BBox, fn func(*indexItem)) {
+	for _, e := range n.entries {
+		if !e.box.Intersects(box) {
+			continue
+		}
+
+		if n.leaf {
+			fn(e.item)
+		} else {
+			e.child.search(box, fn)
+		}
+	}
+}
+
+func (t *rtree) insert(item *indexItem) {
+	n := t.root
+
+	for !n.leaf {
+		n = n.entries[chooseSubtree(n, item.box)].child
+	}
+
+	n.entries = append(n.entries, rtreeEntry{box: item.box, item: item})
+	item.leaf = n
+	t.adjust(n)
+}
+
+// chooseSubtree returns back the entry of n needing the least enlargement to hold box
+func chooseSubtree(n *rtreeNode, box BBox) int {
+	best, bestGrowth, bestArea := 0, math.Inf(1), math.Inf(1)
+
+	for i, e := range n.entries {
+		area := boxArea(e.box)
+		growth := boxArea(e.box.Union(box)) - area
+
+		if growth < bestGrowth || (growth == bestGrowth && area < bestArea) {
+			best, bestGrowth, bestArea = i, growth, area
+		}
+	}
+
+	return best
+}
+
+// adjust splits overfull nodes and refreshes bounding boxes from n up to the root
+func (t *rtree) adjust(n *rtreeNode) {
+	for {
+		var sibling *rtreeNode
+
+		if len(n.entries) > rtreeMaxEntries {
+			sibling = n.split()
+		}
+
+		parent := n.parent
+
+		if parent == nil {
+			if sibling != nil {
+				root := &rtreeNode{entries: []rtreeEntry{{box: n.bounds(), child: n}, {box: sibling.bounds(), child: sibling}}}
+				n.parent, sibling.parent = root, root
+				t.root = root
+			}
+
+			return
+		}
+
+		parent.entries[parent.indexOf(n)].box = n.bounds()
+
+		if sibling != nil {
+			sibling.parent = parent
+			parent.entries = append(parent.entries, rtreeEntry{box: sibling.bounds(), child: sibling})
+		}
+
+		n = parent
+	}
+}
+
+// split moves about half of the entries of n into a new sibling node, using Guttman's quadratic split
+func (n *rtreeNode) split() *rtreeNode {
+	entries := n.entries
+	seedA, seedB, worst := 0, 1, math.Inf(-1)
+
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			waste := boxArea(entries[i].box.Union(entries[j].box)) - boxArea(entries[i].box) - boxArea(entries[j].box)
+
+			if waste > worst {
+				seedA, seedB, worst = i, j, waste
+			}
+		}
+	}
+
+	a := []rtreeEntry{entries[seedA]}
+	b := []rtreeEntry{entries[seedB]}
+	boxA, boxB := entries[seedA].box, entries[seedB].box
+
+	var remaining []rtreeEntry
+
+	for i, e := range entries {
+		if i != seedA && i != seedB {
+			remaining = append(remaining, e)
+		}
+	}
+
+	for len(remaining) > 0 {
+		if len(a)+len(remaining) <= rtreeMinEntries {
+			a = append(a, remaining...)
+			break
+		}
+
+		if len(b)+len(remaining) <= rtreeMinEntries {
+			b = append(b, remaining...)
+			break
+		}
+
+		// Assign the entry with the strongest preference for one group first
+		next, preference := 0, math.Inf(-1)
+
+		for i, e := range remaining {
+			growthA := boxArea(boxA.Union(e.box)) - boxArea(boxA)
+			growthB := boxArea(boxB.Union(e.box)) - boxArea(boxB)
+
+			if d := math.Abs(growthA - growthB); d > preference {
+				next, preference = i, d
+			}
+		}
+
+		e := remaining[next]
+		remaining = append(remaining[:next], remaining[next+1:]...)
+
+		growthA := boxArea(boxA.Union(e.box)) - boxArea(boxA)
+		growthB := boxArea(boxB.Union(e.box)) - boxArea(boxB)
+
+		if growthA < growthB || (growthA == growthB && (boxArea(boxA) < boxArea(boxB) || (boxArea(boxA) == boxArea(boxB) && len(a) <= len(b)))) {
+			a = append(a, e)
+			boxA = boxA.Union(e.box)
+		} else {
+			b = append(b, e)
+			boxB = boxB.Union(e.box)
+		}
+	}
+
+	n.entries = a
+	sibling := &rtreeNode{leaf: n.leaf, entries: b}
+
+	for _, e := range b {
+		if n.leaf {
+			e.item.leaf = sibling
+		} else {
+			e.child.parent = sibling
+		}
+	}
+
+	return sibling
+}
+
+func (t *rtree) remove(item *indexItem) {
+	n := item.leaf
+	n.entries = removeEntry(n.entries, func(e rtreeEntry) bool { return e.item == item })
+	item.leaf = nil
+
+	// Dissolve underfull nodes on the way up, reinserting their items afterwards
+	var orphans []*indexItem
+
+	for n.parent != nil {
+		parent := n.parent
+
+		if len(n.entries) < rtreeMinEntries {
+			parent.entries = removeEntry(parent.entries, func(e rtreeEntry) bool { return e.child == n })
+			orphans = n.collect(orphans)
+		} else {
+			parent.entries[parent.indexOf(n)].box = n.bounds()
+		}
+
+		n = parent
+	}
+
+	for !t.root.leaf && len(t.root.entries) == 1 {
+		t.root = t.root.entries[0].child
+		t.root.parent = nil
+	}
+
+	if !t.root.leaf && len(t.root.entries) == 0 {
+		t.root = &rtreeNode{leaf: true}
+	}
+
+	for _, orphan := range orphans {
+		t.insert(orphan)
+	}
+}
+
+// collect appends the items held in n and its descendants to items
+func (n *rtreeNode) collect(items []*indexItem) []*indexItem {
+	for _, e := range n.entries {
+		if n.leaf {
+			items = append(items, e.item)
+		} else {
+			items = e.child.collect(items)
+		}
+	}
+
+	return items
+}
+
+func (n *rtreeNode) bounds() BBox {
+	box := n.entries[0].box
+
+	for _, e := range n.entries[1:] {
+		box = box.Union(e.box)
+	}
+
+	return box
+}
+
+func (n *rtreeNode) indexOf(child *rtreeNode) int {
+	for i, e := range n.entries {
+		if e.child == child {
+			return i
+		}
+	}
+
+	panic("cap: R-tree node is not a child of its parent")
+}
+
+func removeEntry(entries []rtreeEntry, match func(rtreeEntry) bool) []rtreeEntry {
+	for i, e := range entries {
+		if match(e) {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+
+	return entries
+}
+
+func boxArea(box BBox) float64 {
+	return (box.MaxLon - box.MinLon) * (box.MaxLat - box.MinLat)
+}
@@ -0,0 +1,265 @@
+package cap
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// newIndexTestAlert returns back an alert with a square polygon of side size degrees at lat, lon
+func newIndexTestAlert(id string, lat, lon, size float64) *Alert {
+	polygon := fmt.Sprintf("%g,%g %g,%g %g,%g %g,%g %g,%g", lat, lon, lat+size, lon, lat+size, lon+size, lat, lon+size, lat, lon)
+
+	return &Alert{
+		MessageID:   id,
+		SenderID:    "sender@example.com",
+		SentDate:    "2015-08-15T20:45:00-05:00",
+		MessageType: "Alert",
+		Infos:       []Info{{EventType: "Flood Warning", Areas: []Area{{Description: id, Polygons: []string{polygon}}}}},
+	}
+}
+
+func indexResultIDs(results []AlertInfo) []string {
+	ids := make([]string, len(results))
+
+	for i, r := range results {
+		ids[i] = r.Alert.MessageID
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// checkRTree verifies every node's box covers its entries and every leaf is at the same depth
+func checkRTree(t *testing.T, n *rtreeNode, depth int, leafDepth *int) {
+	for _, e := range n.entries {
+		if n.leaf {
+			if e.item.leaf != n {
+				t.Fatal("An item has the wrong leaf")
+			}
+
+			continue
+		}
+
+		if e.child.parent != n {
+			t.Fatal("A node has the wrong parent")
+		}
+
+		if len(e.child.entries) == 0 || e.box != e.child.bounds() {
+			t.Fatal("A node's box does not match its entries")
+		}
+
+		checkRTree(t, e.child, depth+1, leafDepth)
+	}
+
+	if n.leaf {
+		if *leafDepth >= 0 && *leafDepth != depth {
+			t.Fatal("Leaves are at different depths")
+		}
+
+		*leafDepth = depth
+	}
+}
+
+func TestSpatialIndexQuery(t *testing.T) {
+	idx := NewSpatialIndex()
+	idx.Insert(newIndexTestAlert("a", 35, -91, 1))
+	idx.Insert(newIndexTestAlert("b", 35.5, -90.5, 1))
+
+	circle := newIndexTestAlert("c", 0, 0, 0)
+	circle.Infos[0].Areas[0] = Area{Description: "Circle", Circles: []string{"32.9525,-115.5527 2"}}
+	idx.Insert(circle)
+
+	assertEqual(t, fmt.Sprint(indexResultIDs(idx.Query(35.2, -90.8))), "[a]", "Only a should cover the point")
+	assertEqual(t, fmt.Sprint(indexResultIDs(idx.Query(35.7, -90.2))), "[a b]", "Both should cover the point")
+	assertEqual(t, fmt.Sprint(indexResultIDs(idx.Query(37, -90))), "[]", "Nothing should cover the point")
+	assertEqual(t, fmt.Sprint(indexResultIDs(idx.Query(32.96, -115.55))), "[c]", "The circle should cover the point")
+	assertEqual(t, fmt.Sprint(indexResultIDs(idx.Query(32.99, -115.55))), "[]", "The point should be outside the circle")
+
+	results := idx.Query(35.2, -90.8)
+
+	assertEqual(t, results[0].Info, &results[0].Alert.Infos[0], "The matching Info should be returned")
+}
+
+func TestSpatialIndexQueryBBox(t *testing.T) {
+	idx := NewSpatialIndex()
+	idx.Insert(newIndexTestAlert("a", 35, -91, 1))
+	idx.Insert(newIndexTestAlert("b", 38, -91, 1))
+
+	// A triangle whose bounding box overlaps the query but whose shape does not
+	triangle := newIndexTestAlert("c", 0, 0, 0)
+	triangle.Infos[0].Areas[0].Polygons = []string{"40,-95 40,-93 42,-95 40,-95"}
+	idx.Insert(triangle)
+
+	assertEqual(t, fmt.Sprint(indexResultIDs(idx.QueryBBox(BBox{MinLon: -91.5, MinLat: 35.5, MaxLon: -90.5, MaxLat: 38.5}))), "[a b]", "Both squares should overlap the box")
+	assertEqual(t, fmt.Sprint(indexResultIDs(idx.QueryBBox(BBox{MinLon: -90.8, MinLat: 35.2, MaxLon: -90.6, MaxLat: 35.4}))), "[a]", "A box inside a square should overlap it")
+	assertEqual(t, fmt.Sprint(indexResultIDs(idx.QueryBBox(BBox{MinLon: -93.5, MinLat: 41.5, MaxLon: -93, MaxLat: 42}))), "[]", "The box misses the triangle")
+}
+
+func TestSpatialIndexOrdersBySentInstant(t *testing.T) {
+	idx := NewSpatialIndex()
+	later := newIndexTestAlert("a", 35, -91, 1)
+	earlier := newIndexTestAlert("b", 35, -91, 1)
+	earlier.SentDate = "2015-08-16T00:30:00+02:00"
+	idx.Insert(later)
+	idx.Insert(earlier)
+
+	results := idx.Query(35.5, -90.5)
+
+	assertEqual(t, results[0].Alert, earlier, "The alert sent first should come first, whatever its UTC offset")
+	assertEqual(t, results[1].Alert, later, "The alert sent last should come last")
+}
+
+func TestSpatialIndexReplacesAndRemoves(t *testing.T) {
+	idx := NewSpatialIndex()
+	idx.Insert(newIndexTestAlert("a", 35, -91, 1))
+	idx.Insert(newIndexTestAlert("a", 40, -91, 1))
+
+	assertEqual(t, idx.Len(), 1, "Inserting the same alert should replace it")
+	assertEqual(t, len(idx.Query(35.5, -90.5)), 0, "The old area should be gone")
+	assertEqual(t, len(idx.Query(40.5, -90.5)), 1, "The new area should be indexed")
+	assertEqual(t, idx.Remove("sender@example.com", "a"), true, "The alert should be removed")
+	assertEqual(t, idx.Remove("sender@example.com", "a"), false, "The alert should already be gone")
+	assertEqual(t, len(idx.Query(40.5, -90.5)), 0, "Nothing should be left")
+}
+
+func TestSpatialIndexFollowsStore(t *testing.T) {
+	store := NewStore()
+	idx := NewSpatialIndex()
+	store.OnChange(idx.Apply)
+
+	alert := newIndexTestAlert("a", 35, -91, 1)
+	alert.Infos[0].ExpiresDate = "2015-08-16T11:45:00-05:00"
+	store.Add(alert)
+
+	assertEqual(t, len(idx.Query(35.5, -90.5)), 1, "Added alerts should be indexed")
+
+	update := newIndexTestAlert("b", 40, -91, 1)
+	update.MessageType = "Update"
+	update.ReferenceIDs = []string{alert.Reference().String()}
+	update.Infos[0].ExpiresDate = "2015-08-16T11:45:00-05:00"
+	store.Add(update)
+
+	assertEqual(t, len(idx.Query(35.5, -90.5)), 0, "Superseded alerts should be removed")
+	assertEqual(t, len(idx.Query(40.5, -90.5)), 1, "Updates should be indexed")
+
+	store.Prune(storeTestTime.AddDate(0, 0, 2))
+
+	assertEqual(t, idx.Len(), 0, "Expired alerts should be removed")
+}
+
+func TestSpatialIndexMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	idx := NewSpatialIndex()
+	alerts := make(map[string]*Alert)
+
+	for i := 0; i < 2000; i++ {
+		id := fmt.Sprint(r.Intn(500))
+
+		if r.Intn(4) == 0 {
+			idx.Remove("sender@example.com", id)
+			delete(alerts, id)
+			continue
+		}
+
+		alert := newIndexTestAlert(id, 25+r.Float64()*24, -125+r.Float64()*58, 0.1+r.Float64()*2)
+		idx.Insert(alert)
+		alerts[id] = alert
+	}
+
+	leafDepth := -1
+	checkRTree(t, idx.tree.root, 0, &leafDepth)
+
+	assertEqual(t, idx.Len(), len(alerts), "The index should hold every alert")
+
+	for i := 0; i < 500; i++ {
+		lat, lon := 25+r.Float64()*24, -125+r.Float64()*58
+
+		var expected []string
+
+		for id, alert := range alerts {
+			if alert.Infos[0].Areas[0].Contains(lat, lon) {
+				expected = append(expected, id)
+			}
+		}
+
+		sort.Strings(expected)
+
+		assertEqual(t, fmt.Sprint(indexResultIDs(idx.Query(lat, lon))), fmt.Sprint(expected), "The index should agree with a linear scan")
+	}
+}
+
+func newIndexBenchmark(b *testing.B, n int) *SpatialIndex {
+	r := rand.New(rand.NewSource(1))
+	idx := NewSpatialIndex()
+
+	for i := 0; i < n; i++ {
+		idx.Insert(newIndexTestAlert(fmt.Sprint(i), 25+r.Float64()*24, -125+r.Float64()*58, 0.1+r.Float64()))
+	}
+
+	b.ResetTimer()
+
+	return idx
+}
+
+func BenchmarkSpatialIndexQuery(b *testing.B) {
+	idx := newIndexBenchmark(b, 10000)
+	r := rand.New(rand.NewSource(2))
+
+	for i := 0; i < b.N; i++ {
+		idx.Query(25+r.Float64()*24, -125+r.Float64()*58)
+	}
+}
+
+func BenchmarkSpatialIndexQueryParallel(b *testing.B) {
+	idx := newIndexBenchmark(b, 10000)
+
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(2))
+
+		for pb.Next() {
+			idx.Query(25+r.Float64()*24, -125+r.Float64()*58)
+		}
+	})
+}
+
+func BenchmarkSpatialIndexQueryBBox(b *testing.B) {
+	idx := newIndexBenchmark(b, 10000)
+	r := rand.New(rand.NewSource(2))
+
+	for i := 0; i < b.N; i++ {
+		lat, lon := 25+r.Float64()*24, -125+r.Float64()*58
+		idx.QueryBBox(BBox{MinLon: lon, MinLat: lat, MaxLon: lon + 1, MaxLat: lat + 1})
+	}
+}
+
+func BenchmarkSpatialIndexInsertRemove(b *testing.B) {
+	idx := newIndexBenchmark(b, 10000)
+	alert := newIndexTestAlert("moving", 35, -91, 1)
+
+	for i := 0; i < b.N; i++ {
+		idx.Insert(alert)
+		idx.Remove(alert.SenderID, alert.MessageID)
+	}
+}
+
+func BenchmarkLinearScanQuery(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	areas := make([]Area, 10000)
+
+	for i := range areas {
+		areas[i] = newIndexTestAlert(fmt.Sprint(i), 25+r.Float64()*24, -125+r.Float64()*58, 0.1+r.Float64()).Infos[0].Areas[0]
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lat, lon := 25+r.Float64()*24, -125+r.Float64()*58
+
+		for j := range areas {
+			areas[j].Contains(lat, lon)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package cap
+
+import (
+	_ "embed"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+)
+
+// JSONSchema is the JSON Schema (draft 2020-12) describing the JSON form of an Alert
+//
+// Property names are the CAP element names, parameters, event codes and
+// geocodes are arrays of {"valueName", "value"} objects, and dates are RFC 3339
+// strings exactly as they appear in the XML form.
+//
+// The Extra elements of the alert, info, resource and area objects are kept as
+// XML strings in their extension property and the ExtraAttrs attributes in
+// their extensionAttr property, so extensions and signatures survive a round
+// trip through JSON.
+//
+//go:embed alert.schema.json
+var JSONSchema string
+
+// alertJSON is the JSON form of an Alert
+//
+// The references and incidents elements are whitespace separated lists in CAP,
+// so they are represented as a single string just like in the XML form.
+type alertJSON struct {
+	MessageID     string   `json:"identifier"`
+	SenderID      string   `json:"sender"`
+	SentDate      string   `json:"sent"`
+	MessageStatus string   `json:"status"`
+	MessageType   string   `json:"msgType"`
+	Source        string   `json:"source,omitempty"`
+	Scope         string   `json:"scope"`
+	Restriction   string   `json:"restriction,omitempty"`
+	Addresses     string   `json:"addresses,omitempty"`
+	HandlingCodes []string `json:"code,omitempty"`
+	Note          string   `json:"note,omitempty"`
+	ReferenceIDs  string   `json:"references,omitempty"`
+	IncidentIDs   string   `json:"incidents,omitempty"`
+	Infos         []Info   `json:"info,omitempty"`
+	extensionsJSON
+}
+
+// infoJSON is the JSON form of an Info
+type infoJSON struct {
+	Language         string       `json:"language,omitempty"`
+	EventCategory    string       `json:"category"`
+	EventType        string       `json:"event"`
+	ResponseType     string       `json:"responseType,omitempty"`
+	Urgency          string       `json:"urgency"`
+	Severity         string       `json:"severity"`
+	Certainty        string       `json:"certainty"`
+	Audience         string       `json:"audience,omitempty"`
+	EventCode        []NamedValue `json:"eventCode,omitempty"`
+	EffectiveDate    string       `json:"effective,omitempty"`
+	ExpiresDate      string       `json:"expires,omitempty"`
+	OnsetDate        string       `json:"onset,omitempty"`
+	SenderName       string       `json:"senderName,omitempty"`
+	Headline         string       `json:"headline,omitempty"`
+	EventDescription string       `json:"description,omitempty"`
+	Instruction      string       `json:"instruction,omitempty"`
+	InformationURL   string       `json:"web,omitempty"`
+	ContactInfo      string       `json:"contact,omitempty"`
+	Parameters       []NamedValue `json:"parameter,omitempty"`
+	Areas            []Area       `json:"area,omitempty"`
+	Resources        []Resource   `json:"resource,omitempty"`
+	extensionsJSON
+}
+
+// resourceJSON is the JSON form of a Resource
+type resourceJSON struct {
+	Description      string `json:"resourceDesc"`
+	MIMEType         string `json:"mimeType,omitempty"`
+	FileSize         string `json:"size,omitempty"`
+	URI              string `json:"uri,omitempty"`
+	DeereferencedURI string `json:"derefUri,omitempty"`
+	Digest           string `json:"digest,omitempty"`
+	extensionsJSON
+}
+
+// areaJSON is the JSON form of an Area
+type areaJSON struct {
+	Description string       `json:"areaDesc"`
+	Polygons    []string     `json:"polygon,omitempty"`
+	Circles     []string     `json:"circle,omitempty"`
+	Geocodes    []NamedValue `json:"geocode,omitempty"`
+	Altitude    string       `json:"altitude,omitempty"`
+	Ceiling     string       `json:"ceiling,omitempty"`
+	extensionsJSON
+}
+
+// extensionsJSON is the JSON form of the Extra and ExtraAttrs of an element
+type extensionsJSON struct {
+	Extension     []string            `json:"extension,omitempty"`
+	ExtensionAttr []extensionAttrJSON `json:"extensionAttr,omitempty"`
+}
+
+// extensionAttrJSON is the JSON form of an extension attribute
+type extensionAttrJSON struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+}
+
+// newExtensionsJSON writes each of the extra elements as an XML string
+func newExtensionsJSON(extra []RawElement, attrs ExtraAttrs) (extensionsJSON, error) {
+	var v extensionsJSON
+
+	for _, element := range extra {
+		data, err := xml.Marshal(element)
+
+		if err != nil {
+			return v, err
+		}
+
+		v.Extension = append(v.Extension, string(data))
+	}
+
+	for _, attr := range attrs {
+		v.ExtensionAttr = append(v.ExtensionAttr, extensionAttrJSON{Namespace: attr.Name.Space, Name: attr.Name.Local, Value: attr.Value})
+	}
+
+	return v, nil
+}
+
+// decode returns back the extra elements and attributes parsed from their JSON form
+func (v extensionsJSON) decode() ([]RawElement, ExtraAttrs, error) {
+	var extra []RawElement
+	var attrs ExtraAttrs
+
+	for _, data := range v.Extension {
+		var element RawElement
+
+		if err := xml.Unmarshal([]byte(data), &element); err != nil {
+			return nil, nil, err
+		}
+
+		extra = append(extra, element)
+	}
+
+	for _, attr := range v.ExtensionAttr {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Space: attr.Namespace, Local: attr.Name}, Value: attr.Value})
+	}
+
+	return extra, attrs, nil
+}
+
+// namedValueJSON is the JSON form of a NamedValue
+type namedValueJSON struct {
+	ValueName string `json:"valueName"`
+	Value     string `json:"value"`
+}
+
+// MarshalJSON encodes the Alert in its JSON form, see JSONSchema
+func (alert Alert) MarshalJSON() ([]byte, error) {
+	extensions, err := newExtensionsJSON(alert.Extra, alert.ExtraAttrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(alertJSON{
+		MessageID:      alert.MessageID,
+		SenderID:       alert.SenderID,
+		SentDate:       alert.SentDate,
+		MessageStatus:  alert.MessageStatus,
+		MessageType:    alert.MessageType,
+		Source:         alert.Source,
+		Scope:          alert.Scope,
+		Restriction:    alert.Restriction,
+		Addresses:      alert.Addresses,
+		HandlingCodes:  alert.handlingCodes(),
+		Note:           alert.Note,
+		ReferenceIDs:   strings.Join(alert.ReferenceIDs, " "),
+		IncidentIDs:    strings.Join(alert.IncidentIDs, " "),
+		Infos:          alert.Infos,
+		extensionsJSON: extensions,
+	})
+}
+
+// UnmarshalJSON decodes the Alert from its JSON form
+func (alert *Alert) UnmarshalJSON(data []byte) error {
+	var v alertJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	extra, attrs, err := v.decode()
+
+	if err != nil {
+		return err
+	}
+
+	*alert = Alert{
+		XMLName:       alert.XMLName,
+		MessageID:     v.MessageID,
+		SenderID:      v.SenderID,
+		SentDate:      v.SentDate,
+		MessageStatus: v.MessageStatus,
+		MessageType:   v.MessageType,
+		Source:        v.Source,
+		Scope:         v.Scope,
+		Restriction:   v.Restriction,
+		Addresses:     v.Addresses,
+		HandlingCodes: v.HandlingCodes,
+		HandlingCode:  firstOf(v.HandlingCodes),
+		Note:          v.Note,
+		Infos:         v.Infos,
+		Extra:         extra,
+		ExtraAttrs:    attrs,
+	}
+
+	if v.ReferenceIDs != "" {
+		alert.ReferenceIDs = []string{v.ReferenceIDs}
+	}
+
+	if v.IncidentIDs != "" {
+		alert.IncidentIDs = []string{v.IncidentIDs}
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the Info in its JSON form
+func (info Info) MarshalJSON() ([]byte, error) {
+	extensions, err := newExtensionsJSON(info.Extra, info.ExtraAttrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(infoJSON{
+		Language:         info.Language,
+		EventCategory:    info.EventCategory,
+		EventType:        info.EventType,
+		ResponseType:     info.ResponseType,
+		Urgency:          info.Urgency,
+		Severity:         info.Severity,
+		Certainty:        info.Certainty,
+		Audience:         info.Audience,
+		EventCode:        info.EventCode,
+		EffectiveDate:    info.EffectiveDate,
+		ExpiresDate:      info.ExpiresDate,
+		OnsetDate:        info.OnsetDate,
+		SenderName:       info.SenderName,
+		Headline:         info.Headline,
+		EventDescription: info.EventDescription,
+		Instruction:      info.Instruction,
+		InformationURL:   info.InformationURL,
+		ContactInfo:      info.ContactInfo,
+		Parameters:       info.Parameters,
+		Areas:            info.Areas,
+		Resources:        info.Resources,
+		extensionsJSON:   extensions,
+	})
+}
+
+// UnmarshalJSON decodes the Info from its JSON form
+func (info *Info) UnmarshalJSON(data []byte) error {
+	var v infoJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	extra, attrs, err := v.decode()
+
+	if err != nil {
+		return err
+	}
+
+	*info = Info{
+		XMLName:          info.XMLName,
+		Language:         v.Language,
+		EventCategory:    v.EventCategory,
+		EventType:        v.EventType,
+		ResponseType:     v.ResponseType,
+		Urgency:          v.Urgency,
+		Severity:         v.Severity,
+		Certainty:        v.Certainty,
+		Audience:         v.Audience,
+		EventCode:        v.EventCode,
+		EffectiveDate:    v.EffectiveDate,
+		ExpiresDate:      v.ExpiresDate,
+		OnsetDate:        v.OnsetDate,
+		SenderName:       v.SenderName,
+		Headline:         v.Headline,
+		EventDescription: v.EventDescription,
+		Instruction:      v.Instruction,
+		InformationURL:   v.InformationURL,
+		ContactInfo:      v.ContactInfo,
+		Parameters:       v.Parameters,
+		Areas:            v.Areas,
+		Resources:        v.Resources,
+		Extra:            extra,
+		ExtraAttrs:       attrs,
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the Resource in its JSON form
+func (r Resource) MarshalJSON() ([]byte, error) {
+	extensions, err := newExtensionsJSON(r.Extra, r.ExtraAttrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(resourceJSON{
+		Description:      r.Description,
+		MIMEType:         r.MIMEType,
+		FileSize:         r.FileSize,
+		URI:              r.URI,
+		DeereferencedURI: r.DeereferencedURI,
+		Digest:           r.Digest,
+		extensionsJSON:   extensions,
+	})
+}
+
+// UnmarshalJSON decodes the Resource from its JSON form
+func (r *Resource) UnmarshalJSON(data []byte) error {
+	var v resourceJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	extra, attrs, err := v.decode()
+
+	if err != nil {
+		return err
+	}
+
+	*r = Resource{
+		XMLName:          r.XMLName,
+		Description:      v.Description,
+		MIMEType:         v.MIMEType,
+		FileSize:         v.FileSize,
+		URI:              v.URI,
+		DeereferencedURI: v.DeereferencedURI,
+		Digest:           v.Digest,
+		Extra:            extra,
+		ExtraAttrs:       attrs,
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the Area in its JSON form
+func (a Area) MarshalJSON() ([]byte, error) {
+	extensions, err := newExtensionsJSON(a.Extra, a.ExtraAttrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(areaJSON{
+		Description:    a.Description,
+		Polygons:       a.polygons(),
+		Circles:        a.circles(),
+		Geocodes:       a.Geocodes,
+		Altitude:       a.Altitude,
+		Ceiling:        a.Ceiling,
+		extensionsJSON: extensions,
+	})
+}
+
+// UnmarshalJSON decodes the Area from its JSON form
+func (a *Area) UnmarshalJSON(data []byte) error {
+	var v areaJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	extra, attrs, err := v.decode()
+
+	if err != nil {
+		return err
+	}
+
+	*a = Area{
+		XMLName:     a.XMLName,
+		Description: v.Description,
+		Polygons:    v.Polygons,
+		Circles:     v.Circles,
+		Polygon:     firstOf(v.Polygons),
+		Circle:      firstOf(v.Circles),
+		Geocodes:    v.Geocodes,
+		Altitude:    v.Altitude,
+		Ceiling:     v.Ceiling,
+		Extra:       extra,
+		ExtraAttrs:  attrs,
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the NamedValue in its JSON form
+func (nv NamedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(namedValueJSON(nv))
+}
+
+// UnmarshalJSON decodes the NamedValue from its JSON form
+func (nv *NamedValue) UnmarshalJSON(data []byte) error {
+	var v namedValueJSON
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*nv = NamedValue(v)
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package cap
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSONUsesCAPElementNames(t *testing.T) {
+	alert, err := getCAPAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(alert)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, decoded["msgType"], "Alert", "msgType does not match!")
+	assertEqual(t, decoded["sent"], "2015-08-15T20:45:00-05:00", "sent does not match!")
+
+	info := decoded["info"].([]interface{})[0].(map[string]interface{})
+	assertEqual(t, info["event"], "Flood Warning", "event does not match!")
+
+	geocode := info["area"].([]interface{})[0].(map[string]interface{})["geocode"].([]interface{})[0].(map[string]interface{})
+	assertEqual(t, geocode["valueName"], "FIPS6", "geocode valueName does not match!")
+	assertEqual(t, geocode["value"], "005067", "geocode value does not match!")
+
+	if strings.Contains(string(data), "XMLName") || strings.Contains(string(data), "DeereferencedURI") {
+		t.Error("Go field names should not appear in the JSON form")
+	}
+}
+
+func TestJSONRoundTripsThroughXML(t *testing.T) {
+	alert, err := getCAPAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := alert.Alert
+	original.ReferenceIDs = []string{"a@example.com,1,2015-08-15T20:45:00-05:00 a@example.com,2,2015-08-15T21:45:00-05:00"}
+	original.Infos[0].Resources = []Resource{{Description: "Map", MIMEType: "image/png", DeereferencedURI: "aGVsbG8="}}
+
+	data, err := json.Marshal(&original)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromJSON Alert
+
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	xmlData, err := xml.Marshal(&fromJSON)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roundTripped, err := ParseAlert(xmlData)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(clearXMLNames(original), clearXMLNames(*roundTripped)) {
+		t.Error("Alert did not survive the XML -> JSON -> XML round trip")
+	}
+}
+
+func TestJSONKeepsExtensions(t *testing.T) {
+	original, err := ParseAlert([]byte(extraAlert))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(original)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	extension := decoded["extension"].([]interface{})
+
+	assertEqual(t, extension[0], `<futureField xmlns="urn:oasis:names:tc:emergency:cap:1.2">value</futureField>`, "Extensions should be written as XML strings")
+	assertStartsWith(t, extension[1].(string), `<Signature xmlns="http://www.w3.org/2000/09/xmldsig#">`, "The signature should be written as an XML string")
+
+	var fromJSON Alert
+
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	xmlData, err := xml.Marshal(&fromJSON)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roundTripped, err := ParseAlert(xmlData)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, extraNames(roundTripped.Infos[0].Areas[0].Extra), "shape", "Area extensions should survive the round trip")
+	assertEqual(t, roundTripped.ExtraAttrs[0], xml.Attr{Name: xml.Name{Space: "urn:example:vendor", Local: "channel"}, Value: "relay"}, "Alert attributes should survive the round trip")
+
+	if !reflect.DeepEqual(clearXMLNames(*original), clearXMLNames(*roundTripped)) {
+		t.Error("Extensions did not survive the XML -> JSON -> XML round trip")
+	}
+}
+
+func TestJSONSchemaDescribesEveryProperty(t *testing.T) {
+	var schema struct {
+		Properties map[string]interface{} `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"$defs"`
+	}
+
+	if err := json.Unmarshal([]byte(JSONSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(properties map[string]interface{}, v interface{}) {
+		data, _ := json.Marshal(v)
+		var decoded map[string]interface{}
+		json.Unmarshal(data, &decoded)
+
+		for name := range decoded {
+			if _, ok := properties[name]; !ok {
+				t.Errorf("Property %q is missing from the schema", name)
+			}
+		}
+	}
+
+	extra := []RawElement{{Tokens: []xml.Token{xml.StartElement{Name: xml.Name{Local: "x"}}, xml.EndElement{Name: xml.Name{Local: "x"}}}}}
+	attrs := ExtraAttrs{{Name: xml.Name{Local: "x"}}}
+
+	full := Info{
+		Language: "en-US", EventCategory: "Met", EventType: "x", ResponseType: "x", Urgency: "x",
+		Severity: "x", Certainty: "x", Audience: "x", EventCode: []NamedValue{{}}, EffectiveDate: "x",
+		ExpiresDate: "x", OnsetDate: "x", SenderName: "x", Headline: "x", EventDescription: "x",
+		Instruction: "x", InformationURL: "x", ContactInfo: "x", Parameters: []NamedValue{{}},
+		Areas: []Area{{}}, Resources: []Resource{{}}, Extra: extra, ExtraAttrs: attrs,
+	}
+
+	check(schema.Properties, Alert{
+		MessageID: "x", SenderID: "x", SentDate: "x", MessageStatus: "x", MessageType: "x", Source: "x",
+		Scope: "x", Restriction: "x", Addresses: "x", HandlingCodes: []string{"x"}, Note: "x",
+		ReferenceIDs: []string{"x"}, IncidentIDs: []string{"x"}, Infos: []Info{full}, Extra: extra, ExtraAttrs: attrs,
+	})
+	check(schema.Defs["info"].Properties, full)
+	check(schema.Defs["area"].Properties, Area{Description: "x", Polygons: []string{"x"}, Circles: []string{"x"}, Geocodes: []NamedValue{{}}, Altitude: "x", Ceiling: "x", Extra: extra, ExtraAttrs: attrs})
+	check(schema.Defs["resource"].Properties, Resource{Description: "x", MIMEType: "x", FileSize: "x", URI: "x", DeereferencedURI: "x", Digest: "x", Extra: extra, ExtraAttrs: attrs})
+	check(schema.Defs["namedValue"].Properties, NamedValue{"x", "x"})
+}
+
+func TestJSONSchemaEnumsMatchValidation(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Enum []string `json:"enum"`
+			} `json:"properties"`
+		} `json:"$defs"`
+	}
+
+	if err := json.Unmarshal([]byte(JSONSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	info := schema.Defs["info"].Properties
+
+	assertEqual(t, strings.Join(info["urgency"].Enum, ","), strings.Join(UrgencyValues, ","), "Urgency values do not match!")
+	assertEqual(t, strings.Join(info["severity"].Enum, ","), strings.Join(SeverityValues, ","), "Severity values do not match!")
+	assertEqual(t, strings.Join(info["certainty"].Enum, ","), strings.Join(CertaintyValues, ","), "Certainty values do not match!")
+}
+
+// clearXMLNames returns back a copy of the alert without the XMLName fields set by the XML decoder
+func clearXMLNames(alert Alert) Alert {
+	alert.XMLName = xml.Name{}
+	infos := make([]Info, len(alert.Infos))
+
+	for i, info := range alert.Infos {
+		info.XMLName = xml.Name{}
+		info.Areas = append([]Area(nil), info.Areas...)
+		info.Resources = append([]Resource(nil), info.Resources...)
+
+		for j := range info.Areas {
+			info.Areas[j].XMLName = xml.Name{}
+		}
+
+		for j := range info.Resources {
+			info.Resources[j].XMLName = xml.Name{}
+		}
+
+		infos[i] = info
+	}
+
+	alert.Infos = infos
+
+	return alert
+}
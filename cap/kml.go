@@ -0,0 +1,229 @@
+package cap
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// KML is a KML 2.2 document
+type KML struct {
+	XMLName  xml.Name    `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document KMLDocument `xml:"Document"`
+}
+
+// KMLDocument holds the shared styles and a folder for every alert
+type KMLDocument struct {
+	Name    string      `xml:"name,omitempty"`
+	Styles  []KMLStyle  `xml:"Style"`
+	Folders []KMLFolder `xml:"Folder"`
+}
+
+// KMLStyle colors the outline and fill of placemarks, as aabbggrr hex
+type KMLStyle struct {
+	ID        string  `xml:"id,attr"`
+	LineColor string  `xml:"LineStyle>color"`
+	LineWidth float64 `xml:"LineStyle>width"`
+	PolyColor string  `xml:"PolyStyle>color"`
+}
+
+// KMLFolder holds the placemarks of one alert
+type KMLFolder struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	Placemarks  []KMLPlacemark `xml:"Placemark"`
+}
+
+// KMLPlacemark is one area of an Info block
+type KMLPlacemark struct {
+	Name        string            `xml:"name"`
+	Description string            `xml:"description,omitempty"`
+	TimeSpan    *KMLTimeSpan      `xml:"TimeSpan,omitempty"`
+	StyleURL    string            `xml:"styleUrl"`
+	Data        []KMLData         `xml:"ExtendedData>Data"`
+	Geometry    *KMLMultiGeometry `xml:"MultiGeometry,omitempty"`
+}
+
+// KMLTimeSpan is the period a placemark is shown in time-aware viewers
+type KMLTimeSpan struct {
+	Begin string `xml:"begin,omitempty"`
+	End   string `xml:"end,omitempty"`
+}
+
+// KMLData is a named value shown in a placemark's balloon
+type KMLData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+// KMLMultiGeometry holds the polygons of a placemark
+type KMLMultiGeometry struct {
+	Polygons []KMLPolygon `xml:"Polygon"`
+}
+
+// KMLPolygon is an outer boundary followed by any holes
+type KMLPolygon struct {
+	Outer KMLBoundary   `xml:"outerBoundaryIs"`
+	Inner []KMLBoundary `xml:"innerBoundaryIs"`
+}
+
+// KMLBoundary is a ring of "longitude,latitude" coordinates separated by spaces
+type KMLBoundary struct {
+	Coordinates string `xml:"LinearRing>coordinates"`
+}
+
+// kmlSeverityColors are the aabbggrr outline colors of each severity; fills
+// use the same color at 40% opacity
+var kmlSeverityColors = []struct {
+	severity string
+	color    string
+}{
+	{"Extreme", "ff0000ff"},  // red
+	{"Severe", "ff0080ff"},   // orange
+	{"Moderate", "ff00ffff"}, // yellow
+	{"Minor", "ff00c000"},    // green
+	{"Unknown", "ff808080"},  // gray
+}
+
+// kmlStyleID returns back the style of an Info block's severity, Unknown for unrecognized values
+func kmlStyleID(severity string) string {
+	for _, s := range kmlSeverityColors {
+		if strings.EqualFold(s.severity, severity) {
+			return "severity-" + strings.ToLower(s.severity)
+		}
+	}
+
+	return "severity-unknown"
+}
+
+// AlertsKML returns back a KML document with a folder for every alert and a
+// placemark for every area of every Info block
+//
+// Placemarks are styled by the severity of their Info block, from red for
+// Extreme to gray for Unknown, and carry its event, urgency, severity,
+// certainty and headline. The area's polygons and circles, or its geocode
+// boundaries without them, are combined as in Area.Geometry, so circles become
+// 64-sided polygons.
+// Placemarks span the Info block's effective date, or its onset without one,
+// to its expiry.
+func AlertsKML(alerts []*Alert) *KML {
+	doc := &KML{}
+
+	for _, s := range kmlSeverityColors {
+		doc.Document.Styles = append(doc.Document.Styles, KMLStyle{
+			ID:        "severity-" + strings.ToLower(s.severity),
+			LineColor: s.color,
+			LineWidth: 2,
+			PolyColor: "66" + s.color[2:],
+		})
+	}
+
+	for _, alert := range alerts {
+		doc.Document.Folders = append(doc.Document.Folders, alert.kmlFolder())
+	}
+
+	if len(alerts) == 1 {
+		doc.Document.Name = doc.Document.Folders[0].Name
+	}
+
+	return doc
+}
+
+// KML returns back a KML document of the alert's areas, see AlertsKML
+func (alert *Alert) KML() *KML {
+	return AlertsKML([]*Alert{alert})
+}
+
+// MarshalKML returns back the indented KML encoding of the alert's areas, with an XML header
+func (alert *Alert) MarshalKML() ([]byte, error) {
+	data, err := xml.MarshalIndent(alert.KML(), "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+// kmlFolder returns back the folder of an alert's placemarks, named after its first headline or event
+func (alert *Alert) kmlFolder() KMLFolder {
+	folder := KMLFolder{Name: alert.MessageID, Description: alert.Note}
+
+	for i := range alert.Infos {
+		info := &alert.Infos[i]
+
+		if i == 0 {
+			switch {
+			case info.Headline != "":
+				folder.Name = info.Headline
+			case info.EventType != "":
+				folder.Name = info.EventType
+			}
+		}
+
+		for j := range info.Areas {
+			folder.Placemarks = append(folder.Placemarks, info.kmlPlacemark(alert, &info.Areas[j]))
+		}
+	}
+
+	return folder
+}
+
+func (info *Info) kmlPlacemark(alert *Alert, area *Area) KMLPlacemark {
+	placemark := KMLPlacemark{
+		Name:        area.Description,
+		Description: info.EventDescription,
+		StyleURL:    "#" + kmlStyleID(info.Severity),
+	}
+
+	for _, d := range []KMLData{
+		{"identifier", alert.MessageID},
+		{"sender", alert.SenderID},
+		{"sent", alert.SentDate},
+		{"event", info.EventType},
+		{"urgency", info.Urgency},
+		{"severity", info.Severity},
+		{"certainty", info.Certainty},
+		{"headline", info.Headline},
+	} {
+		if d.Value != "" {
+			placemark.Data = append(placemark.Data, d)
+		}
+	}
+
+	begin := info.EffectiveDate
+
+	if begin == "" {
+		begin = info.OnsetDate
+	}
+
+	if begin != "" || info.ExpiresDate != "" {
+		placemark.TimeSpan = &KMLTimeSpan{Begin: begin, End: info.ExpiresDate}
+	}
+
+	if geometry := area.Geometry(); len(geometry) > 0 {
+		placemark.Geometry = &KMLMultiGeometry{}
+
+		for _, polygon := range geometry {
+			p := KMLPolygon{Outer: kmlBoundary(polygon[0])}
+
+			for _, hole := range polygon[1:] {
+				p.Inner = append(p.Inner, kmlBoundary(hole))
+			}
+
+			placemark.Geometry.Polygons = append(placemark.Geometry.Polygons, p)
+		}
+	}
+
+	return placemark
+}
+
+func kmlBoundary(r Ring) KMLBoundary {
+	coordinates := make([]string, len(r))
+
+	for i, pos := range r {
+		coordinates[i] = strconv.FormatFloat(pos[0], 'f', -1, 64) + "," + strconv.FormatFloat(pos[1], 'f', -1, 64)
+	}
+
+	return KMLBoundary{Coordinates: strings.Join(coordinates, " ")}
+}
@@ -0,0 +1,80 @@
+package cap
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestAlertKML(t *testing.T) {
+	alert := &Alert{
+		MessageID: "KSTO1055887203",
+		SenderID:  "KSTO@NWS.NOAA.GOV",
+		Infos: []Info{
+			{
+				EventType:     "Tornado Warning",
+				Severity:      "Extreme",
+				Headline:      "Tornado Warning for Sacramento County",
+				EffectiveDate: "2003-06-17T14:57:00-07:00",
+				ExpiresDate:   "2003-06-17T16:00:00-07:00",
+				Areas: []Area{
+					{Description: "Polygon", Polygons: []string{"38.47,-120.14 38.34,-119.95 38.52,-119.74 38.62,-119.89 38.47,-120.14"}},
+					{Description: "Circle", Circles: []string{"38.5,-121.5 10"}},
+				},
+			},
+			{
+				EventType: "Flood Watch",
+				Severity:  "moderate",
+				Areas:     []Area{{Description: "Nowhere"}},
+			},
+		},
+	}
+
+	doc := alert.KML()
+
+	assertEqual(t, doc.Document.Name, "Tornado Warning for Sacramento County", "The document should be named after the headline")
+	assertEqual(t, len(doc.Document.Styles), 5, "Every severity should have a style")
+	assertEqual(t, doc.Document.Styles[0].PolyColor, "660000ff", "Extreme fills should be translucent red")
+
+	placemarks := doc.Document.Folders[0].Placemarks
+
+	assertEqual(t, len(placemarks), 3, "Every area should be a placemark")
+	assertEqual(t, placemarks[0].StyleURL, "#severity-extreme", "Placemarks should be styled by severity")
+	assertEqual(t, placemarks[2].StyleURL, "#severity-moderate", "Severities should match regardless of case")
+	assertEqual(t, placemarks[0].TimeSpan.End, "2003-06-17T16:00:00-07:00", "The placemark should end when the info expires")
+	assertStartsWith(t, placemarks[0].Geometry.Polygons[0].Outer.Coordinates, "-120.14,38.47 ", "Coordinates should be longitude first")
+	assertEqual(t, len(strings.Fields(placemarks[1].Geometry.Polygons[0].Outer.Coordinates)), circleSegments+1, "Circles should be buffered polygons")
+
+	if placemarks[2].Geometry != nil || placemarks[2].TimeSpan != nil {
+		t.Error("Areas without shapes should have no geometry")
+	}
+
+	data, err := alert.MarshalKML()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertStartsWith(t, string(data), xml.Header+`<kml xmlns="http://www.opengis.net/kml/2.2">`, "Unexpected KML root")
+
+	if !strings.Contains(string(data), `<Data name="event">`) {
+		t.Error("Placemarks should carry their event")
+	}
+
+	var decoded KML
+
+	if err := xml.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(decoded.Document.Folders[0].Placemarks), 3, "The KML should decode")
+}
+
+func TestAlertsKML(t *testing.T) {
+	doc := AlertsKML([]*Alert{{MessageID: "1"}, {MessageID: "2", Infos: []Info{{EventType: "Flood Watch"}}}})
+
+	assertEqual(t, doc.Document.Name, "", "Documents of several alerts are not named")
+	assertEqual(t, len(doc.Document.Folders), 2, "Every alert should be a folder")
+	assertEqual(t, doc.Document.Folders[0].Name, "1", "Alerts without infos should be named after their identifier")
+	assertEqual(t, doc.Document.Folders[1].Name, "Flood Watch", "Alerts without headlines should be named after their event")
+}
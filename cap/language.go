@@ -0,0 +1,172 @@
+package cap
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLanguage is the language of an Info block that has no language element
+var DefaultLanguage = language.AmericanEnglish
+
+// LanguageTag returns back the BCP 47 language of the Info block
+//
+// Info blocks without a language element are in DefaultLanguage. A language
+// element that is not a valid BCP 47 tag returns language.Und.
+func (info *Info) LanguageTag() language.Tag {
+	if strings.TrimSpace(info.Language) == "" {
+		return DefaultLanguage
+	}
+
+	tag, err := language.Parse(strings.TrimSpace(info.Language))
+
+	if err != nil {
+		return language.Und
+	}
+
+	return tag
+}
+
+// Languages returns back the distinct languages of the alert's Info blocks in document order
+func (alert *Alert) Languages() []language.Tag {
+	var (
+		found []language.Tag
+		seen  = make(map[language.Tag]bool)
+	)
+
+	for i := range alert.Infos {
+		tag := alert.Infos[i].LanguageTag()
+
+		if !seen[tag] {
+			seen[tag] = true
+			found = append(found, tag)
+		}
+	}
+
+	return found
+}
+
+// InfoGroups groups the alert's Info blocks that describe the same event in different languages
+//
+// Translations of an Info block share its category, urgency, severity,
+// certainty, event codes, effective, onset and expires dates, and the
+// polygons, circles and geocodes of its areas, so Info blocks are grouped on
+// those values. The event and area descriptions are translated, so they cannot
+// be compared. A group never holds two Info blocks in the same language: a
+// second one describes a different event and starts a new group. Groups are
+// returned in the order their first Info block appears, and each group keeps
+// document order.
+func (alert *Alert) InfoGroups() [][]*Info {
+	var (
+		groups [][]*Info
+		index  = make(map[string][]int)
+	)
+
+	for i := range alert.Infos {
+		info := &alert.Infos[i]
+		key := infoGroupKey(info)
+		found := false
+
+		for _, g := range index[key] {
+			if !groupHasLanguage(groups[g], info.LanguageTag()) {
+				groups[g] = append(groups[g], info)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			index[key] = append(index[key], len(groups))
+			groups = append(groups, []*Info{info})
+		}
+	}
+
+	return groups
+}
+
+func groupHasLanguage(group []*Info, tag language.Tag) bool {
+	for _, info := range group {
+		if info.LanguageTag() == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func infoGroupKey(info *Info) string {
+	parts := []string{
+		info.EventCategory, info.Urgency, info.Severity, info.Certainty,
+		info.EffectiveDate, info.OnsetDate, info.ExpiresDate,
+	}
+
+	for _, code := range info.EventCode {
+		parts = append(parts, code.ValueName+"="+code.Value)
+	}
+
+	for _, area := range info.Areas {
+		parts = append(parts, "area")
+		parts = append(parts, area.polygons()...)
+		parts = append(parts, "circles")
+		parts = append(parts, area.circles()...)
+
+		for _, geocode := range area.Geocodes {
+			parts = append(parts, geocode.ValueName+"="+geocode.Value)
+		}
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+// InfoFor returns back the Info block best matching the preferred languages for each event in the alert
+//
+// The preferred languages are given in order of preference. Matching follows
+// BCP 47 fallback rules, so a preference for es-MX selects an es-US block. When
+// no block in a group matches, the block in DefaultLanguage is returned, or the
+// first block of the group if there is none. Without any preferred languages,
+// DefaultLanguage is preferred.
+func (alert *Alert) InfoFor(langs ...language.Tag) []*Info {
+	if len(langs) == 0 {
+		langs = []language.Tag{DefaultLanguage}
+	}
+
+	var found []*Info
+
+	for _, group := range alert.InfoGroups() {
+		found = append(found, bestInfo(group, langs))
+	}
+
+	return found
+}
+
+// bestInfo selects the Info block in a group that best matches the preferred languages
+func bestInfo(group []*Info, langs []language.Tag) *Info {
+	if len(group) == 1 {
+		return group[0]
+	}
+
+	// The matcher falls back to its first supported tag, so list the default language first
+	ordered := make([]*Info, 0, len(group))
+
+	for _, info := range group {
+		if info.LanguageTag() == DefaultLanguage {
+			ordered = append(ordered, info)
+		}
+	}
+
+	for _, info := range group {
+		if info.LanguageTag() != DefaultLanguage {
+			ordered = append(ordered, info)
+		}
+	}
+
+	supported := make([]language.Tag, len(ordered))
+
+	for i, info := range ordered {
+		supported[i] = info.LanguageTag()
+	}
+
+	_, index, _ := language.NewMatcher(supported).Match(langs...)
+
+	return ordered[index]
+}
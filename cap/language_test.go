@@ -0,0 +1,86 @@
+package cap
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func getMultilingualAlertExample() *Alert {
+	return &Alert{Infos: []Info{
+		{EventType: "Flood Warning", Severity: "Moderate", ExpiresDate: "2015-08-16T11:45:00-05:00"},
+		{Language: "es-US", EventType: "Aviso de Inundación", Severity: "Moderate", ExpiresDate: "2015-08-16T11:45:00-05:00"},
+		{Language: "fr-CA", EventType: "Avertissement d'inondation", Severity: "Moderate", ExpiresDate: "2015-08-16T11:45:00-05:00"},
+		{Language: "en-US", EventType: "Tornado Watch", Severity: "Severe"},
+		{Language: "es-US", EventType: "Vigilancia de Tornado", Severity: "Severe"},
+	}}
+}
+
+func TestInfoLanguageTagDefaultsToEnUS(t *testing.T) {
+	var info Info
+
+	assertEqual(t, info.LanguageTag(), language.AmericanEnglish, "Missing language should default to en-US")
+
+	info.Language = "not a language tag"
+	assertEqual(t, info.LanguageTag(), language.Und, "Invalid language should be undetermined")
+}
+
+func TestAlertLanguagesReturnsDistinctTags(t *testing.T) {
+	langs := getMultilingualAlertExample().Languages()
+
+	assertEqual(t, len(langs), 3, "Three languages should be found")
+	assertEqual(t, langs[0], language.AmericanEnglish, "The first language does not match!")
+	assertEqual(t, langs[2], language.CanadianFrench, "The last language does not match!")
+}
+
+func TestAlertInfoGroupsGroupsTranslations(t *testing.T) {
+	groups := getMultilingualAlertExample().InfoGroups()
+
+	assertEqual(t, len(groups), 2, "Two events should be found")
+	assertEqual(t, len(groups[0]), 3, "The flood warning has three translations")
+	assertEqual(t, len(groups[1]), 2, "The tornado watch has two translations")
+}
+
+func TestAlertInfoGroupsSeparatesEventsInTheSameLanguage(t *testing.T) {
+	alert := &Alert{Infos: []Info{
+		{Language: "en-US", EventType: "Flood Warning", Severity: "Moderate"},
+		{Language: "en-US", EventType: "Flash Flood Warning", Severity: "Moderate"},
+		{Language: "es-US", EventType: "Aviso de Inundación", Severity: "Moderate"},
+	}}
+
+	groups := alert.InfoGroups()
+
+	assertEqual(t, len(groups), 2, "Events in the same language should not be grouped")
+	assertEqual(t, len(groups[0]), 2, "The translation should join the first event")
+
+	english := alert.InfoFor(language.AmericanEnglish)
+
+	assertEqual(t, len(english), 2, "Both events should be returned")
+	assertEqual(t, english[0].EventType, "Flood Warning", "The first event does not match!")
+	assertEqual(t, english[1].EventType, "Flash Flood Warning", "The second event does not match!")
+}
+
+func TestAlertInfoGroupsComparesAreas(t *testing.T) {
+	alert := &Alert{Infos: []Info{
+		{EventType: "Flood Warning", Areas: []Area{{Description: "Pulaski", Geocodes: []NamedValue{{ValueName: "SAME", Value: "005119"}}}}},
+		{Language: "es-US", EventType: "Aviso de Inundación", Areas: []Area{{Description: "Saline", Geocodes: []NamedValue{{ValueName: "SAME", Value: "005125"}}}}},
+	}}
+
+	assertEqual(t, len(alert.InfoGroups()), 2, "Info blocks for different areas are not translations")
+}
+
+func TestAlertInfoForSelectsPreferredLanguage(t *testing.T) {
+	alert := getMultilingualAlertExample()
+
+	spanish := alert.InfoFor(language.MustParse("es-MX"))
+	assertEqual(t, len(spanish), 2, "One Info per event should be returned")
+	assertEqual(t, spanish[0].EventType, "Aviso de Inundación", "Spanish flood warning should be selected")
+	assertEqual(t, spanish[1].EventType, "Vigilancia de Tornado", "Spanish tornado watch should be selected")
+
+	french := alert.InfoFor(language.French)
+	assertEqual(t, french[0].EventType, "Avertissement d'inondation", "French flood warning should be selected")
+	assertEqual(t, french[1].EventType, "Tornado Watch", "Missing French should fall back to en-US")
+
+	defaults := alert.InfoFor()
+	assertEqual(t, defaults[0].EventType, "Flood Warning", "en-US should be selected by default")
+}
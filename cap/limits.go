@@ -0,0 +1,192 @@
+package cap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Limits bound the resources a document may use while it is parsed, to protect
+// against hostile input from the public internet
+//
+// Infos, areas, resources and parameters are counted for each alert or feed
+// entry, so feeds and envelopes may hold many alerts; entries are counted for
+// the whole document. A zero value disables that limit.
+type Limits struct {
+	MaxDocumentSize int64
+	MaxDepth        int
+	MaxInfos        int
+	MaxAreas        int
+	MaxResources    int
+	MaxParameters   int
+	MaxEntries      int
+	MaxAttributes   int
+
+	// MaxTokenLength bounds the length of text, attribute values, comments and
+	// processing instructions
+	MaxTokenLength int
+}
+
+// DefaultLimits are the limits used by ParseAlert, ParseAlert11, ParseAlertWithOptions,
+// ParseDistribution, ExtractAlerts, the Decoder, FileArchive and the Atom feed functions
+var DefaultLimits = Limits{
+	MaxDocumentSize: MaxFeedSize,
+	MaxDepth:        32,
+	MaxInfos:        64,
+	MaxAreas:        1024,
+	MaxResources:    64,
+	MaxParameters:   1024,
+	MaxEntries:      10000,
+	MaxAttributes:   32,
+	MaxTokenLength:  1024 * 1024,
+}
+
+// ErrDTDNotAllowed is returned for documents with a DOCTYPE or entity declaration
+var ErrDTDNotAllowed = errors.New("DOCTYPE and entity declarations are not allowed")
+
+// LimitError is returned when a document exceeds one of its Limits
+type LimitError struct {
+	// Limit is the name of the exceeded field of Limits, such as MaxDepth
+	Limit string
+	Max   int64
+
+	// Offset is the byte offset where the limit was exceeded
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Document exceeds %s of %d at byte %d", e.Limit, e.Max, e.Offset)
+}
+
+// elementLimit is the limit on the number of elements with a given name
+type elementLimit struct {
+	name string
+	max  int
+}
+
+// limitedElements maps the local names of counted elements to their limits
+func (l Limits) limitedElements() map[string]elementLimit {
+	return map[string]elementLimit{
+		"info":      {"MaxInfos", l.MaxInfos},
+		"area":      {"MaxAreas", l.MaxAreas},
+		"resource":  {"MaxResources", l.MaxResources},
+		"parameter": {"MaxParameters", l.MaxParameters},
+		"entry":     {"MaxEntries", l.MaxEntries},
+	}
+}
+
+// alertElements are the local names of the elements that start a new count of
+// infos, areas, resources and parameters
+var alertElements = map[string]bool{"alert": true, "entry": true}
+
+// Check returns back a *LimitError if xmlData exceeds the limits, or
+// ErrDTDNotAllowed if it declares a DOCTYPE or entities
+//
+// Malformed XML is not reported; it is left to the parser that follows.
+func (l Limits) Check(xmlData []byte) error {
+	if l.MaxDocumentSize > 0 && int64(len(xmlData)) > l.MaxDocumentSize {
+		return &LimitError{Limit: "MaxDocumentSize", Max: l.MaxDocumentSize, Offset: l.MaxDocumentSize}
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(xmlData))
+	c := newLimitChecker(l)
+
+	for {
+		offset := d.InputOffset()
+		tok, err := d.RawToken()
+
+		// The end of the document, or malformed XML left for the parser to report
+		if err != nil {
+			return nil
+		}
+
+		if err := c.check(tok, offset); err != nil {
+			return err
+		}
+	}
+}
+
+// limitChecker applies Limits to the raw tokens of a document, one at a time
+type limitChecker struct {
+	limits   Limits
+	elements map[string]elementLimit
+	counts   map[string]int
+	depth    int
+}
+
+func newLimitChecker(l Limits) *limitChecker {
+	return &limitChecker{limits: l, elements: l.limitedElements(), counts: make(map[string]int)}
+}
+
+// resetCounts starts counting elements afresh
+func (c *limitChecker) resetCounts() {
+	c.counts = make(map[string]int)
+}
+
+// resetAlertCounts starts counting the elements of a new alert or feed entry afresh
+func (c *limitChecker) resetAlertCounts() {
+	for name := range c.counts {
+		if name != "entry" {
+			delete(c.counts, name)
+		}
+	}
+}
+
+// check returns back an error if tok, which starts at offset, exceeds the limits
+func (c *limitChecker) check(tok xml.Token, offset int64) error {
+	l := c.limits
+	length := 0
+
+	switch t := tok.(type) {
+	case xml.StartElement:
+		c.depth++
+
+		if l.MaxDepth > 0 && c.depth > l.MaxDepth {
+			return &LimitError{Limit: "MaxDepth", Max: int64(l.MaxDepth), Offset: offset}
+		}
+
+		if l.MaxAttributes > 0 && len(t.Attr) > l.MaxAttributes {
+			return &LimitError{Limit: "MaxAttributes", Max: int64(l.MaxAttributes), Offset: offset}
+		}
+
+		if alertElements[t.Name.Local] {
+			c.resetAlertCounts()
+		}
+
+		if limit, ok := c.elements[t.Name.Local]; ok {
+			c.counts[t.Name.Local]++
+
+			if limit.max > 0 && c.counts[t.Name.Local] > limit.max {
+				return &LimitError{Limit: limit.name, Max: int64(limit.max), Offset: offset}
+			}
+		}
+
+		for _, attr := range t.Attr {
+			if len(attr.Value) > length {
+				length = len(attr.Value)
+			}
+		}
+	case xml.EndElement:
+		c.depth--
+	case xml.CharData:
+		length = len(t)
+	case xml.Comment:
+		length = len(t)
+	case xml.ProcInst:
+		length = len(t.Inst)
+	case xml.Directive:
+		directive := strings.ToUpper(strings.TrimSpace(string(t)))
+
+		if strings.HasPrefix(directive, "DOCTYPE") || strings.HasPrefix(directive, "ENTITY") {
+			return ErrDTDNotAllowed
+		}
+	}
+
+	if l.MaxTokenLength > 0 && length > l.MaxTokenLength {
+		return &LimitError{Limit: "MaxTokenLength", Max: int64(l.MaxTokenLength), Offset: offset}
+	}
+
+	return nil
+}
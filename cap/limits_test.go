@@ -0,0 +1,79 @@
+package cap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func assertLimitError(t *testing.T, err error, limit string) {
+	limitErr, ok := err.(*LimitError)
+
+	if !ok {
+		t.Errorf("Expected a *LimitError for %s but got %v", limit, err)
+		return
+	}
+
+	assertEqual(t, limitErr.Limit, limit, "Exceeded limit does not match!")
+}
+
+func TestLimitsRejectHostileDocuments(t *testing.T) {
+	limits := Limits{MaxDocumentSize: 1000, MaxDepth: 4, MaxInfos: 2, MaxAttributes: 2, MaxTokenLength: 100}
+
+	assertLimitError(t, limits.Check([]byte(strings.Repeat(" ", 1001))), "MaxDocumentSize")
+	assertLimitError(t, limits.Check([]byte("<a><b><c><d><e/></d></c></b></a>")), "MaxDepth")
+	assertLimitError(t, limits.Check([]byte("<alert><info/><info/><info/></alert>")), "MaxInfos")
+	assertLimitError(t, limits.Check([]byte(`<alert a="1" b="2" c="3"/>`)), "MaxAttributes")
+	assertLimitError(t, limits.Check([]byte("<alert>"+strings.Repeat("x", 101)+"</alert>")), "MaxTokenLength")
+	assertLimitError(t, limits.Check([]byte(`<alert a="`+strings.Repeat("x", 101)+`"/>`)), "MaxTokenLength")
+
+	if err := limits.Check([]byte("<alert><info/><info/></alert><alert><info/><info/></alert>")); err != nil {
+		t.Errorf("Expected a document within limits to pass but got %v", err)
+	}
+}
+
+func TestParseAlertRejectsDTD(t *testing.T) {
+	billionLaughs := `<?xml version="1.0"?>
+<!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;&lol;&lol;">]>
+<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2"><identifier>&lol2;</identifier></alert>`
+
+	_, err := ParseAlert([]byte(billionLaughs))
+	assertEqual(t, err, ErrDTDNotAllowed, "DOCTYPE should be rejected")
+
+	_, err = ParseAlert11([]byte(billionLaughs))
+	assertEqual(t, err, ErrDTDNotAllowed, "DOCTYPE should be rejected")
+
+	_, err = ParseNWSAtomFeed([]byte(billionLaughs))
+	assertEqual(t, err, ErrDTDNotAllowed, "DOCTYPE should be rejected")
+
+	_, err = ParseAlertWithOptions([]byte(billionLaughs), ParseOptions{})
+	assertEqual(t, err, ErrDTDNotAllowed, "DOCTYPE should be rejected")
+}
+
+func TestParseAlertWithOptionsUsesCustomLimits(t *testing.T) {
+	alert := `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">` + strings.Repeat("<info/>", 3) + `</alert>`
+
+	_, err := ParseAlertWithOptions([]byte(alert), ParseOptions{Limits: &Limits{MaxInfos: 2}})
+	assertLimitError(t, err, "MaxInfos")
+	assertStartsWith(t, err.Error(), "Document exceeds MaxInfos of 2 at byte", "Error message does not match!")
+
+	if _, err := ParseAlertWithOptions([]byte(alert), ParseOptions{}); err != nil {
+		t.Errorf("Expected the default limits to allow %s but got %v", alert, err)
+	}
+}
+
+func TestParseNWSAtomFeedLimitsEntries(t *testing.T) {
+	feed := `<feed xmlns="http://www.w3.org/2005/Atom">` + strings.Repeat("<entry/>", DefaultLimits.MaxEntries+1) + `</feed>`
+
+	_, err := ParseNWSAtomFeed([]byte(feed))
+	assertLimitError(t, err, "MaxEntries")
+
+	entries := strings.Repeat("<entry><cap:parameter><valueName>VTEC</valueName></cap:parameter></entry>", DefaultLimits.MaxParameters+1)
+	feed = `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:cap="urn:oasis:names:tc:emergency:cap:1.1">` + entries + `</feed>`
+
+	_, err = ParseNWSAtomFeed([]byte(feed))
+	assertEqual(t, err, nil, "Parameters should be counted for each entry")
+
+	_, err = ParseNWSAtomFeed([]byte(fmt.Sprintf(`<feed xmlns="http://www.w3.org/2005/Atom"><id>%s</id></feed>`, "x")))
+	assertEqual(t, err, nil, "A small feed should parse")
+}
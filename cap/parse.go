@@ -0,0 +1,345 @@
+package cap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseOptions control how ParseAlertWithOptions reads an alert
+type ParseOptions struct {
+	// Strict rejects unknown elements, repeated single-valued elements,
+	// elements out of the order of the CAP schema, and mandatory elements that
+	// are missing or blank
+	Strict bool
+
+	// AllowExtensions accepts elements outside of the CAP namespace, such as
+	// vendor extensions, in strict mode. XML digital signatures at the end of
+	// the alert are always accepted.
+	AllowExtensions bool
+
+	// Limits bound the size and shape of the document, DefaultLimits when nil
+	Limits *Limits
+}
+
+// ParseError describes where and why an alert could not be parsed
+type ParseError struct {
+	Line   int
+	Column int
+
+	// Path locates the offending element the same way as ValidationError,
+	// empty for the root element
+	Path string
+
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("Line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+
+	return fmt.Sprintf("Line %d, column %d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+// childRule describes an element allowed inside another in the CAP schema
+type childRule struct {
+	name     string
+	single   bool
+	required bool
+
+	// children names the rules of the element's own children, empty for text elements
+	children string
+}
+
+// strictSchema lists the children of each CAP element in schema order
+//
+// Elements the Alert model holds in a single field, such as category and
+// responseType, are single-valued here even where CAP allows repeating them, since the
+// extra values would be silently dropped.
+var strictSchema = map[string][]childRule{
+	"alert": {
+		{name: "identifier", single: true, required: true},
+		{name: "sender", single: true, required: true},
+		{name: "sent", single: true, required: true},
+		{name: "status", single: true, required: true},
+		{name: "msgType", single: true, required: true},
+		{name: "source", single: true},
+		{name: "scope", single: true, required: true},
+		{name: "restriction", single: true},
+		{name: "addresses", single: true},
+		{name: "code"},
+		{name: "note", single: true},
+		{name: "references", single: true},
+		{name: "incidents", single: true},
+		{name: "info", children: "info"},
+	},
+	"info": {
+		{name: "language", single: true},
+		{name: "category", single: true, required: true},
+		{name: "event", single: true, required: true},
+		{name: "responseType", single: true},
+		{name: "urgency", single: true, required: true},
+		{name: "severity", single: true, required: true},
+		{name: "certainty", single: true, required: true},
+		{name: "audience", single: true},
+		{name: "eventCode", children: "namedValue"},
+		{name: "effective", single: true},
+		{name: "onset", single: true},
+		{name: "expires", single: true},
+		{name: "senderName", single: true},
+		{name: "headline", single: true},
+		{name: "description", single: true},
+		{name: "instruction", single: true},
+		{name: "web", single: true},
+		{name: "contact", single: true},
+		{name: "parameter", children: "namedValue"},
+		{name: "resource", children: "resource"},
+		{name: "area", children: "area"},
+	},
+	"resource": {
+		{name: "resourceDesc", single: true, required: true},
+		{name: "mimeType", single: true, required: true},
+		{name: "size", single: true},
+		{name: "uri", single: true},
+		{name: "derefUri", single: true},
+		{name: "digest", single: true},
+	},
+	"area": {
+		{name: "areaDesc", single: true, required: true},
+		{name: "polygon"},
+		{name: "circle"},
+		{name: "geocode", children: "namedValue"},
+		{name: "altitude", single: true},
+		{name: "ceiling", single: true},
+	},
+	"namedValue": {
+		{name: "valueName", single: true, required: true},
+		{name: "value", single: true, required: true},
+	},
+}
+
+// strictFrame tracks an open element during the strict checks
+type strictFrame struct {
+	path     string
+	rules    []childRule
+	required bool
+	line     int
+	column   int
+	last     int
+	counts   map[string]int
+	text     strings.Builder
+}
+
+// ParseAlertWithOptions parses a CAP 1.2 or 1.1 alert
+//
+// The document is first checked against the limits, returning a *LimitError or
+// ErrDTDNotAllowed. Unlike ParseAlert, an empty document or a root element
+// other than a CAP alert is reported as a *ParseError, as are XML syntax
+// errors. In strict mode the document is also checked against the structure of
+// the CAP schema, and the first problem found is returned as a *ParseError.
+func ParseAlertWithOptions(xmlData []byte, opts ParseOptions) (*Alert, error) {
+	limits := DefaultLimits
+
+	if opts.Limits != nil {
+		limits = *opts.Limits
+	}
+
+	if err := limits.Check(xmlData); err != nil {
+		return nil, err
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(xmlData))
+
+	for {
+		line, column := d.InputPos()
+		tok, err := d.Token()
+
+		if err == io.EOF {
+			return nil, &ParseError{Line: line, Column: column, Message: "the document has no root element"}
+		}
+
+		if err != nil {
+			return nil, syntaxParseError(d, err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+
+		if !ok {
+			continue
+		}
+
+		if start.Name.Local != "alert" || (start.Name.Space != CAP12Namespace && start.Name.Space != CAP11Namespace) {
+			return nil, &ParseError{
+				Line:    line,
+				Column:  column,
+				Message: fmt.Sprintf("the root element is %s, expected a CAP 1.2 or 1.1 alert", formatName(start.Name)),
+			}
+		}
+
+		if opts.Strict {
+			if err := checkStrict(d, start, line, column, opts); err != nil {
+				return nil, err
+			}
+
+			// The strict checks consumed the alert, so decode it from a fresh decoder
+			d = xml.NewDecoder(bytes.NewReader(xmlData))
+
+			if err := skipToRoot(d, &start); err != nil {
+				return nil, syntaxParseError(d, err)
+			}
+		}
+
+		alert, err := decodeAlertElement(d, &start)
+
+		if err != nil {
+			return nil, syntaxParseError(d, err)
+		}
+
+		return alert, nil
+	}
+}
+
+// checkStrict walks the alert element that start opens and checks it against strictSchema
+func checkStrict(d *xml.Decoder, start xml.StartElement, line, column int, opts ParseOptions) error {
+	namespace := start.Name.Space
+	stack := []*strictFrame{{rules: strictSchema["alert"], line: line, column: column, last: -1, counts: map[string]int{}}}
+
+	for len(stack) > 0 {
+		line, column := d.InputPos()
+		tok, err := d.Token()
+
+		if err != nil {
+			return syntaxParseError(d, err)
+		}
+
+		top := stack[len(stack)-1]
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != namespace {
+				allowed := opts.AllowExtensions || (len(stack) == 1 && t.Name.Space == XMLDSigNamespace)
+
+				if !allowed || top.rules == nil {
+					return &ParseError{Line: line, Column: column, Path: top.path, Message: fmt.Sprintf("unknown element %s", formatName(t.Name))}
+				}
+
+				if err := d.Skip(); err != nil {
+					return syntaxParseError(d, err)
+				}
+
+				continue
+			}
+
+			index := -1
+
+			for i, rule := range top.rules {
+				if rule.name == t.Name.Local {
+					index = i
+					break
+				}
+			}
+
+			if index < 0 {
+				return &ParseError{Line: line, Column: column, Path: top.path, Message: fmt.Sprintf("unknown element <%s>", t.Name.Local)}
+			}
+
+			rule := top.rules[index]
+			top.counts[rule.name]++
+			path := joinPath(top.path, rule.name)
+
+			if !rule.single {
+				path = fmt.Sprintf("%s[%d]", path, top.counts[rule.name]-1)
+			}
+
+			if rule.single && top.counts[rule.name] > 1 {
+				return &ParseError{Line: line, Column: column, Path: path, Message: "must not be repeated"}
+			}
+
+			if index < top.last {
+				return &ParseError{Line: line, Column: column, Path: path, Message: fmt.Sprintf("must come before <%s>", top.rules[top.last].name)}
+			}
+
+			top.last = index
+			frame := &strictFrame{path: path, required: rule.required, line: line, column: column, last: -1, counts: map[string]int{}}
+
+			if rule.children != "" {
+				frame.rules = strictSchema[rule.children]
+			}
+
+			stack = append(stack, frame)
+		case xml.CharData:
+			top.text.Write(t)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+
+			if top.rules == nil {
+				if top.required && strings.TrimSpace(top.text.String()) == "" {
+					return &ParseError{Line: top.line, Column: top.column, Path: top.path, Message: "is required and must not be blank"}
+				}
+
+				continue
+			}
+
+			for _, rule := range top.rules {
+				if rule.required && top.counts[rule.name] == 0 {
+					return &ParseError{Line: line, Column: column, Path: top.path, Message: fmt.Sprintf("missing required element <%s>", rule.name)}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// skipToRoot reads d up to the root element, storing it in start
+func skipToRoot(d *xml.Decoder, start *xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+
+		if err != nil {
+			return err
+		}
+
+		if t, ok := tok.(xml.StartElement); ok {
+			*start = t
+			return nil
+		}
+	}
+}
+
+// syntaxParseError converts an error from d into a *ParseError at the position
+// where d found it
+func syntaxParseError(d *xml.Decoder, err error) error {
+	line, column := d.InputPos()
+
+	if syntaxErr, ok := err.(*xml.SyntaxError); ok {
+		// A SyntaxError only records its line, so the column of the decoder is
+		// only used while it is still on that line
+		if syntaxErr.Line != line {
+			column = 0
+		}
+
+		return &ParseError{Line: syntaxErr.Line, Column: column, Message: syntaxErr.Msg}
+	}
+
+	return &ParseError{Line: line, Column: column, Message: err.Error()}
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+func formatName(name xml.Name) string {
+	if name.Space == "" {
+		return "<" + name.Local + ">"
+	}
+
+	return fmt.Sprintf("<%s> in namespace %q", name.Local, name.Space)
+}
@@ -0,0 +1,118 @@
+package cap
+
+import (
+	"strings"
+	"testing"
+)
+
+const strictAlert = `<?xml version="1.0" encoding="UTF-8"?>
+<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+	<identifier>1</identifier>
+	<sender>sender@example.com</sender>
+	<sent>2015-08-15T20:45:00-05:00</sent>
+	<status>Actual</status>
+	<msgType>Alert</msgType>
+	<scope>Public</scope>
+	<info>
+		<category>Met</category>
+		<event>Flood Warning</event>
+		<urgency>Expected</urgency>
+		<severity>Moderate</severity>
+		<certainty>Likely</certainty>
+		<parameter><valueName>VTEC</valueName><value>/O.NEW.KLZK.FL.W.0108.150816T0245Z-000000T0000Z/</value></parameter>
+		<area><areaDesc>Jackson</areaDesc></area>
+	</info>
+	<Signature xmlns="http://www.w3.org/2000/09/xmldsig#"><SignedInfo/></Signature>
+</alert>`
+
+func assertParseError(t *testing.T, xmlData string, opts ParseOptions, expected string) {
+	_, err := ParseAlertWithOptions([]byte(xmlData), opts)
+
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("Expected a *ParseError containing %q but got %v", expected, err)
+		return
+	}
+
+	assertEqual(t, err.Error(), expected, "Unexpected parse error")
+}
+
+func TestParseAlertWithOptionsAcceptsValidAlert(t *testing.T) {
+	alert, err := ParseAlertWithOptions([]byte(strictAlert), ParseOptions{Strict: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, alert.Infos[0].Parameter("VTEC"), "/O.NEW.KLZK.FL.W.0108.150816T0245Z-000000T0000Z/", "Parameter does not match!")
+}
+
+func TestParseAlertWithOptionsReportsDocumentErrors(t *testing.T) {
+	assertParseError(t, "", ParseOptions{}, "Line 1, column 1: the document has no root element")
+	assertParseError(t,
+		`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.3"/>`,
+		ParseOptions{},
+		`Line 1, column 1: the root element is <alert> in namespace "urn:oasis:names:tc:emergency:cap:1.3", expected a CAP 1.2 or 1.1 alert`)
+	assertParseError(t,
+		"<alert xmlns=\"urn:oasis:names:tc:emergency:cap:1.2\">\n<identifier>1</sender>",
+		ParseOptions{},
+		"Line 2, column 23: element <identifier> closed by </sender>")
+	assertParseError(t,
+		"<alert xmlns=\"urn:oasis:names:tc:emergency:cap:1.2\">\n<identifier>1</identifier>\n<!-- never closed\n\n",
+		ParseOptions{Strict: true},
+		"Line 5, column 1: unexpected EOF")
+}
+
+func TestParseAlertWithOptionsStrictRules(t *testing.T) {
+	strict := ParseOptions{Strict: true}
+
+	assertParseError(t,
+		strings.Replace(strictAlert, "<scope>Public</scope>", "<scope>Public</scope><color>red</color>", 1),
+		strict,
+		"Line 8, column 23: unknown element <color>")
+	assertParseError(t,
+		strings.Replace(strictAlert, "<event>Flood Warning</event>", "<event>Flood Warning</event><event>Flood</event>", 1),
+		strict,
+		"Line 11, column 31: info[0].event: must not be repeated")
+	assertParseError(t,
+		strings.Replace(strictAlert, "<area><areaDesc>Jackson</areaDesc></area>", "<area><polygon/><areaDesc>Jackson</areaDesc></area>", 1),
+		strict,
+		"Line 16, column 19: info[0].area[0].areaDesc: must come before <polygon>")
+	assertParseError(t,
+		strings.Replace(strictAlert, "<identifier>1</identifier>", "<identifier>  </identifier>", 1),
+		strict,
+		"Line 3, column 2: identifier: is required and must not be blank")
+	assertParseError(t,
+		strings.Replace(strictAlert, "<status>Actual</status>", "", 1),
+		strict,
+		"Line 19, column 1: missing required element <status>")
+	assertParseError(t,
+		strings.Replace(strictAlert, "<area>", `<area><v:zone xmlns:v="urn:vendor">A</v:zone>`, 1),
+		strict,
+		`Line 16, column 9: info[0].area[0]: unknown element <zone> in namespace "urn:vendor"`)
+
+	assertParseError(t,
+		strings.Replace(strictAlert, "<scope>Public</scope>", "<scope>Public</scope><code>a</code><note/><code>b</code>", 1),
+		strict,
+		"Line 8, column 44: code[1]: must come before <note>")
+
+	alert, err := ParseAlertWithOptions([]byte(strings.NewReplacer(
+		"<scope>Public</scope>", "<scope>Public</scope><code>profile:CAP-CP:0.4</code><code>layer:SOREM:1.0</code>",
+		"<areaDesc>Jackson</areaDesc>", "<areaDesc>Jackson</areaDesc><polygon>0,0 0,1 1,1 0,0</polygon><polygon>5,5 5,6 6,6 5,5</polygon><circle>0,0 1</circle><circle>5,5 1</circle>",
+	).Replace(strictAlert)), strict)
+
+	if err != nil {
+		t.Fatalf("Expected repeated code, polygon and circle elements to be allowed but got %v", err)
+	}
+
+	assertEqual(t, len(alert.HandlingCodes), 2, "Every code should be decoded")
+	assertEqual(t, len(alert.Infos[0].Areas[0].Polygons), 2, "Every polygon should be decoded")
+	assertEqual(t, len(alert.Infos[0].Areas[0].Circles), 2, "Every circle should be decoded")
+
+	_, err = ParseAlertWithOptions(
+		[]byte(strings.Replace(strictAlert, "<area>", `<area><v:zone xmlns:v="urn:vendor">A</v:zone>`, 1)),
+		ParseOptions{Strict: true, AllowExtensions: true})
+
+	if err != nil {
+		t.Errorf("Expected extensions to be allowed but got %v", err)
+	}
+}
@@ -0,0 +1,480 @@
+package cap
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PolygonFixKind describes the kind of change made while repairing a polygon
+type PolygonFixKind int
+
+// The changes RepairPolygon can make, in the order they are applied
+const (
+	// FixClamped is reported when coordinates are moved into the valid latitude and longitude ranges
+	FixClamped PolygonFixKind = iota
+	// FixDuplicatesRemoved is reported when repeated consecutive positions are dropped
+	FixDuplicatesRemoved
+	// FixClosed is reported when the first position is repeated at the end of the ring
+	FixClosed
+	// FixSimplified is reported when positions are dropped to fit the point budget
+	FixSimplified
+	// FixSelfIntersections is reported when positions are reordered so edges no longer cross
+	FixSelfIntersections
+	// FixRewound is reported when the ring is reversed to the requested winding
+	FixRewound
+)
+
+// PolygonFix describes a change RepairPolygon made, worded to be passed back to the originator
+type PolygonFix struct {
+	Kind    PolygonFixKind
+	Message string
+}
+
+func (f PolygonFix) String() string {
+	return f.Message
+}
+
+// PolygonOptions control how RepairPolygon normalizes a polygon
+type PolygonOptions struct {
+	// Clockwise winds the ring clockwise; by default it is wound
+	// counterclockwise, as RFC 7946 requires of GeoJSON exterior rings
+	Clockwise bool
+
+	// MaxPoints simplifies the ring to at most this many positions, counting
+	// the closing one, zero for no limit. Budgets under four are raised to four.
+	MaxPoints int
+}
+
+// maxUntangleSteps and untangleStepsPerPosition bound the work done removing
+// self-intersections, in steps on top of and in proportion to the ring's size
+const (
+	maxUntangleSteps         int = 1000
+	untangleStepsPerPosition int = 64
+)
+
+// RepairPolygon normalizes a CAP polygon, returning back the repaired polygon
+// and a description of every change made
+//
+// Coordinates are clamped to valid ranges, repeated consecutive positions are
+// removed, the ring is closed, simplified to the point budget with the
+// Douglas-Peucker algorithm, untangled so no edges cross, and wound in the
+// requested direction. An error is returned if the polygon cannot be parsed,
+// has fewer than three distinct positions, or cannot be untangled.
+func RepairPolygon(value string, opts PolygonOptions) (string, []PolygonFix, error) {
+	ring, err := parseLooseRing(value)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	var fixes []PolygonFix
+
+	fix := func(kind PolygonFixKind, format string, args ...interface{}) {
+		fixes = append(fixes, PolygonFix{Kind: kind, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if clamped, n := ring.Clamp(); n > 0 {
+		ring = clamped
+		fix(FixClamped, "clamped %s to valid latitude and longitude ranges", plural(n, "coordinate"))
+	}
+
+	if deduped, n := ring.RemoveDuplicates(); n > 0 {
+		ring = deduped
+		fix(FixDuplicatesRemoved, "removed %s", plural(n, "duplicate point"))
+	}
+
+	if !ring.Closed() {
+		ring = ring.Close()
+		fix(FixClosed, "closed the ring by repeating the first point at the end")
+	}
+
+	if len(ring) < 4 {
+		return "", fixes, fmt.Errorf("Polygon has fewer than 3 distinct points")
+	}
+
+	if opts.MaxPoints > 0 && len(ring) > opts.MaxPoints {
+		if simplified := ring.SimplifyTo(opts.MaxPoints); len(simplified) < len(ring) {
+			fix(FixSimplified, "simplified from %d to %d points", len(ring), len(simplified))
+			ring = simplified
+		}
+	}
+
+	untangled, n, err := ring.Untangle()
+
+	if err != nil {
+		return "", fixes, err
+	}
+
+	if n > 0 {
+		ring = untangled
+		fix(FixSelfIntersections, "reordered points to remove %s", plural(n, "self-intersection"))
+	}
+
+	if area := ring.SignedArea(); area != 0 && (area < 0) != opts.Clockwise {
+		ring = ring.Reverse()
+
+		if opts.Clockwise {
+			fix(FixRewound, "rewound the ring clockwise")
+		} else {
+			fix(FixRewound, "rewound the ring counterclockwise")
+		}
+	}
+
+	return formatPolygon(ring), fixes, nil
+}
+
+// RepairPolygon repairs each of the area's polygons in place, see RepairPolygon,
+// returning back the fixes made to all of them
+//
+// Blank polygons are left alone. The polygons are not changed if one of them
+// cannot be repaired.
+func (area *Area) RepairPolygon(opts PolygonOptions) ([]PolygonFix, error) {
+	var fixes []PolygonFix
+
+	polygons := append([]string(nil), area.polygons()...)
+
+	for i, polygon := range polygons {
+		if strings.TrimSpace(polygon) == "" {
+			continue
+		}
+
+		repaired, f, err := RepairPolygon(polygon, opts)
+		fixes = append(fixes, f...)
+
+		if err != nil {
+			return fixes, err
+		}
+
+		polygons[i] = repaired
+	}
+
+	area.Polygons = polygons
+	area.Polygon = firstOf(polygons)
+
+	return fixes, nil
+}
+
+// parseLooseRing parses a CAP polygon without checking ranges, closure or the number of positions
+func parseLooseRing(value string) (Ring, error) {
+	var ring Ring
+
+	for _, pair := range strings.Fields(value) {
+		parts := strings.Split(pair, ",")
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid coordinate pair: %q", pair)
+		}
+
+		lat, latErr := strconv.ParseFloat(parts[0], 64)
+		lon, lonErr := strconv.ParseFloat(parts[1], 64)
+
+		if latErr != nil || lonErr != nil || math.IsNaN(lat) || math.IsNaN(lon) || math.IsInf(lat, 0) || math.IsInf(lon, 0) {
+			return nil, fmt.Errorf("Invalid coordinate pair: %q", pair)
+		}
+
+		ring = append(ring, [2]float64{lon, lat})
+	}
+
+	return ring, nil
+}
+
+// formatPolygon returns back the CAP form of a ring
+func formatPolygon(r Ring) string {
+	pairs := make([]string, len(r))
+
+	for i, pos := range r {
+		pairs[i] = strconv.FormatFloat(pos[1], 'f', -1, 64) + "," + strconv.FormatFloat(pos[0], 'f', -1, 64)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Closed returns back true if the ring ends where it starts
+func (r Ring) Closed() bool {
+	return len(r) > 0 && r[0] == r[len(r)-1]
+}
+
+// Close returns back the ring with its first position repeated at the end, if it is not closed already
+func (r Ring) Close() Ring {
+	if len(r) == 0 || r.Closed() {
+		return r
+	}
+
+	return append(append(Ring(nil), r...), r[0])
+}
+
+// Reverse returns back the ring with its positions in the opposite order, flipping its winding
+func (r Ring) Reverse() Ring {
+	reversed := make(Ring, len(r))
+
+	for i, pos := range r {
+		reversed[len(r)-1-i] = pos
+	}
+
+	return reversed
+}
+
+// Clamp returns back the ring with latitudes limited to [-90, 90] and
+// longitudes to [-180, 180], and the number of coordinates changed
+func (r Ring) Clamp() (Ring, int) {
+	clamped := make(Ring, len(r))
+	changed := 0
+
+	for i, pos := range r {
+		lon := math.Max(-180, math.Min(180, pos[0]))
+		lat := math.Max(-90, math.Min(90, pos[1]))
+
+		if lon != pos[0] {
+			changed++
+		}
+
+		if lat != pos[1] {
+			changed++
+		}
+
+		clamped[i] = [2]float64{lon, lat}
+	}
+
+	return clamped, changed
+}
+
+// RemoveDuplicates returns back the ring without positions repeating the one
+// before them, and the number removed
+//
+// The closing position of a closed ring is kept.
+func (r Ring) RemoveDuplicates() (Ring, int) {
+	if len(r) == 0 {
+		return r, 0
+	}
+
+	closed := len(r) > 1 && r.Closed()
+	open := r
+
+	if closed {
+		open = r[:len(r)-1]
+	}
+
+	deduped := Ring{open[0]}
+
+	for _, pos := range open[1:] {
+		if pos != deduped[len(deduped)-1] {
+			deduped = append(deduped, pos)
+		}
+	}
+
+	// An open ring whose last positions repeat the first would close onto itself
+	for len(deduped) > 1 && deduped[len(deduped)-1] == deduped[0] {
+		deduped = deduped[:len(deduped)-1]
+	}
+
+	removed := len(open) - len(deduped)
+
+	if closed {
+		deduped = append(deduped, deduped[0])
+	}
+
+	return deduped, removed
+}
+
+// SimplifyTo returns back the ring simplified with the Douglas-Peucker
+// algorithm, using the smallest tolerance that leaves at most maxPoints
+// positions, counting the closing one
+//
+// Budgets under four are raised to four, the fewest a CAP polygon allows.
+func (r Ring) SimplifyTo(maxPoints int) Ring {
+	if maxPoints < 4 {
+		maxPoints = 4
+	}
+
+	if len(r) <= maxPoints {
+		return r
+	}
+
+	box, _ := MultiPolygon{{r}}.Bounds()
+	low, high := 0.0, math.Hypot(box.MaxLon-box.MinLon, box.MaxLat-box.MinLat)
+	best := r
+
+	for i := 0; i < 64 && high-low > 1e-12; i++ {
+		tolerance := (low + high) / 2
+		simplified := r.simplify(tolerance)
+
+		switch {
+		case len(simplified) < 4:
+			high = tolerance
+		case len(simplified) <= maxPoints:
+			best, high = simplified, tolerance
+		default:
+			low = tolerance
+		}
+	}
+
+	return best
+}
+
+// SelfIntersections returns back the pairs of edges of a closed ring that
+// cross or touch other than at their shared corners, by the index of their
+// first position
+func (r Ring) SelfIntersections() [][2]int {
+	var found [][2]int
+
+	edges := len(r) - 1
+
+	for i := 0; i < edges; i++ {
+		for j := i + 2; j < edges; j++ {
+			// The first and last edges share the closing position
+			if i == 0 && j == edges-1 {
+				continue
+			}
+
+			if segmentsIntersect(r[i], r[i+1], r[j], r[j+1]) {
+				found = append(found, [2]int{i, j})
+			}
+		}
+	}
+
+	return found
+}
+
+// Untangle returns back a closed ring reordered so no edges cross, and the
+// number of crossings it had
+//
+// Each pair of crossing edges is swapped for the pair joining their ends the
+// other way, reversing the positions between them, which keeps every position
+// and shortens the outline until it is simple. Only the two new edges are
+// checked for crossings after each swap, so a step takes time in proportion to
+// the size of the ring. An error is returned if the ring still crosses itself
+// after a number of steps bounded by its size, as rings that touch themselves
+// at a repeated position or along collinear edges can.
+func (r Ring) Untangle() (Ring, int, error) {
+	found := r.SelfIntersections()
+
+	if len(found) == 0 {
+		return r, 0, nil
+	}
+
+	t := newRingUntangler(r)
+
+	for _, pair := range found {
+		t.push(pair[0], pair[1])
+	}
+
+	for step := 0; ; step++ {
+		i, j, ok := t.next()
+
+		if !ok {
+			return t.ring, len(found), nil
+		}
+
+		if step == maxUntangleSteps+untangleStepsPerPosition*len(r) {
+			return nil, 0, fmt.Errorf("Polygon self-intersections could not be removed")
+		}
+
+		t.swap(i, j)
+	}
+}
+
+// ringUntangler tracks the crossing edges of a ring as it is untangled
+//
+// Positions are identified by their index in the original ring, so edges keep
+// their identity as the positions between two crossing edges are reversed.
+type ringUntangler struct {
+	ring Ring
+	ids  []int
+	at   []int
+
+	// crossings holds the pairs of crossing edges, each edge given by the ids
+	// of its two positions. Pairs whose edges no longer exist are skipped.
+	crossings [][4]int
+}
+
+func newRingUntangler(r Ring) *ringUntangler {
+	t := &ringUntangler{ring: append(Ring(nil), r...), ids: make([]int, len(r)), at: make([]int, len(r))}
+
+	for i := range r {
+		t.ids[i], t.at[i] = i, i
+	}
+
+	return t
+}
+
+// push records that the edges starting at positions i and j cross
+func (t *ringUntangler) push(i, j int) {
+	t.crossings = append(t.crossings, [4]int{t.ids[i], t.ids[i+1], t.ids[j], t.ids[j+1]})
+}
+
+// edge returns back the index of the edge joining the positions with ids u and
+// v, or false if they are no longer next to each other
+func (t *ringUntangler) edge(u, v int) (int, bool) {
+	switch a, b := t.at[u], t.at[v]; {
+	case b == a+1:
+		return a, true
+	case a == b+1:
+		return b, true
+	}
+
+	return 0, false
+}
+
+// next returns back the first positions of a pair of crossing edges, in order,
+// or false if the ring no longer crosses itself
+func (t *ringUntangler) next() (int, int, bool) {
+	for len(t.crossings) > 0 {
+		c := t.crossings[len(t.crossings)-1]
+		t.crossings = t.crossings[:len(t.crossings)-1]
+
+		i, ok := t.edge(c[0], c[1])
+
+		if !ok {
+			continue
+		}
+
+		j, ok := t.edge(c[2], c[3])
+
+		if !ok {
+			continue
+		}
+
+		if i > j {
+			i, j = j, i
+		}
+
+		return i, j, true
+	}
+
+	return 0, 0, false
+}
+
+// swap replaces the crossing edges starting at positions i and j by reversing
+// the positions between them, then records the crossings of the two new edges
+func (t *ringUntangler) swap(i, j int) {
+	for a, b := i+1, j; a < b; a, b = a+1, b-1 {
+		t.ring[a], t.ring[b] = t.ring[b], t.ring[a]
+		t.ids[a], t.ids[b] = t.ids[b], t.ids[a]
+		t.at[t.ids[a]], t.at[t.ids[b]] = a, b
+	}
+
+	edges := len(t.ring) - 1
+
+	for _, e := range [2]int{i, j} {
+		for k := 0; k < edges; k++ {
+			// Neighboring edges share a position, and the first and last edges share the closing one
+			if k >= e-1 && k <= e+1 || e == 0 && k == edges-1 || e == edges-1 && k == 0 {
+				continue
+			}
+
+			if segmentsIntersect(t.ring[e], t.ring[e+1], t.ring[k], t.ring[k+1]) {
+				t.push(e, k)
+			}
+		}
+	}
+}
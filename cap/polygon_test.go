@@ -0,0 +1,148 @@
+package cap
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func fixMessages(fixes []PolygonFix) string {
+	messages := make([]string, len(fixes))
+
+	for i, f := range fixes {
+		messages[i] = f.String()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func TestRepairPolygonLeavesValidPolygonsAlone(t *testing.T) {
+	polygon := "35,-91 35,-90 36,-90 36,-91 35,-91"
+	repaired, fixes, err := RepairPolygon(polygon, PolygonOptions{})
+
+	assertEqual(t, err, nil, "The polygon should be repaired")
+	assertEqual(t, repaired, polygon, "A valid counterclockwise polygon should not change")
+	assertEqual(t, len(fixes), 0, "No fixes should be reported")
+}
+
+func TestRepairPolygonReportsEveryFix(t *testing.T) {
+	// Unclosed, clockwise, with a repeated point and a latitude out of range
+	repaired, fixes, err := RepairPolygon("35,-91 36,-91 36,-91 91,-90 35,-90", PolygonOptions{})
+
+	assertEqual(t, err, nil, "The polygon should be repaired")
+	assertEqual(t, repaired, "35,-91 35,-90 90,-90 36,-91 35,-91", "Unexpected repaired polygon")
+	assertEqual(t, fixMessages(fixes), "clamped 1 coordinate to valid latitude and longitude ranges; removed 1 duplicate point; closed the ring by repeating the first point at the end; rewound the ring counterclockwise", "Unexpected fixes")
+	assertEqual(t, validatePolygon(repaired), nil, "The repaired polygon should be valid")
+}
+
+func TestRepairPolygonWindsClockwise(t *testing.T) {
+	repaired, fixes, _ := RepairPolygon("35,-91 35,-90 36,-90 36,-91 35,-91", PolygonOptions{Clockwise: true})
+
+	assertEqual(t, repaired, "35,-91 36,-91 36,-90 35,-90 35,-91", "The ring should be reversed")
+	assertEqual(t, fixes[0].Kind, FixRewound, "The rewinding should be reported")
+}
+
+func TestRepairPolygonUntanglesBowTies(t *testing.T) {
+	// A bow tie: the second and fourth edges cross
+	bowTie := "0,0 0,1 1,0 1,1 0,0"
+
+	assertEqual(t, len(Ring{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 0}}.SelfIntersections()), 1, "The bow tie should cross itself once")
+
+	repaired, fixes, err := RepairPolygon(bowTie, PolygonOptions{})
+
+	assertEqual(t, err, nil, "The polygon should be repaired")
+	assertEqual(t, fixMessages(fixes), "reordered points to remove 1 self-intersection", "Unexpected fixes")
+
+	ring, _ := parsePolygonRing(repaired)
+
+	assertEqual(t, len(ring.SelfIntersections()), 0, "The repaired ring should not cross itself")
+	assertEqual(t, math.Abs(ring.SignedArea()), 1.0, "The repaired ring should be the square")
+}
+
+func TestRingUntanglesLargeRings(t *testing.T) {
+	// 800 points in random order cross themselves tens of thousands of times
+	random := rand.New(rand.NewSource(1))
+	var ring Ring
+
+	for i := 0; i < 800; i++ {
+		ring = append(ring, [2]float64{random.Float64() - 97, random.Float64() + 35})
+	}
+
+	ring = ring.Close()
+	untangled, n, err := ring.Untangle()
+
+	assertEqual(t, err, nil, "The ring should be untangled")
+	assertEqual(t, n, len(ring.SelfIntersections()), "Every crossing should be counted")
+	assertEqual(t, len(untangled), len(ring), "Every position should be kept")
+	assertEqual(t, len(untangled.SelfIntersections()), 0, "The untangled ring should not cross itself")
+}
+
+func TestRepairPolygonSimplifiesToBudget(t *testing.T) {
+	// A circle of 1000 points
+	ring := circleRing([2]float64{-97.5, 35.5}, 50, 1000)
+	repaired, fixes, err := RepairPolygon(formatPolygon(ring), PolygonOptions{MaxPoints: 100})
+
+	assertEqual(t, err, nil, "The polygon should be repaired")
+	assertEqual(t, len(strings.Fields(repaired)) <= 100, true, "The polygon should fit the budget")
+	assertEqual(t, len(strings.Fields(repaired)) > 50, true, "The polygon should not be simplified more than needed")
+	assertEqual(t, fixes[0].Kind, FixSimplified, "The simplification should be reported")
+	assertStartsWith(t, fixes[0].Message, "simplified from 1001 to ", "Unexpected message")
+}
+
+func TestRepairPolygonErrors(t *testing.T) {
+	_, _, err := RepairPolygon("35,-91 35,-91 36,-90 35,-91", PolygonOptions{})
+
+	assertEqual(t, err.Error(), "Polygon has fewer than 3 distinct points", "Degenerate polygons should be rejected")
+
+	_, _, err = RepairPolygon("35,-91 abc 36,-90", PolygonOptions{})
+
+	assertEqual(t, err.Error(), `Invalid coordinate pair: "abc"`, "Invalid pairs should be rejected")
+}
+
+func TestAreaRepairPolygon(t *testing.T) {
+	area := Area{Polygons: []string{"35,-91 36,-91 36,-90 35,-90"}}
+	fixes, err := area.RepairPolygon(PolygonOptions{})
+
+	assertEqual(t, err, nil, "The polygon should be repaired")
+	assertEqual(t, len(fixes), 2, "Closing and rewinding should be reported")
+	assertEqual(t, area.Polygons[0], "35,-91 35,-90 36,-90 36,-91 35,-91", "The area should be updated")
+
+	area = Area{Polygons: []string{"35,-91 36,-91 36,-90 35,-90", "35,-91"}}
+	_, err = area.RepairPolygon(PolygonOptions{})
+
+	assertEqual(t, err != nil, true, "The polygon cannot be repaired")
+	assertEqual(t, area.Polygons[0], "35,-91 36,-91 36,-90 35,-90", "The area should be left alone")
+
+	area = Area{Polygons: []string{"35,-91 36,-91 36,-90 35,-90", "0,0 1,0 1,1 0,1 0,0"}}
+	fixes, err = area.RepairPolygon(PolygonOptions{})
+
+	assertEqual(t, err, nil, "The polygons should be repaired")
+	assertEqual(t, len(fixes), 3, "The fixes to every polygon should be reported")
+	assertEqual(t, area.Polygons[1], "0,0 0,1 1,1 1,0 0,0", "Every polygon should be repaired")
+}
+
+func TestRingRemoveDuplicates(t *testing.T) {
+	cases := map[string]int{
+		"a a b c a":   1,
+		"a b b b c a": 2,
+		"a b c a a":   1,
+		"a b c":       0,
+		"a b c a b":   0,
+	}
+
+	positions := map[string][2]float64{"a": {0, 0}, "b": {1, 0}, "c": {1, 1}}
+
+	for input, removed := range cases {
+		var ring Ring
+
+		for _, name := range strings.Fields(input) {
+			ring = append(ring, positions[name])
+		}
+
+		_, n := ring.RemoveDuplicates()
+
+		assertEqual(t, n, removed, fmt.Sprintf("Unexpected number removed from %s", input))
+	}
+}
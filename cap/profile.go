@@ -0,0 +1,119 @@
+package cap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Profile is a set of rules a national or jurisdictional CAP profile adds to
+// the core specification, such as mandatory parameters, geocode schemes and
+// event lists
+type Profile interface {
+	// Name returns back the name the profile is registered under, such as CAP-CP
+	Name() string
+
+	// Validate records every rule of the profile the alert breaks
+	Validate(alert *Alert, errs *ValidationErrors)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Profile)
+)
+
+func init() {
+	RegisterProfile(CAPCPProfile)
+	RegisterProfile(CAPAUProfile)
+}
+
+// RegisterProfile makes a profile available by name to LookupProfile
+//
+// Profile names are matched case-insensitively. RegisterProfile panics if a
+// profile with the same name is already registered.
+func RegisterProfile(profile Profile) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	key := strings.ToLower(profile.Name())
+
+	if _, exists := registry[key]; exists {
+		panic(fmt.Sprintf("cap: profile %q is already registered", profile.Name()))
+	}
+
+	registry[key] = profile
+}
+
+// LookupProfile returns back the registered profile with the given name
+func LookupProfile(name string) (Profile, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	profile, ok := registry[strings.ToLower(name)]
+
+	return profile, ok
+}
+
+// Profiles returns back the names of the registered profiles, sorted
+func Profiles() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+
+	for _, profile := range registry {
+		names = append(names, profile.Name())
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// requireEventCode checks an Info has exactly one event code of the profile's scheme
+func requireEventCode(errs *ValidationErrors, path string, info *Info, valueName string) string {
+	var codes []string
+
+	for _, code := range info.EventCode {
+		if code.ValueName == valueName {
+			codes = append(codes, code.Value)
+		}
+	}
+
+	switch len(codes) {
+	case 0:
+		errs.Add(path+".eventCode", "an event code named %s is required", valueName)
+		return ""
+	case 1:
+		return codes[0]
+	default:
+		errs.Add(path+".eventCode", "only one event code named %s is allowed", valueName)
+		return ""
+	}
+}
+
+// requireCode checks one of the alert's code elements is the code of a profile
+func requireCode(errs *ValidationErrors, alert *Alert, code string) {
+	if !alert.HasHandlingCode(code) {
+		errs.Add("code", "must include %s", code)
+	}
+}
+
+// NewProfile returns back a Profile named name whose rules are checked by validate
+func NewProfile(name string, validate func(alert *Alert, errs *ValidationErrors)) Profile {
+	return funcProfile{name: name, validate: validate}
+}
+
+type funcProfile struct {
+	name     string
+	validate func(alert *Alert, errs *ValidationErrors)
+}
+
+func (p funcProfile) Name() string {
+	return p.name
+}
+
+func (p funcProfile) Validate(alert *Alert, errs *ValidationErrors) {
+	p.validate(alert, errs)
+}
@@ -0,0 +1,44 @@
+package cap
+
+import "fmt"
+
+// Identifiers defined by the Australian Government Standard for CAP (CAP-AU) version 1.0
+const (
+	CAPAUCode           string = "urn:oasis:names:tc:emergency:cap:1.2:profile:CAP-AU:1.0"
+	CAPAUEventValueName string = "https://govshare.gov.au/xmlui/handle/10772/6495"
+)
+
+// CAPAUProfile checks the rules of the Australian CAP profile
+var CAPAUProfile Profile = capauProfile{}
+
+type capauProfile struct{}
+
+func (capauProfile) Name() string {
+	return "CAP-AU"
+}
+
+func (capauProfile) Validate(alert *Alert, errs *ValidationErrors) {
+	requireCode(errs, alert, CAPAUCode)
+
+	if len(alert.Infos) == 0 && (alert.MessageType == "Alert" || alert.MessageType == "Update") {
+		errs.Add("info", "is required for %s messages", alert.MessageType)
+	}
+
+	for i := range alert.Infos {
+		info := &alert.Infos[i]
+		path := fmt.Sprintf("info[%d]", i)
+
+		requireValue(errs, path+".expires", info.ExpiresDate)
+		requireEventCode(errs, path, info, CAPAUEventValueName)
+
+		if len(info.Areas) == 0 {
+			errs.Add(path+".area", "is required")
+		}
+
+		for j, area := range info.Areas {
+			if len(area.polygons()) == 0 && len(area.circles()) == 0 && len(area.Geocodes) == 0 {
+				errs.Add(fmt.Sprintf("%s.area[%d]", path, j), "a polygon, circle or geocode is required")
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package cap
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Identifiers defined by the Canadian Profile of CAP (CAP-CP) version 0.4
+const (
+	CAPCPCode              string = "profile:CAP-CP:0.4"
+	CAPCPEventValueName    string = "profile:CAP-CP:Event:0.4"
+	CAPCPLocationValueName string = "profile:CAP-CP:Location:0.3"
+)
+
+// CAPCPLanguageValues are the info languages allowed by CAP-CP
+var CAPCPLanguageValues = []string{"en-CA", "fr-CA"}
+
+// CAPCPProfile checks the rules of the Canadian Profile of CAP
+var CAPCPProfile Profile = capcpProfile{}
+
+var capcpLocationPattern = regexp.MustCompile(`^\d{2}(\d{2}(\d{3})?)?$`)
+
+type capcpProfile struct{}
+
+func (capcpProfile) Name() string {
+	return "CAP-CP"
+}
+
+func (capcpProfile) Validate(alert *Alert, errs *ValidationErrors) {
+	requireCode(errs, alert, CAPCPCode)
+
+	for i := range alert.Infos {
+		info := &alert.Infos[i]
+		path := fmt.Sprintf("info[%d]", i)
+
+		requireEnum(errs, path+".language", info.Language, CAPCPLanguageValues)
+		requireValue(errs, path+".expires", info.ExpiresDate)
+
+		if event := requireEventCode(errs, path, info, CAPCPEventValueName); event != "" {
+			if _, ok := LookupCAPCPEvent(event); !ok {
+				errs.Add(path+".eventCode", "%q is not a CAP-CP event code", event)
+			}
+		}
+
+		if len(info.Areas) == 0 {
+			errs.Add(path+".area", "is required")
+		}
+
+		for j := range info.Areas {
+			areaPath := fmt.Sprintf("%s.area[%d]", path, j)
+			codes := info.Areas[j].GeocodeAll(CAPCPLocationValueName)
+
+			if len(codes) == 0 {
+				errs.Add(areaPath+".geocode", "a geocode named %s is required", CAPCPLocationValueName)
+			}
+
+			for _, code := range codes {
+				if _, err := LookupSGC(code); err != nil {
+					errs.Add(areaPath+".geocode", "%s", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package cap
+
+import (
+	"strings"
+	"testing"
+)
+
+func getCAPCPAlert() *Alert {
+	return &Alert{
+		MessageID:     "2d5f7f2a-cap-cp-test",
+		SenderID:      "cap-pac@canada.ca",
+		SentDate:      "2015-08-15T20:45:00-05:00",
+		MessageStatus: "Actual",
+		MessageType:   "Alert",
+		Scope:         "Public",
+		HandlingCodes: []string{CAPCPCode},
+		Infos: []Info{{
+			Language:      "en-CA",
+			EventCategory: "Met",
+			EventType:     "tornado",
+			Urgency:       "Immediate",
+			Severity:      "Extreme",
+			Certainty:     "Observed",
+			ExpiresDate:   "2015-08-15T21:45:00-05:00",
+			EventCode:     []NamedValue{{ValueName: CAPCPEventValueName, Value: "tornado"}},
+			Areas: []Area{{
+				Description: "City of Ottawa",
+				Geocodes:    []NamedValue{{ValueName: CAPCPLocationValueName, Value: "3506008"}},
+			}},
+		}},
+	}
+}
+
+func errorPaths(err error) string {
+	errs, _ := err.(ValidationErrors)
+	paths := make([]string, len(errs))
+
+	for i, e := range errs {
+		paths[i] = e.Path
+	}
+
+	return strings.Join(paths, " ")
+}
+
+func TestProfilesAreRegistered(t *testing.T) {
+	names := Profiles()
+
+	assertIn(t, "CAP-CP", names, "CAP-CP should be registered")
+	assertIn(t, "CAP-AU", names, "CAP-AU should be registered")
+
+	profile, ok := LookupProfile("cap-cp")
+
+	assertEqual(t, ok, true, "Lookup should ignore case")
+	assertEqual(t, profile.Name(), "CAP-CP", "Wrong profile returned")
+}
+
+func TestRegisterProfileRejectsDuplicates(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering CAP-CP twice to panic")
+		}
+	}()
+
+	RegisterProfile(NewProfile("cap-cp", func(*Alert, *ValidationErrors) {}))
+}
+
+func TestValidateAppliesCustomProfile(t *testing.T) {
+	alert := getCAPCPAlert()
+	profile := NewProfile("Test County", func(alert *Alert, errs *ValidationErrors) {
+		if alert.Source == "" {
+			errs.Add("source", "is required by Test County")
+		}
+	})
+
+	err := alert.Validate(profile)
+
+	assertEqual(t, errorPaths(err), "source", "Unexpected validation errors")
+}
+
+func TestCAPCPProfile(t *testing.T) {
+	alert := getCAPCPAlert()
+
+	if err := alert.Validate(CAPCPProfile); err != nil {
+		t.Fatal(err)
+	}
+
+	alert.HandlingCodes = nil
+	alert.Infos[0].Language = "en-US"
+	alert.Infos[0].EventCode[0].Value = "Tornado Warning"
+	alert.Infos[0].Areas[0].Geocodes[0].Value = "35A"
+
+	assertEqual(t,
+		errorPaths(alert.Validate(CAPCPProfile)),
+		"code info[0].language info[0].eventCode info[0].area[0].geocode",
+		"Unexpected validation errors")
+}
+
+func TestCAPCPProfileChecksTheEventList(t *testing.T) {
+	alert := getCAPCPAlert()
+
+	for _, event := range []string{"waterspout", "iceberg", "tropStorm", "pyroclasSurge"} {
+		alert.Infos[0].EventCode[0].Value = event
+
+		assertEqual(t, alert.Validate(CAPCPProfile), nil, "Listed event codes should be accepted: "+event)
+	}
+
+	alert.Infos[0].EventCode[0].Value = "notARealEvent"
+
+	assertEqual(t, alert.Validate(CAPCPProfile).Error(), `info[0].eventCode: "notARealEvent" is not a CAP-CP event code`, "Unlisted event codes should be rejected")
+
+	// Trois-Rivières
+	alert.Infos[0].EventCode[0].Value = "flood"
+	alert.Infos[0].Areas[0].Geocodes[0].Value = "2437067"
+
+	assertEqual(t, alert.Validate(CAPCPProfile), nil, "Codes of every division should be accepted")
+
+	scheme, _ := LookupGeocodeScheme(CAPCPLocationValueName)
+
+	assertEqual(t, scheme.Validate("2437067"), nil, "The geocode scheme should accept the division")
+}
+
+func TestCAPCPProfileAcceptsSeveralCodes(t *testing.T) {
+	alert, err := ParseAlert([]byte(`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+	<code>layer:SOREM:1.0</code>
+	<code>profile:CAP-CP:0.4</code>
+	<code>layer:SOREM:2.0</code>
+</alert>`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := ValidationErrors{}
+	CAPCPProfile.Validate(alert, &errs)
+
+	for _, e := range errs {
+		if e.Path == "code" {
+			t.Errorf("The profile code should be found among the others: %s", e)
+		}
+	}
+
+	alert.HandlingCodes = []string{"layer:SOREM:1.0", "layer:SOREM:2.0"}
+	errs = ValidationErrors{}
+	CAPCPProfile.Validate(alert, &errs)
+
+	assertStartsWith(t, errorPaths(errs), "code", "A missing profile code should still be reported")
+}
+
+func TestCAPAUProfile(t *testing.T) {
+	alert := getCAPCPAlert()
+	alert.HandlingCodes = []string{CAPAUCode}
+	alert.Infos[0].EventCode = []NamedValue{{ValueName: CAPAUEventValueName, Value: "tornado"}}
+
+	if err := alert.Validate(CAPAUProfile); err != nil {
+		t.Fatal(err)
+	}
+
+	alert.Infos[0].EventCode = nil
+	alert.Infos[0].ExpiresDate = ""
+	alert.Infos[0].Areas[0].Geocodes = nil
+
+	assertEqual(t,
+		errorPaths(alert.Validate(CAPAUProfile)),
+		"info[0].expires info[0].eventCode info[0].area[0]",
+		"Unexpected validation errors")
+}
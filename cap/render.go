@@ -0,0 +1,236 @@
+package cap
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode"
+)
+
+// DefaultTextTemplate is the text/template used by NewTextRenderer
+const DefaultTextTemplate string = `{{range .Infos}}[{{badge .Severity}}] {{or .Headline .EventType | plain}}
+{{with .Areas}}Areas: {{range $i, $a := .}}{{if $i}}; {{end}}{{plain $a.Description}}{{end}}
+{{end}}{{with .EffectiveDate}}Effective: {{date .}}
+{{end}}{{with .ExpiresDate}}Expires: {{date .}}
+{{end}}{{with .EventDescription}}
+{{plain .}}
+{{end}}{{with .Instruction}}
+{{plain .}}
+{{end}}{{range .Resources}}{{$r := .}}{{with safeURL .URI}}
+{{plain $r.Description}}: {{.}}{{end}}{{end}}
+{{end}}`
+
+// DefaultMarkdownTemplate is the text/template used by NewMarkdownRenderer
+const DefaultMarkdownTemplate string = `{{range .Infos}}## **{{badge .Severity}}** {{or .Headline .EventType | md}}
+{{with .Areas}}
+**Areas:** {{range $i, $a := .}}{{if $i}}; {{end}}{{md $a.Description}}{{end}}
+{{end}}{{with .EffectiveDate}}
+**Effective:** {{date . | md}}
+{{end}}{{with .ExpiresDate}}
+**Expires:** {{date . | md}}
+{{end}}{{with .EventDescription}}
+{{md .}}
+{{end}}{{with .Instruction}}
+> {{md .}}
+{{end}}{{range .Resources}}{{$r := .}}{{with safeURL .URI}}
+- [{{md $r.Description}}]({{.}}){{end}}{{end}}
+{{end}}`
+
+// DefaultHTMLTemplate is the html/template used by NewHTMLRenderer
+const DefaultHTMLTemplate string = `{{range .Infos}}<div class="cap-info">
+<h2><span class="cap-severity cap-severity-{{severityClass .Severity}}">{{badge .Severity}}</span> {{or .Headline .EventType}}</h2>
+{{with .Areas}}<p class="cap-areas"><strong>Areas:</strong> {{range $i, $a := .}}{{if $i}}; {{end}}{{$a.Description}}{{end}}</p>
+{{end}}{{with .EffectiveDate}}<p class="cap-effective"><strong>Effective:</strong> {{date .}}</p>
+{{end}}{{with .ExpiresDate}}<p class="cap-expires"><strong>Expires:</strong> {{date .}}</p>
+{{end}}{{with .EventDescription}}<p class="cap-description">{{.}}</p>
+{{end}}{{with .Instruction}}<p class="cap-instruction">{{.}}</p>
+{{end}}{{with .Resources}}<ul class="cap-resources">
+{{range .}}{{$r := .}}{{with safeURL .URI}}<li><a href="{{.}}">{{$r.Description}}</a></li>
+{{end}}{{end}}</ul>
+{{end}}</div>
+{{end}}`
+
+// renderDateLayout is how effective and expires dates are shown
+const renderDateLayout string = "Mon Jan 2, 2006 3:04 PM MST"
+
+// Renderer produces a human-readable summary of an alert from a template
+//
+// Templates are executed with the *Alert and can use these functions besides
+// the standard ones:
+//
+//	date           formats a CAP date in the renderer's Location
+//	badge          returns back the severity in upper case, UNKNOWN when empty
+//	severityClass  returns back the severity in lower case, limited to SeverityValues
+//	safeURL        returns back the URI if it is an absolute http or https URL, otherwise ""
+//	plain          removes control characters (text and Markdown templates)
+//	md             escapes Markdown syntax and HTML (text and Markdown templates)
+//
+// Fields come from untrusted senders: HTML templates escape them automatically,
+// while text and Markdown templates must pass them through plain or md.
+type Renderer struct {
+	// Location is the time zone dates are shown in, the offset of each date when nil
+	Location *time.Location
+
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// NewTextRenderer returns back a Renderer producing plain text from a
+// text/template, DefaultTextTemplate when tmpl is empty
+func NewTextRenderer(tmpl string) (*Renderer, error) {
+	return newTextRenderer("text", tmpl, DefaultTextTemplate)
+}
+
+// NewMarkdownRenderer returns back a Renderer producing Markdown from a
+// text/template, DefaultMarkdownTemplate when tmpl is empty
+func NewMarkdownRenderer(tmpl string) (*Renderer, error) {
+	return newTextRenderer("markdown", tmpl, DefaultMarkdownTemplate)
+}
+
+// NewHTMLRenderer returns back a Renderer producing HTML from an
+// html/template, DefaultHTMLTemplate when tmpl is empty
+func NewHTMLRenderer(tmpl string) (*Renderer, error) {
+	if tmpl == "" {
+		tmpl = DefaultHTMLTemplate
+	}
+
+	r := &Renderer{}
+
+	t, err := htmltemplate.New("html").Funcs(r.funcs()).Parse(tmpl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.html = t
+
+	return r, nil
+}
+
+func newTextRenderer(name, tmpl, fallback string) (*Renderer, error) {
+	if tmpl == "" {
+		tmpl = fallback
+	}
+
+	r := &Renderer{}
+
+	t, err := texttemplate.New(name).Funcs(r.funcs()).Parse(tmpl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.text = t
+
+	return r, nil
+}
+
+// Render writes the summary of the alert to w
+func (r *Renderer) Render(w io.Writer, alert *Alert) error {
+	if r.html != nil {
+		return r.html.Execute(w, alert)
+	}
+
+	return r.text.Execute(w, alert)
+}
+
+// RenderString returns back the summary of the alert
+func (r *Renderer) RenderString(alert *Alert) (string, error) {
+	var b strings.Builder
+
+	err := r.Render(&b, alert)
+
+	return b.String(), err
+}
+
+func (r *Renderer) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"date":          r.formatDate,
+		"badge":         severityBadge,
+		"severityClass": severityClass,
+		"safeURL":       safeURL,
+		"plain":         plainText,
+		"md":            escapeMarkdown,
+	}
+}
+
+func (r *Renderer) formatDate(value string) string {
+	date, err := ParseCAPDate(value)
+
+	if err != nil {
+		return value
+	}
+
+	if r.Location != nil {
+		date = date.In(r.Location)
+	}
+
+	return date.Format(renderDateLayout)
+}
+
+func severityBadge(severity string) string {
+	if strings.TrimSpace(severity) == "" {
+		return "UNKNOWN"
+	}
+
+	return strings.ToUpper(plainText(severity))
+}
+
+func severityClass(severity string) string {
+	for _, s := range SeverityValues {
+		if s == severity {
+			return strings.ToLower(s)
+		}
+	}
+
+	return "unknown"
+}
+
+// safeURL returns back uri if it is an absolute http or https URL
+func safeURL(uri string) string {
+	u, err := url.Parse(strings.TrimSpace(uri))
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+
+	return urlEscaper.Replace(u.String())
+}
+
+// urlEscaper percent-encodes the characters that would end a URL early in Markdown or text
+var urlEscaper = strings.NewReplacer(" ", "%20", "<", "%3C", ">", "%3E", "(", "%28", ")", "%29", `"`, "%22")
+
+// plainText removes control characters other than newlines and tabs, so
+// fields cannot inject terminal escape sequences
+func plainText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+
+		return r
+	}, text)
+}
+
+// markdownEscaper escapes Markdown syntax characters and the HTML Markdown passes through
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "{", `\{`, "}", `\}`, "[", `\[`, "]", `\]`,
+	"(", `\(`, ")", `\)`, "#", `\#`, "|", `\|`, "<", "&lt;", ">", "&gt;", "&", "&amp;",
+)
+
+// Lines Markdown would turn into bullet and numbered lists
+var (
+	markdownBulletPattern   = regexp.MustCompile(`(?m)^(\s*)([-+])`)
+	markdownNumberedPattern = regexp.MustCompile(`(?m)^(\s*\d+)\.`)
+)
+
+func escapeMarkdown(text string) string {
+	text = markdownEscaper.Replace(plainText(text))
+	text = markdownBulletPattern.ReplaceAllString(text, `$1\$2`)
+
+	return markdownNumberedPattern.ReplaceAllString(text, `$1\.`)
+}
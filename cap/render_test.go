@@ -0,0 +1,130 @@
+package cap
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func getRenderAlert() *Alert {
+	return &Alert{
+		MessageID: "1",
+		Infos: []Info{{
+			EventType:        "Flood Warning",
+			Severity:         "Severe",
+			Headline:         "Flood Warning for *Jackson* <b>County</b>",
+			EffectiveDate:    "2015-08-15T20:45:00-05:00",
+			ExpiresDate:      "2015-08-16T02:45:00-05:00",
+			EventDescription: "- River rising.\n1. Expect flooding.",
+			Instruction:      "Move to higher ground.\x1b[31m",
+			Areas:            []Area{{Description: "Jackson"}, {Description: "Woodruff"}},
+			Resources: []Resource{
+				{Description: "Map", URI: "https://example.com/map (1).png"},
+				{Description: "Bad", URI: "javascript:alert(1)"},
+			},
+		}},
+	}
+}
+
+func TestTextRenderer(t *testing.T) {
+	r, err := NewTextRenderer("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Location = time.UTC
+	text, err := r.RenderString(getRenderAlert())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[SEVERE] Flood Warning for *Jackson* <b>County</b>
+Areas: Jackson; Woodruff
+Effective: Sun Aug 16, 2015 1:45 AM UTC
+Expires: Sun Aug 16, 2015 7:45 AM UTC
+
+- River rising.
+1. Expect flooding.
+
+Move to higher ground.[31m
+
+Map: https://example.com/map%20%281%29.png
+`
+
+	assertEqual(t, text, expected, "Rendered text does not match!")
+}
+
+func TestMarkdownRendererEscapesFields(t *testing.T) {
+	r, err := NewMarkdownRenderer("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := r.RenderString(getRenderAlert())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`## **SEVERE** Flood Warning for \*Jackson\* &lt;b&gt;County&lt;/b&gt;`,
+		"**Expires:** Sun Aug 16, 2015 2:45 AM -0500",
+		"\\- River rising.\n1\\. Expect flooding.",
+		"- [Map](https://example.com/map%20%281%29.png)",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, text)
+		}
+	}
+
+	if strings.Contains(text, "javascript") {
+		t.Errorf("Unsafe links should be dropped:\n%s", text)
+	}
+}
+
+func TestHTMLRendererEscapesFields(t *testing.T) {
+	r, err := NewHTMLRenderer("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := r.RenderString(getRenderAlert())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`<span class="cap-severity cap-severity-severe">SEVERE</span> Flood Warning for *Jackson* &lt;b&gt;County&lt;/b&gt;</h2>`,
+		`<li><a href="https://example.com/map%20%281%29.png">Map</a></li>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, html)
+		}
+	}
+
+	if strings.Contains(html, "javascript") {
+		t.Errorf("Unsafe links should be dropped:\n%s", html)
+	}
+}
+
+func TestRendererUsesCustomTemplate(t *testing.T) {
+	r, err := NewTextRenderer(`{{range .Infos}}{{badge .Severity}}: {{plain .EventType}}{{end}}`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := r.RenderString(getRenderAlert())
+
+	assertEqual(t, err, nil, "Render should succeed")
+	assertEqual(t, text, "SEVERE: Flood Warning", "Rendered text does not match!")
+
+	if _, err := NewHTMLRenderer("{{.Missing"); err == nil {
+		t.Error("Expected a template parse error")
+	}
+}
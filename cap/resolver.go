@@ -0,0 +1,197 @@
+package cap
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GeometryResolver fills in the boundaries of areas described only by geocodes,
+// from county and zone boundary datasets
+//
+// Boundaries are simplified with the resolver's tolerance the first time each
+// code is looked up and cached from then on, so resolving the same counties
+// for every alert is cheap. A GeometryResolver is safe for concurrent use.
+type GeometryResolver struct {
+	tolerance float64
+
+	mu      sync.RWMutex
+	sources map[string]GeometrySource
+	files   map[string]GeometrySet
+	cache   map[string]MultiPolygon
+}
+
+// NewGeometryResolver returns back a GeometryResolver simplifying boundaries
+// with the Douglas-Peucker tolerance in degrees, zero keeping them as loaded
+//
+// A tolerance of 0.001 degrees, about 100 meters, keeps county outlines
+// recognisable while dropping most of their positions.
+func NewGeometryResolver(tolerance float64) *GeometryResolver {
+	return &GeometryResolver{
+		tolerance: tolerance,
+		sources:   make(map[string]GeometrySource),
+		files:     make(map[string]GeometrySet),
+		cache:     make(map[string]MultiPolygon),
+	}
+}
+
+// AddSource uses source for the boundaries of geocodes named valueName,
+// replacing any source added before
+func (r *GeometryResolver) AddSource(valueName string, source GeometrySource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := strings.ToLower(strings.TrimSpace(valueName))
+	r.sources[key] = source
+
+	for cached := range r.cache {
+		if strings.HasPrefix(cached, key+"\x00") {
+			delete(r.cache, cached)
+		}
+	}
+}
+
+// LoadFile reads a GeoJSON (.geojson or .json) or shapefile (.shp) dataset
+// keyed by property and uses it for the geocodes named valueName
+//
+// Each file is read once per property, so one county dataset can serve both
+// SAME and FIPS6 geocodes.
+func (r *GeometryResolver) LoadFile(valueName, path, property string) error {
+	key := path + "\x00" + property
+
+	r.mu.RLock()
+	set, loaded := r.files[key]
+	r.mu.RUnlock()
+
+	if !loaded {
+		var err error
+
+		if set, err = loadGeometryFile(path, property); err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		r.files[key] = set
+		r.mu.Unlock()
+	}
+
+	r.AddSource(valueName, set)
+
+	return nil
+}
+
+// loadGeometryFile reads a dataset, choosing the format from the file extension
+func loadGeometryFile(path, property string) (GeometrySet, error) {
+	if strings.EqualFold(filepath.Ext(path), ".shp") {
+		return LoadShapefile(path, property)
+	}
+
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ReadGeoJSONGeometries(bufio.NewReader(f), property)
+}
+
+// Geometry returns back the simplified boundary of a geocode and true, or false if it cannot be found
+//
+// Geocodes without a source added to the resolver fall back to the geometry
+// source of their registered scheme. SAME and FIPS6 codes are also looked up by
+// their last five digits, the county GEOID used by Census Bureau datasets.
+func (r *GeometryResolver) Geometry(valueName, code string) (MultiPolygon, bool) {
+	code = strings.TrimSpace(code)
+	sourceKey := strings.ToLower(strings.TrimSpace(valueName))
+	key := sourceKey + "\x00" + code
+
+	r.mu.RLock()
+	geometry, cached := r.cache[key]
+	source := r.sources[sourceKey]
+	r.mu.RUnlock()
+
+	if cached {
+		return geometry, geometry != nil
+	}
+
+	if source == nil {
+		if scheme, ok := LookupGeocodeScheme(valueName); ok {
+			source = scheme.Geometry
+		}
+	}
+
+	if source != nil {
+		var ok bool
+
+		geometry, ok = source.Geometry(code)
+
+		if !ok && (sourceKey == "same" || sourceKey == "fips6") && countyCodePattern.MatchString(code) {
+			geometry, ok = source.Geometry(code[1:])
+		}
+
+		if ok {
+			geometry = geometry.Simplify(r.tolerance)
+		} else {
+			geometry = nil
+		}
+	}
+
+	// Misses are cached too, so unknown codes are not looked up again, but
+	// codes without any source are not, so a source added later is used
+	if source != nil {
+		r.mu.Lock()
+		r.cache[key] = geometry
+		r.mu.Unlock()
+	}
+
+	return geometry, geometry != nil
+}
+
+// ResolveArea fills in the ResolvedGeometry of an area with no polygon or
+// circle from the boundaries of its geocodes, returning back true if any were found
+//
+// Areas with a polygon or circle are left alone, since their own geometry is
+// more precise than the boundaries of the counties or zones they touch.
+func (r *GeometryResolver) ResolveArea(area *Area) bool {
+	for _, shape := range append(append([]string(nil), area.polygons()...), area.circles()...) {
+		if strings.TrimSpace(shape) != "" {
+			return false
+		}
+	}
+
+	var geometry MultiPolygon
+
+	for _, geocode := range area.Geocodes {
+		if boundary, ok := r.Geometry(geocode.ValueName, geocode.Value); ok {
+			geometry = append(geometry, boundary...)
+		}
+	}
+
+	if geometry == nil {
+		return false
+	}
+
+	area.ResolvedGeometry = geometry
+
+	return true
+}
+
+// Resolve fills in the geometry of every geocode-only area of the alert,
+// returning back the number of areas resolved
+func (r *GeometryResolver) Resolve(alert *Alert) int {
+	resolved := 0
+
+	for i := range alert.Infos {
+		for j := range alert.Infos[i].Areas {
+			if r.ResolveArea(&alert.Infos[i].Areas[j]) {
+				resolved++
+			}
+		}
+	}
+
+	return resolved
+}
@@ -0,0 +1,107 @@
+package cap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countingSource is a GeometrySource that counts its lookups
+type countingSource struct {
+	set     GeometrySet
+	lookups int
+}
+
+func (s *countingSource) Geometry(code string) (MultiPolygon, bool) {
+	s.lookups++
+	return s.set.Geometry(code)
+}
+
+func getResolverAlert() *Alert {
+	return &Alert{Infos: []Info{{Areas: []Area{
+		{Description: "Jackson; Woodruff", Geocodes: []NamedValue{{ValueName: "SAME", Value: "005067"}, {ValueName: "SAME", Value: "005147"}}},
+		{Description: "Polygon", Polygons: []string{"35,-91 36,-91 36,-90 35,-90 35,-91"}, Geocodes: []NamedValue{{ValueName: "SAME", Value: "005067"}}},
+		{Description: "Unknown", Geocodes: []NamedValue{{ValueName: "SAME", Value: "005999"}}},
+	}}}}
+}
+
+func TestResolverFillsGeocodeOnlyAreas(t *testing.T) {
+	shp, dbf := buildTestShapefile(testShapes)
+	dir, err := ioutil.TempDir("", "cap-resolver")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "counties.shp"), shp, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "counties.dbf"), dbf, 0644)
+
+	resolver := NewGeometryResolver(0)
+
+	if err := resolver.LoadFile("SAME", filepath.Join(dir, "counties.shp"), "NAME"); err != nil {
+		t.Fatal(err)
+	}
+
+	alert := getResolverAlert()
+
+	assertEqual(t, resolver.Resolve(alert), 1, "Only the geocode-only area with known codes should be resolved")
+
+	areas := alert.Infos[0].Areas
+
+	assertEqual(t, len(areas[0].ResolvedGeometry), 2, "Both counties should be resolved by their GEOID")
+	assertEqual(t, areas[1].ResolvedGeometry == nil, true, "Areas with a polygon should be left alone")
+	assertEqual(t, areas[2].ResolvedGeometry == nil, true, "Unknown codes should not be resolved")
+	assertEqual(t, areas[0].Contains(35.1, -91.2), true, "The resolved area should contain points in Woodruff County")
+	assertEqual(t, areas[2].Contains(35.1, -91.2), false, "The unresolved area should contain nothing")
+
+	features := alert.GeoJSON().Features
+
+	assertEqual(t, features[0].Geometry.Type, "MultiPolygon", "The resolved geometry should be mapped")
+}
+
+func TestResolverCachesLookups(t *testing.T) {
+	source := &countingSource{set: GeometrySet{"ARC067": {{{{-91.5, 35.4}, {-91.0, 35.4}, {-91.0, 35.9}, {-91.5, 35.9}, {-91.5, 35.4}}}}}}
+	resolver := NewGeometryResolver(0)
+	resolver.AddSource("UGC", source)
+
+	for i := 0; i < 3; i++ {
+		_, ok := resolver.Geometry("UGC", "ARC067")
+		assertEqual(t, ok, true, "The zone should be found")
+
+		_, ok = resolver.Geometry("ugc", "ARC999")
+		assertEqual(t, ok, false, "The zone should not be found")
+	}
+
+	assertEqual(t, source.lookups, 2, "Hits and misses should be cached")
+
+	resolver.AddSource("UGC", source)
+	resolver.Geometry("UGC", "ARC067")
+
+	assertEqual(t, source.lookups, 3, "Adding a source should clear its cache")
+}
+
+func TestResolverSimplifiesBoundaries(t *testing.T) {
+	// A square with a slight bump in the middle of each side
+	ring := Ring{{0, 0}, {0.5, 0.0001}, {1, 0}, {1, 0.5}, {1, 1}, {0.5, 1}, {0, 1}, {0, 0.5}, {0, 0}}
+	resolver := NewGeometryResolver(0.001)
+	resolver.AddSource("UGC", GeometrySet{"ARC067": {{ring}}})
+
+	geometry, _ := resolver.Geometry("UGC", "ARC067")
+
+	assertEqual(t, len(geometry[0][0]), 5, "The square should be simplified to its corners")
+}
+
+func TestResolverFallsBackToRegisteredSchemes(t *testing.T) {
+	if err := SetGeocodeGeometry("UGC", GeometrySet{"ARC067": {{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetGeocodeGeometry("UGC", nil)
+
+	_, ok := NewGeometryResolver(0).Geometry("UGC", "ARC067")
+
+	assertEqual(t, ok, true, "The registered scheme's geometry should be used")
+}
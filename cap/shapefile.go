@@ -0,0 +1,275 @@
+package cap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Shapefile shape types holding polygons
+const (
+	shapeNull     int32 = 0
+	shapePolygon  int32 = 5
+	shapePolygonZ int32 = 15
+	shapePolygonM int32 = 25
+)
+
+// shapefileCode is the file code at the start of every .shp file
+const shapefileCode int32 = 9994
+
+// maxShapefileRecordSize bounds the length of a single .shp record, so a
+// corrupt header cannot make ReadShapefile allocate gigabytes
+const maxShapefileRecordSize int64 = 1024 * 1024 * 64
+
+// LoadShapefile reads the polygons of an ESRI shapefile into a GeometrySet,
+// keyed by the named attribute of the .dbf file next to the .shp file at path
+//
+// Coordinates must be longitude and latitude, as in the Census Bureau and NWS
+// boundary files; the .prj file is not read. See ReadShapefile.
+func LoadShapefile(path, property string) (GeometrySet, error) {
+	shp, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer shp.Close()
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	dbf, err := os.Open(base + ".dbf")
+
+	if os.IsNotExist(err) {
+		dbf, err = os.Open(base + ".DBF")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer dbf.Close()
+
+	return ReadShapefile(bufio.NewReader(shp), bufio.NewReader(dbf), property)
+}
+
+// ReadShapefile reads the Polygon, PolygonZ and PolygonM shapes of a .shp
+// stream into a GeometrySet, keyed by the named attribute of the matching .dbf
+// stream
+//
+// Clockwise rings start new polygons and counterclockwise rings are holes of
+// the polygon containing them, as the shapefile specification lays out. Null
+// shapes, deleted records and records with an empty attribute are skipped, and
+// records sharing a code are merged.
+func ReadShapefile(shp, dbf io.Reader, property string) (GeometrySet, error) {
+	codes, err := readDBFColumn(dbf, property)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var header [100]byte
+
+	if _, err := io.ReadFull(shp, header[:]); err != nil {
+		return nil, fmt.Errorf("Invalid shapefile header: %s", err)
+	}
+
+	if int32(binary.BigEndian.Uint32(header[0:4])) != shapefileCode {
+		return nil, fmt.Errorf("Invalid shapefile: unexpected file code")
+	}
+
+	set := make(GeometrySet)
+
+	for i := 0; ; i++ {
+		var recordHeader [8]byte
+
+		if _, err := io.ReadFull(shp, recordHeader[:]); err == io.EOF {
+			return set, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("Invalid shapefile record %d: %s", i, err)
+		}
+
+		length := int64(binary.BigEndian.Uint32(recordHeader[4:8])) * 2
+
+		if length < 4 || length > maxShapefileRecordSize {
+			return nil, fmt.Errorf("Invalid shapefile record %d: bad length %d", i, length)
+		}
+
+		content := make([]byte, length)
+
+		if _, err := io.ReadFull(shp, content); err != nil {
+			return nil, fmt.Errorf("Invalid shapefile record %d: %s", i, err)
+		}
+
+		if i >= len(codes) || codes[i] == "" {
+			continue
+		}
+
+		geometry, err := parseShapefilePolygon(content)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid shapefile record %d: %s", i, err)
+		}
+
+		if len(geometry) > 0 {
+			set[codes[i]] = append(set[codes[i]], geometry...)
+		}
+	}
+}
+
+// parseShapefilePolygon parses the content of a polygon record, nil for a null shape
+func parseShapefilePolygon(content []byte) (MultiPolygon, error) {
+	switch shapeType := int32(binary.LittleEndian.Uint32(content[0:4])); shapeType {
+	case shapeNull:
+		return nil, nil
+	case shapePolygon, shapePolygonZ, shapePolygonM:
+	default:
+		return nil, fmt.Errorf("unsupported shape type %d", shapeType)
+	}
+
+	// The shape type and bounding box come before the part and point counts
+	if len(content) < 44 {
+		return nil, fmt.Errorf("truncated polygon")
+	}
+
+	numParts := int(binary.LittleEndian.Uint32(content[36:40]))
+	numPoints := int(binary.LittleEndian.Uint32(content[40:44]))
+
+	if numParts < 0 || numPoints < 0 || int64(44)+int64(numParts)*4+int64(numPoints)*16 > int64(len(content)) {
+		return nil, fmt.Errorf("truncated polygon")
+	}
+
+	points := content[44+numParts*4:]
+	var rings []Ring
+
+	for part := 0; part < numParts; part++ {
+		start := int(binary.LittleEndian.Uint32(content[44+part*4:]))
+		end := numPoints
+
+		if part+1 < numParts {
+			end = int(binary.LittleEndian.Uint32(content[44+(part+1)*4:]))
+		}
+
+		if start < 0 || start > end || end > numPoints {
+			return nil, fmt.Errorf("invalid part %d", part)
+		}
+
+		ring := make(Ring, 0, end-start)
+
+		for p := start; p < end; p++ {
+			x := math.Float64frombits(binary.LittleEndian.Uint64(points[p*16:]))
+			y := math.Float64frombits(binary.LittleEndian.Uint64(points[p*16+8:]))
+			ring = append(ring, [2]float64{x, y})
+		}
+
+		rings = append(rings, ring)
+	}
+
+	return assembleRings(rings), nil
+}
+
+// assembleRings groups clockwise outer rings with the counterclockwise holes inside them
+func assembleRings(rings []Ring) MultiPolygon {
+	var polygons MultiPolygon
+	var holes []Ring
+
+	for _, ring := range rings {
+		if len(ring) < 4 {
+			continue
+		}
+
+		if ring.SignedArea() < 0 {
+			polygons = append(polygons, Polygon{ring})
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	for _, hole := range holes {
+		placed := false
+
+		for i := range polygons {
+			if polygons[i][0].Contains(hole[0][1], hole[0][0]) {
+				polygons[i] = append(polygons[i], hole)
+				placed = true
+				break
+			}
+		}
+
+		// A counterclockwise ring outside every outer ring is a polygon wound the wrong way
+		if !placed {
+			polygons = append(polygons, Polygon{hole})
+		}
+	}
+
+	return polygons
+}
+
+// readDBFColumn returns back the trimmed values of the named column of a dBASE
+// table, with an empty value for deleted records
+func readDBFColumn(r io.Reader, column string) ([]string, error) {
+	var header [32]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("Invalid dBASE header: %s", err)
+	}
+
+	numRecords := int(binary.LittleEndian.Uint32(header[4:8]))
+	headerLength := int(binary.LittleEndian.Uint16(header[8:10]))
+	recordLength := int(binary.LittleEndian.Uint16(header[10:12]))
+
+	if headerLength < 33 || recordLength < 1 {
+		return nil, fmt.Errorf("Invalid dBASE header")
+	}
+
+	descriptors := make([]byte, headerLength-32)
+
+	if _, err := io.ReadFull(r, descriptors); err != nil {
+		return nil, fmt.Errorf("Invalid dBASE header: %s", err)
+	}
+
+	offset, width := -1, 0
+	position := 1 // Every record starts with its deletion flag
+
+	for i := 0; i+32 <= len(descriptors) && descriptors[i] != 0x0D; i += 32 {
+		name := strings.TrimRight(string(descriptors[i:i+11]), "\x00 ")
+		length := int(descriptors[i+16])
+
+		if strings.EqualFold(name, column) {
+			offset, width = position, length
+		}
+
+		position += length
+	}
+
+	if offset < 0 {
+		return nil, fmt.Errorf("Column %q not found in the dBASE table", column)
+	}
+
+	if offset+width > recordLength {
+		return nil, fmt.Errorf("Invalid dBASE header")
+	}
+
+	var values []string
+
+	record := make([]byte, recordLength)
+
+	for i := 0; i < numRecords; i++ {
+		if _, err := io.ReadFull(r, record); err != nil {
+			return nil, fmt.Errorf("Invalid dBASE record %d: %s", i, err)
+		}
+
+		value := ""
+
+		if record[0] != '*' {
+			value = strings.TrimSpace(string(record[offset : offset+width]))
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,156 @@
+package cap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testShape struct {
+	code  string
+	rings []Ring
+}
+
+// buildTestShapefile returns back the .shp and .dbf contents of polygon shapes
+// with a single NAME attribute, writing a null shape for shapes without rings
+func buildTestShapefile(shapes []testShape) ([]byte, []byte) {
+	var records bytes.Buffer
+
+	for i, shape := range shapes {
+		var content bytes.Buffer
+
+		if len(shape.rings) == 0 {
+			binary.Write(&content, binary.LittleEndian, shapeNull)
+		} else {
+			points := 0
+
+			for _, ring := range shape.rings {
+				points += len(ring)
+			}
+
+			binary.Write(&content, binary.LittleEndian, shapePolygon)
+			binary.Write(&content, binary.LittleEndian, [4]float64{})
+			binary.Write(&content, binary.LittleEndian, int32(len(shape.rings)))
+			binary.Write(&content, binary.LittleEndian, int32(points))
+
+			start := 0
+
+			for _, ring := range shape.rings {
+				binary.Write(&content, binary.LittleEndian, int32(start))
+				start += len(ring)
+			}
+
+			for _, ring := range shape.rings {
+				for _, pos := range ring {
+					binary.Write(&content, binary.LittleEndian, pos)
+				}
+			}
+		}
+
+		binary.Write(&records, binary.BigEndian, int32(i+1))
+		binary.Write(&records, binary.BigEndian, int32(content.Len()/2))
+		records.Write(content.Bytes())
+	}
+
+	shp := make([]byte, 100)
+	binary.BigEndian.PutUint32(shp[0:], uint32(shapefileCode))
+	binary.BigEndian.PutUint32(shp[24:], uint32((100+records.Len())/2))
+	binary.LittleEndian.PutUint32(shp[28:], 1000)
+	binary.LittleEndian.PutUint32(shp[32:], uint32(shapePolygon))
+	shp = append(shp, records.Bytes()...)
+
+	const width = 10
+
+	dbf := make([]byte, 65)
+	dbf[0] = 3
+	binary.LittleEndian.PutUint32(dbf[4:], uint32(len(shapes)))
+	binary.LittleEndian.PutUint16(dbf[8:], 65)
+	binary.LittleEndian.PutUint16(dbf[10:], 1+width)
+	copy(dbf[32:], "NAME")
+	dbf[43] = 'C'
+	dbf[48] = width
+	dbf[64] = 0x0D
+
+	for _, shape := range shapes {
+		dbf = append(dbf, ' ')
+		dbf = append(dbf, []byte(shape.code + "          ")[:width]...)
+	}
+
+	return shp, append(dbf, 0x1A)
+}
+
+var testShapes = []testShape{
+	{code: "05067", rings: []Ring{
+		// Clockwise outer ring with a counterclockwise hole
+		{{-91.5, 35.4}, {-91.5, 35.9}, {-91.0, 35.9}, {-91.0, 35.4}, {-91.5, 35.4}},
+		{{-91.3, 35.6}, {-91.2, 35.6}, {-91.2, 35.7}, {-91.3, 35.7}, {-91.3, 35.6}},
+	}},
+	{code: "05147", rings: []Ring{
+		{{-91.5, 34.9}, {-91.5, 35.4}, {-91.0, 35.4}, {-91.0, 34.9}, {-91.5, 34.9}},
+	}},
+	{code: "05999"},
+}
+
+func TestReadShapefile(t *testing.T) {
+	shp, dbf := buildTestShapefile(testShapes)
+	set, err := ReadShapefile(bytes.NewReader(shp), bytes.NewReader(dbf), "name")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(set), 2, "Null shapes should be skipped")
+	assertEqual(t, len(set["05067"]), 1, "The outer ring should make one polygon")
+	assertEqual(t, len(set["05067"][0]), 2, "The hole should belong to the outer ring")
+	assertEqual(t, set["05067"].Contains(35.5, -91.4), true, "The point should be inside the county")
+	assertEqual(t, set["05067"].Contains(35.65, -91.25), false, "The point should be inside the hole")
+}
+
+func TestReadShapefileReportsProblems(t *testing.T) {
+	shp, dbf := buildTestShapefile(testShapes)
+
+	_, err := ReadShapefile(bytes.NewReader(shp), bytes.NewReader(dbf), "GEOID")
+
+	assertEqual(t, err.Error(), `Column "GEOID" not found in the dBASE table`, "Missing columns should be reported")
+
+	_, err = ReadShapefile(bytes.NewReader(shp[:150]), bytes.NewReader(dbf), "NAME")
+
+	assertStartsWith(t, err.Error(), "Invalid shapefile record 0", "Truncated files should be reported")
+
+	corrupt := append([]byte{}, shp...)
+	binary.BigEndian.PutUint32(corrupt[104:108], 0x7fffffff)
+	_, err = ReadShapefile(bytes.NewReader(corrupt), bytes.NewReader(dbf), "NAME")
+
+	assertEqual(t, err.Error(), "Invalid shapefile record 0: bad length 4294967294", "Oversized records should be rejected before they are read")
+
+	_, err = ReadShapefile(bytes.NewReader(dbf), bytes.NewReader(dbf), "NAME")
+
+	assertStartsWith(t, err.Error(), "Invalid shapefile", "Other files should be rejected")
+}
+
+func TestLoadShapefile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cap-shapefile")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	shp, dbf := buildTestShapefile(testShapes)
+	ioutil.WriteFile(filepath.Join(dir, "counties.shp"), shp, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "counties.dbf"), dbf, 0644)
+
+	set, err := LoadShapefile(filepath.Join(dir, "counties.shp"), "NAME")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(set), 2, "The shapes should be read")
+	assertEqual(t, math.Abs(set["05147"][0][0].SignedArea()), 0.25, "The ring should be read in full")
+}
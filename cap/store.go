@@ -0,0 +1,336 @@
+package cap
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StoreEventType describes the kind of change made to a Store
+type StoreEventType int
+
+// The changes reported to Store listeners
+const (
+	// StoreAdded is reported when a new alert becomes active
+	StoreAdded StoreEventType = iota
+	// StoreUpdated is reported when an Update message supersedes an active alert
+	StoreUpdated
+	// StoreCancelled is reported when a Cancel message removes an active alert
+	StoreCancelled
+	// StoreExpired is reported when every Info block of an active alert has expired
+	StoreExpired
+	// StoreAcknowledged is reported when an Ack message references an active alert
+	StoreAcknowledged
+	// StoreRejected is reported when an Error message references an active alert
+	StoreRejected
+)
+
+// SupersessionRetention is how long a Store remembers an alert was replaced
+// by a message that has no expiry of its own, such as a Cancel without Info
+// blocks of an alert the Store never saw, counted from when the message was sent
+var SupersessionRetention = 48 * time.Hour
+
+// StoreEvent describes a single change made to a Store
+type StoreEvent struct {
+	Type StoreEventType
+
+	// Alert is the message that caused the change
+	Alert *Alert
+
+	// Previous is the active alert affected by the change, if any
+	Previous *Alert
+}
+
+// Store keeps track of the currently active alerts
+//
+// Alerts are added with Add, which resolves Update, Cancel, Ack and Error
+// messages against the alerts listed in their references. A Store is safe for
+// concurrent use.
+type Store struct {
+	mu         sync.RWMutex
+	active     map[string]*Alert
+	superseded map[string]supersession
+	listeners  []func(StoreEvent)
+}
+
+// supersession records the Update or Cancel message that replaced an alert,
+// so late copies of the alert are ignored until the message expires
+type supersession struct {
+	by       *Alert
+	previous *Alert
+}
+
+// expired returns back true once the superseding message has expired, or for
+// messages without Info blocks, once the alert they replaced has
+//
+// When neither has Info blocks, the supersession expires SupersessionRetention
+// after the message was sent, or at once if its sent date cannot be parsed.
+func (s supersession) expired(at time.Time) bool {
+	if len(s.by.Infos) > 0 {
+		return activeInfos(s.by, at) == nil
+	}
+
+	if s.previous != nil && len(s.previous.Infos) > 0 {
+		return activeInfos(s.previous, at) == nil
+	}
+
+	sent, err := ParseCAPDate(s.by.SentDate)
+
+	return err != nil || !at.Before(sent.Add(SupersessionRetention))
+}
+
+// NewStore creates an empty Store
+func NewStore() *Store {
+	return &Store{
+		active:     make(map[string]*Alert),
+		superseded: make(map[string]supersession),
+	}
+}
+
+// storeKey identifies a message by its sender and identifier
+func storeKey(senderID, messageID string) string {
+	return senderID + "," + messageID
+}
+
+// OnChange registers a function that is called after every change to the Store
+//
+// Listeners are called synchronously, outside of the Store's lock, so they may
+// query the Store but should return quickly.
+func (s *Store) OnChange(fn func(StoreEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, fn)
+}
+
+// Add ingests an alert, updating the set of active alerts based on its MessageType
+func (s *Store) Add(alert *Alert) error {
+	refs, err := alert.References()
+
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	events, err := s.add(alert, refs)
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	notify(listeners, events)
+
+	return nil
+}
+
+func (s *Store) add(alert *Alert, refs []Reference) ([]StoreEvent, error) {
+	key := storeKey(alert.SenderID, alert.MessageID)
+
+	if _, exists := s.superseded[key]; exists {
+		return nil, nil
+	}
+
+	var events []StoreEvent
+
+	switch alert.MessageType {
+	case "Alert":
+		if _, exists := s.active[key]; !exists {
+			events = append(events, StoreEvent{Type: StoreAdded, Alert: alert})
+		}
+
+		s.active[key] = alert
+	case "Update", "Cancel":
+		eventType := StoreUpdated
+
+		if alert.MessageType == "Cancel" {
+			eventType = StoreCancelled
+		}
+
+		for _, ref := range refs {
+			refKey := storeKey(ref.SenderID, ref.MessageID)
+			previous, exists := s.active[refKey]
+			s.superseded[refKey] = supersession{by: alert, previous: previous}
+
+			if exists {
+				delete(s.active, refKey)
+				events = append(events, StoreEvent{Type: eventType, Alert: alert, Previous: previous})
+			}
+		}
+
+		if alert.MessageType == "Update" {
+			if len(events) == 0 {
+				events = append(events, StoreEvent{Type: StoreAdded, Alert: alert})
+			}
+
+			s.active[key] = alert
+		}
+	case "Ack", "Error":
+		eventType := StoreAcknowledged
+
+		if alert.MessageType == "Error" {
+			eventType = StoreRejected
+		}
+
+		for _, ref := range refs {
+			if previous, exists := s.active[storeKey(ref.SenderID, ref.MessageID)]; exists {
+				events = append(events, StoreEvent{Type: eventType, Alert: alert, Previous: previous})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Unknown message type: %q", alert.MessageType)
+	}
+
+	return events, nil
+}
+
+// Get returns back the active alert with the specified sender and identifier, or nil
+func (s *Store) Get(senderID, messageID string) *Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.active[storeKey(senderID, messageID)]
+}
+
+// Len returns back the number of alerts in the Store, including expired ones not yet pruned
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.active)
+}
+
+// Active returns back the alerts that are active at the specified time
+//
+// Info blocks that have expired are dropped from the returned alerts, and
+// alerts whose Info blocks have all expired are omitted. The stored alerts are
+// not modified. Alerts are returned ordered by sent date, comparing the
+// instants so alerts sent with different UTC offsets sort correctly.
+func (s *Store) Active(at time.Time) []*Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found := make([]*Alert, 0, len(s.active))
+
+	for _, alert := range s.active {
+		if current := activeInfos(alert, at); current != nil {
+			found = append(found, current)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		si, sj := sentUnix(found[i]), sentUnix(found[j])
+
+		if si != sj {
+			return si < sj
+		}
+
+		return storeKey(found[i].SenderID, found[i].MessageID) < storeKey(found[j].SenderID, found[j].MessageID)
+	})
+
+	return found
+}
+
+// Prune removes alerts whose Info blocks have all expired at the specified time
+//
+// The record of alerts replaced by Update and Cancel messages is also dropped
+// once those messages have expired, see SupersessionRetention.
+func (s *Store) Prune(at time.Time) []*Alert {
+	s.mu.Lock()
+
+	var (
+		removed []*Alert
+		events  []StoreEvent
+	)
+
+	for key, alert := range s.active {
+		if activeInfos(alert, at) == nil {
+			delete(s.active, key)
+			removed = append(removed, alert)
+			events = append(events, StoreEvent{Type: StoreExpired, Alert: alert})
+		}
+	}
+
+	for key, entry := range s.superseded {
+		if entry.expired(at) {
+			delete(s.superseded, key)
+		}
+	}
+
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	notify(listeners, events)
+
+	return removed
+}
+
+// activeInfos returns back a copy of the alert without the Info blocks that have
+// expired at the specified time, or nil if every Info block has expired
+//
+// Alerts without any Info blocks never expire.
+func activeInfos(alert *Alert, at time.Time) *Alert {
+	if len(alert.Infos) == 0 {
+		return alert
+	}
+
+	infos := make([]Info, 0, len(alert.Infos))
+
+	for _, info := range alert.Infos {
+		if !info.Expired(at) {
+			infos = append(infos, info)
+		}
+	}
+
+	if len(infos) == 0 {
+		return nil
+	}
+
+	if len(infos) == len(alert.Infos) {
+		return alert
+	}
+
+	current := *alert
+	current.Infos = infos
+
+	return &current
+}
+
+// Expired returns back whether the Info block has expired at the specified time
+//
+// An Info block without an expires date, or with an expires date that cannot be
+// parsed, never expires.
+func (info *Info) Expired(at time.Time) bool {
+	if info.ExpiresDate == "" {
+		return false
+	}
+
+	expires, err := ParseCAPDate(info.ExpiresDate)
+
+	if err != nil {
+		return false
+	}
+
+	return !at.Before(expires)
+}
+
+// sentUnix returns back the sent date of the alert in seconds since the epoch, or zero if it cannot be parsed
+func sentUnix(alert *Alert) int64 {
+	sent, err := ParseCAPDate(alert.SentDate)
+
+	if err != nil {
+		return 0
+	}
+
+	return sent.Unix()
+}
+
+func notify(listeners []func(StoreEvent), events []StoreEvent) {
+	for _, event := range events {
+		for _, fn := range listeners {
+			fn(event)
+		}
+	}
+}
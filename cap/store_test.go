@@ -0,0 +1,209 @@
+package cap
+
+import (
+	"testing"
+	"time"
+)
+
+func newStoreTestAlert(id, msgType, expires string, refs ...string) *Alert {
+	alert := Alert{
+		MessageID:    id,
+		SenderID:     "sender@example.com",
+		SentDate:     "2015-08-15T20:45:00-05:00",
+		MessageType:  msgType,
+		ReferenceIDs: refs,
+	}
+
+	if expires != "" {
+		alert.Infos = []Info{{EventType: "Flood Warning", ExpiresDate: expires}}
+	}
+
+	return &alert
+}
+
+var storeTestTime = time.Date(2015, 8, 16, 0, 0, 0, 0, time.UTC)
+
+func TestStoreAddAlertIsActive(t *testing.T) {
+	store := NewStore()
+	alert := newStoreTestAlert("1", "Alert", "2015-08-16T11:45:00-05:00")
+
+	if err := store.Add(alert); err != nil {
+		t.Fatal(err)
+	}
+
+	active := store.Active(storeTestTime)
+
+	assertEqual(t, len(active), 1, "One alert should be active")
+	assertEqual(t, active[0], alert, "The stored alert should be returned")
+	assertEqual(t, store.Get("sender@example.com", "1"), alert, "Get should return the stored alert")
+}
+
+func TestStoreUpdateSupersedesReferencedAlert(t *testing.T) {
+	store := NewStore()
+	original := newStoreTestAlert("1", "Alert", "2015-08-16T11:45:00-05:00")
+	update := newStoreTestAlert("2", "Update", "2015-08-16T11:45:00-05:00",
+		"sender@example.com,1,2015-08-15T20:45:00-05:00")
+
+	var events []StoreEvent
+	store.OnChange(func(e StoreEvent) { events = append(events, e) })
+
+	store.Add(original)
+	store.Add(update)
+
+	assertEqual(t, store.Len(), 1, "Only the update should be stored")
+	assertEqual(t, store.Get("sender@example.com", "1") == nil, true, "The original should be superseded")
+	assertEqual(t, len(events), 2, "Two events should be reported")
+	assertEqual(t, events[1].Type, StoreUpdated, "The second event should be an update")
+	assertEqual(t, events[1].Previous, original, "The update should reference the original")
+
+	// A late copy of the original must not become active again
+	store.Add(original)
+	assertEqual(t, store.Len(), 1, "Superseded alerts should be ignored")
+}
+
+func TestStoreCancelRemovesReferencedAlert(t *testing.T) {
+	store := NewStore()
+	store.Add(newStoreTestAlert("1", "Alert", "2015-08-16T11:45:00-05:00"))
+
+	err := store.Add(newStoreTestAlert("2", "Cancel", "",
+		"sender@example.com,1,2015-08-15T20:45:00-05:00"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(store.Active(storeTestTime)), 0, "No alerts should be active")
+}
+
+func TestStoreAckDoesNotChangeActiveAlerts(t *testing.T) {
+	store := NewStore()
+	store.Add(newStoreTestAlert("1", "Alert", "2015-08-16T11:45:00-05:00"))
+
+	var events []StoreEvent
+	store.OnChange(func(e StoreEvent) { events = append(events, e) })
+
+	store.Add(newStoreTestAlert("2", "Ack", "", "sender@example.com,1,2015-08-15T20:45:00-05:00"))
+
+	assertEqual(t, store.Len(), 1, "The acknowledged alert should remain active")
+	assertEqual(t, len(events), 1, "One event should be reported")
+	assertEqual(t, events[0].Type, StoreAcknowledged, "The event should be an acknowledgement")
+}
+
+func TestStoreActiveDropsExpiredInfos(t *testing.T) {
+	store := NewStore()
+	alert := newStoreTestAlert("1", "Alert", "2015-08-15T18:00:00-05:00")
+	alert.Infos = append(alert.Infos, Info{EventType: "Flood Watch", ExpiresDate: "2015-08-16T11:45:00-05:00"})
+	store.Add(alert)
+
+	active := store.Active(storeTestTime)
+
+	assertEqual(t, len(active), 1, "One alert should be active")
+	assertEqual(t, len(active[0].Infos), 1, "The expired Info should be dropped")
+	assertEqual(t, active[0].Infos[0].EventType, "Flood Watch", "The remaining Info does not match!")
+	assertEqual(t, len(alert.Infos), 2, "The stored alert should not be modified")
+}
+
+func TestStorePruneRemovesExpiredAlerts(t *testing.T) {
+	store := NewStore()
+	store.Add(newStoreTestAlert("1", "Alert", "2015-08-15T18:00:00-05:00"))
+	store.Add(newStoreTestAlert("2", "Alert", "2015-08-16T11:45:00-05:00"))
+
+	var events []StoreEvent
+	store.OnChange(func(e StoreEvent) { events = append(events, e) })
+
+	removed := store.Prune(storeTestTime)
+
+	assertEqual(t, len(removed), 1, "One alert should be pruned")
+	assertEqual(t, removed[0].MessageID, "1", "The expired alert should be pruned")
+	assertEqual(t, store.Len(), 1, "One alert should remain")
+	assertEqual(t, events[0].Type, StoreExpired, "An expiry event should be reported")
+}
+
+func TestStoreActiveOrdersBySentInstant(t *testing.T) {
+	store := NewStore()
+	later := newStoreTestAlert("1", "Alert", "2015-08-16T11:45:00-05:00")
+	later.SentDate = "2015-08-15T20:45:00-05:00"
+	earlier := newStoreTestAlert("2", "Alert", "2015-08-16T11:45:00-05:00")
+	earlier.SentDate = "2015-08-16T00:30:00+02:00"
+	store.Add(later)
+	store.Add(earlier)
+
+	active := store.Active(storeTestTime)
+
+	// Compared as strings, "2015-08-16T00:30" would sort after "2015-08-15T20:45"
+	assertEqual(t, active[0], earlier, "The alert sent first should come first")
+	assertEqual(t, active[1], later, "The alert sent last should come last")
+}
+
+func TestStorePruneForgetsExpiredSupersessions(t *testing.T) {
+	store := NewStore()
+	original := newStoreTestAlert("1", "Alert", "2015-08-15T22:00:00-05:00")
+	store.Add(original)
+	store.Add(newStoreTestAlert("2", "Update", "2015-08-15T23:00:00-05:00", "sender@example.com,1,2015-08-15T20:45:00-05:00"))
+	store.Add(newStoreTestAlert("3", "Alert", "2015-08-16T11:45:00-05:00"))
+	store.Add(newStoreTestAlert("4", "Cancel", "", "sender@example.com,3,2015-08-15T20:45:00-05:00"))
+
+	assertEqual(t, len(store.superseded), 2, "Both replaced alerts should be remembered")
+
+	store.Prune(storeTestTime.Add(6 * time.Hour))
+
+	assertEqual(t, len(store.superseded), 1, "The expired update should be forgotten")
+	assertEqual(t, store.superseded[storeKey("sender@example.com", "3")].by.MessageID, "4", "The cancel of an unexpired alert should be remembered")
+
+	store.Prune(storeTestTime.AddDate(0, 0, 1))
+
+	assertEqual(t, len(store.superseded), 0, "Cancels without infos should be forgotten when the alert they cancelled expires")
+}
+
+func TestStorePruneForgetsCancelsOfUnknownAlerts(t *testing.T) {
+	store := NewStore()
+	store.Add(newStoreTestAlert("2", "Cancel", "", "sender@example.com,1,2015-08-15T20:00:00-05:00"))
+
+	assertEqual(t, len(store.superseded), 1, "The cancelled alert should be remembered")
+
+	sent, _ := ParseCAPDate("2015-08-15T20:45:00-05:00")
+	store.Prune(sent.Add(SupersessionRetention - time.Hour))
+
+	assertEqual(t, len(store.superseded), 1, "The cancel should be remembered until the retention ends")
+
+	store.Prune(sent.Add(SupersessionRetention))
+
+	assertEqual(t, len(store.superseded), 0, "The cancel should be forgotten once the retention ends")
+}
+
+func TestStoreAddReturnsErrForInvalidReferences(t *testing.T) {
+	store := NewStore()
+
+	err := store.Add(newStoreTestAlert("2", "Update", "", "not-a-reference"))
+
+	assertStartsWith(t, err.Error(), "Invalid reference", "Unexpected or missing error message")
+}
+
+func TestStoreAddReturnsErrForUnknownMessageType(t *testing.T) {
+	store := NewStore()
+
+	err := store.Add(newStoreTestAlert("1", "Bogus", ""))
+
+	assertStartsWith(t, err.Error(), "Unknown message type", "Unexpected or missing error message")
+}
+
+func TestStoreIsSafeForConcurrentUse(t *testing.T) {
+	store := NewStore()
+	done := make(chan bool)
+
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			for j := 0; j < 100; j++ {
+				store.Add(newStoreTestAlert(string(rune('a'+i))+string(rune('0'+j%10)), "Alert", "2015-08-16T11:45:00-05:00"))
+				store.Active(storeTestTime)
+			}
+			done <- true
+		}(i)
+	}
+
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	assertEqual(t, store.Len(), 40, "Every distinct alert should be stored")
+}
@@ -0,0 +1,170 @@
+package cap
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultIdentifierGenerator creates the identifiers of the messages returned by
+// NewUpdate, NewCancel, NewAck and NewError
+var DefaultIdentifierGenerator IdentifierGenerator = UUIDGenerator{Prefix: "urn:uuid:"}
+
+// NewUpdate returns back an Update message superseding the alert
+//
+// The update keeps the sender, status, scope and a copy of every Info block for
+// the caller to edit. Its references list the alert and every message the
+// alert itself referenced, so recipients that missed earlier messages can still
+// retire them.
+func (alert *Alert) NewUpdate() (*Alert, error) {
+	update, err := alert.successor("Update")
+
+	if err != nil {
+		return nil, err
+	}
+
+	update.ReferenceIDs = alert.referenceChain()
+	update.Infos = copyInfos(alert.Infos)
+
+	return update, nil
+}
+
+// NewCancel returns back a Cancel message withdrawing the alert, with the reason in its note
+//
+// Like NewUpdate, the references list the whole chain of messages and the Info
+// blocks are copied, so the cancellation can be shown with the event it ends.
+func (alert *Alert) NewCancel(reason string) (*Alert, error) {
+	cancel, err := alert.successor("Cancel")
+
+	if err != nil {
+		return nil, err
+	}
+
+	cancel.Note = reason
+	cancel.ReferenceIDs = alert.referenceChain()
+	cancel.Infos = copyInfos(alert.Infos)
+
+	return cancel, nil
+}
+
+// NewAck returns back an Ack message from sender acknowledging receipt of the alert
+//
+// The acknowledgement references only the alert, carries no Info blocks, and is
+// addressed privately to the alert's sender.
+func (alert *Alert) NewAck(sender string) (*Alert, error) {
+	ack, err := alert.successor("Ack")
+
+	if err != nil {
+		return nil, err
+	}
+
+	ack.SenderID = sender
+	ack.Scope = "Private"
+	ack.Restriction = ""
+	ack.Addresses = alert.SenderID
+	ack.ReferenceIDs = []string{alert.Reference().String()}
+
+	return ack, nil
+}
+
+// NewError returns back an Error message rejecting the alert, with the explanation in its note
+//
+// The error references only the alert and carries no Info blocks.
+func (alert *Alert) NewError(note string) (*Alert, error) {
+	e, err := alert.successor("Error")
+
+	if err != nil {
+		return nil, err
+	}
+
+	e.Note = note
+	e.ReferenceIDs = []string{alert.Reference().String()}
+
+	return e, nil
+}
+
+// successor returns back a message of msgType following the alert, with a new
+// identifier and sent date and no note, references or Info blocks
+//
+// Signatures are dropped since they cannot be valid for the new message; other
+// extensions are kept.
+func (alert *Alert) successor(msgType string) (*Alert, error) {
+	identifier, err := DefaultIdentifierGenerator.NewIdentifier()
+
+	if err != nil {
+		return nil, err
+	}
+
+	next := Alert{
+		XMLName:       alert.XMLName,
+		MessageID:     identifier,
+		SenderID:      alert.SenderID,
+		SentDate:      time.Now().Format(CAPDate),
+		MessageStatus: alert.MessageStatus,
+		MessageType:   msgType,
+		Source:        alert.Source,
+		Scope:         alert.Scope,
+		Restriction:   alert.Restriction,
+		Addresses:     alert.Addresses,
+		HandlingCodes: append([]string(nil), alert.handlingCodes()...),
+		IncidentIDs:   append([]string(nil), alert.IncidentIDs...),
+		ExtraAttrs:    append(ExtraAttrs(nil), alert.ExtraAttrs...),
+	}
+
+	for _, element := range alert.Extra {
+		if element.Name().Space != XMLDSigNamespace {
+			next.Extra = append(next.Extra, element)
+		}
+	}
+
+	return &next, nil
+}
+
+// referenceChain returns back the references element of a message superseding
+// the alert: the alert's own references followed by the alert, without duplicates
+func (alert *Alert) referenceChain() []string {
+	var refs []string
+
+	seen := make(map[string]bool)
+
+	for _, field := range append(strings.Fields(strings.Join(alert.ReferenceIDs, " ")), alert.Reference().String()) {
+		if !seen[field] {
+			seen[field] = true
+			refs = append(refs, field)
+		}
+	}
+
+	// A single element, since each entry of ReferenceIDs is written as its own <references>
+	return []string{strings.Join(refs, " ")}
+}
+
+// copyInfos returns back a deep copy of infos, so editing the copy leaves the original alone
+func copyInfos(infos []Info) []Info {
+	if infos == nil {
+		return nil
+	}
+
+	copies := make([]Info, len(infos))
+
+	for i, info := range infos {
+		info.EventCode = append([]NamedValue(nil), info.EventCode...)
+		info.Parameters = append([]NamedValue(nil), info.Parameters...)
+		info.Resources = append([]Resource(nil), info.Resources...)
+		info.Extra = append([]RawElement(nil), info.Extra...)
+		info.ExtraAttrs = append(ExtraAttrs(nil), info.ExtraAttrs...)
+
+		areas := make([]Area, len(info.Areas))
+
+		for j, area := range info.Areas {
+			area.Geocodes = append([]NamedValue(nil), area.Geocodes...)
+			areas[j] = area
+		}
+
+		if info.Areas != nil {
+			info.Areas = areas
+		}
+
+		copies[i] = info
+	}
+
+	return copies
+}
@@ -0,0 +1,148 @@
+package cap
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getSuccessorAlert() *Alert {
+	return &Alert{
+		MessageID:     "KSTO1055887203",
+		SenderID:      "KSTO@NWS.NOAA.GOV",
+		SentDate:      "2003-06-17T14:57:00-07:00",
+		MessageStatus: "Actual",
+		MessageType:   "Update",
+		Scope:         "Public",
+		Note:          "Extended to 4 PM",
+		ReferenceIDs:  []string{"KSTO@NWS.NOAA.GOV,KSTO1055887200,2003-06-17T14:00:00-07:00"},
+		IncidentIDs:   []string{"fire-42"},
+		Infos: []Info{{
+			EventCategory: "Met",
+			EventType:     "SEVERE THUNDERSTORM",
+			Urgency:       "Immediate",
+			Severity:      "Severe",
+			Certainty:     "Observed",
+			Areas: []Area{{
+				Description: "EXTREME NORTH CENTRAL TUOLUMNE COUNTY",
+				Geocodes:    []NamedValue{{ValueName: "SAME", Value: "006109"}},
+			}},
+		}},
+		Extra: []RawElement{{Tokens: []xml.Token{
+			xml.StartElement{Name: xml.Name{Space: XMLDSigNamespace, Local: "Signature"}},
+			xml.EndElement{Name: xml.Name{Space: XMLDSigNamespace, Local: "Signature"}},
+		}}},
+	}
+}
+
+func TestNewUpdateReferencesTheWholeChain(t *testing.T) {
+	alert := getSuccessorAlert()
+	update, err := alert.NewUpdate()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, update.MessageType, "Update", "The message should be an Update")
+	assertEqual(t, update.SenderID, alert.SenderID, "The sender should be kept")
+	assertEqual(t, update.MessageID != alert.MessageID, true, "The update should have a new identifier")
+	assertEqual(t, ValidateIdentifier(update.MessageID), nil, "The new identifier should be valid")
+	assertEqual(t, update.Note, "", "The note should not be carried over")
+	assertEqual(t, len(update.ReferenceIDs), 1, "References should be a single element")
+	assertEqual(t, update.ReferenceIDs[0], "KSTO@NWS.NOAA.GOV,KSTO1055887200,2003-06-17T14:00:00-07:00 KSTO@NWS.NOAA.GOV,KSTO1055887203,2003-06-17T14:57:00-07:00", "Unexpected references")
+	assertEqual(t, update.IncidentIDs[0], "fire-42", "Incidents should be kept")
+	assertEqual(t, len(update.Extra), 0, "The signature should be dropped")
+	assertEqual(t, len(update.Infos), 1, "The infos should be carried over")
+
+	sent, err := ParseCAPDate(update.SentDate)
+
+	assertEqual(t, err, nil, "The sent date should be a CAP date")
+	assertEqual(t, time.Since(sent) < time.Minute, true, "The sent date should be now")
+
+	update.Infos[0].Areas[0].Geocodes[0].Value = "006043"
+
+	assertEqual(t, alert.Infos[0].Areas[0].Geocodes[0].Value, "006109", "Editing the update should not change the original")
+}
+
+func TestNewCancelKeepsInfosAndSetsReason(t *testing.T) {
+	alert := getSuccessorAlert()
+	alert.ReferenceIDs = nil
+	cancel, err := alert.NewCancel("The storm has weakened")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, cancel.MessageType, "Cancel", "The message should be a Cancel")
+	assertEqual(t, cancel.Note, "The storm has weakened", "The reason should be the note")
+	assertEqual(t, cancel.ReferenceIDs[0], alert.Reference().String(), "The cancel should reference the alert")
+	assertEqual(t, len(cancel.Infos), 1, "The infos should be carried over")
+	assertEqual(t, cancel.Validate(), nil, "The cancel should be valid")
+}
+
+func TestNewAckIsAddressedToTheSender(t *testing.T) {
+	alert := getSuccessorAlert()
+	ack, err := alert.NewAck("county-eoc@example.org")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, ack.MessageType, "Ack", "The message should be an Ack")
+	assertEqual(t, ack.SenderID, "county-eoc@example.org", "The acknowledging party should be the sender")
+	assertEqual(t, ack.Scope, "Private", "The ack should be private")
+	assertEqual(t, ack.Addresses, "KSTO@NWS.NOAA.GOV", "The ack should be addressed to the original sender")
+	assertEqual(t, strings.Join(ack.ReferenceIDs, " "), alert.Reference().String(), "The ack should reference only the alert")
+	assertEqual(t, len(ack.Infos), 0, "The infos should be cleared")
+	assertEqual(t, ack.Validate(), nil, "The ack should be valid")
+}
+
+func TestNewErrorSetsNote(t *testing.T) {
+	alert := getSuccessorAlert()
+	e, err := alert.NewError("Polygon does not match the counties")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, e.MessageType, "Error", "The message should be an Error")
+	assertEqual(t, e.Note, "Polygon does not match the counties", "The explanation should be the note")
+	assertEqual(t, strings.Join(e.ReferenceIDs, " "), alert.Reference().String(), "The error should reference only the alert")
+	assertEqual(t, len(e.Infos), 0, "The infos should be cleared")
+}
+
+func TestSuccessorsMarshalASingleReferencesElement(t *testing.T) {
+	update, err := getSuccessorAlert().NewUpdate()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := xml.Marshal(update)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, strings.Count(string(output), "<references>"), 1, "There should be one references element")
+}
+
+func TestStoreAppliesSuccessors(t *testing.T) {
+	store := NewStore()
+	alert := getSuccessorAlert()
+	alert.MessageType = "Alert"
+	alert.ReferenceIDs = nil
+
+	if err := store.Add(alert); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel, _ := alert.NewCancel("All clear")
+
+	if err := store.Add(cancel); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, store.Len(), 0, "The cancel should remove the alert")
+}
@@ -0,0 +1,251 @@
+package cap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValidationError describes a single rule an alert does not follow
+type ValidationError struct {
+	// Path locates the offending element, such as info[0].area[1].polygon
+	Path string
+
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors is returned when an alert breaks one or more rules
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Add records a problem with the element at path
+func (e *ValidationErrors) Add(path, format string, args ...interface{}) {
+	*e = append(*e, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns back nil if no problems were recorded, otherwise the ValidationErrors
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Enumerated values of CAP 1.2 elements
+var (
+	StatusValues       = []string{"Actual", "Exercise", "System", "Test", "Draft"}
+	MessageTypeValues  = []string{"Alert", "Update", "Cancel", "Ack", "Error"}
+	ScopeValues        = []string{"Public", "Restricted", "Private"}
+	CategoryValues     = []string{"Geo", "Met", "Safety", "Security", "Rescue", "Fire", "Health", "Env", "Transport", "Infra", "CBRNE", "Other"}
+	ResponseTypeValues = []string{"Shelter", "Evacuate", "Prepare", "Execute", "Avoid", "Monitor", "Assess", "AllClear", "None"}
+	UrgencyValues      = []string{"Immediate", "Expected", "Future", "Past", "Unknown"}
+	SeverityValues     = []string{"Extreme", "Severe", "Moderate", "Minor", "Unknown"}
+	CertaintyValues    = []string{"Observed", "Likely", "Possible", "Unlikely", "Unknown"}
+)
+
+// Validate checks the alert against the rules of the CAP 1.2 specification and
+// those of each of the given profiles
+//
+// The returned error is a ValidationErrors listing every problem found, or nil
+// if the alert is valid.
+func (alert *Alert) Validate(profiles ...Profile) error {
+	var errs ValidationErrors
+
+	requireIdentifier(&errs, "identifier", alert.MessageID)
+	requireIdentifier(&errs, "sender", alert.SenderID)
+	requireDate(&errs, "sent", alert.SentDate, true)
+	requireEnum(&errs, "status", alert.MessageStatus, StatusValues)
+	requireEnum(&errs, "msgType", alert.MessageType, MessageTypeValues)
+	requireEnum(&errs, "scope", alert.Scope, ScopeValues)
+
+	if alert.Scope == "Restricted" && strings.TrimSpace(alert.Restriction) == "" {
+		errs.Add("restriction", "is required when scope is Restricted")
+	}
+
+	if alert.Scope == "Private" && strings.TrimSpace(alert.Addresses) == "" {
+		errs.Add("addresses", "is required when scope is Private")
+	}
+
+	if _, err := alert.References(); err != nil {
+		errs.Add("references", "%s", err)
+	}
+
+	for i := range alert.Infos {
+		alert.Infos[i].validate(&errs, fmt.Sprintf("info[%d]", i))
+	}
+
+	for _, profile := range profiles {
+		profile.Validate(alert, &errs)
+	}
+
+	return errs.Err()
+}
+
+func (info *Info) validate(errs *ValidationErrors, path string) {
+	requireEnum(errs, path+".category", info.EventCategory, CategoryValues)
+	requireValue(errs, path+".event", info.EventType)
+
+	if info.ResponseType != "" {
+		requireEnum(errs, path+".responseType", info.ResponseType, ResponseTypeValues)
+	}
+
+	requireEnum(errs, path+".urgency", info.Urgency, UrgencyValues)
+	requireEnum(errs, path+".severity", info.Severity, SeverityValues)
+	requireEnum(errs, path+".certainty", info.Certainty, CertaintyValues)
+	requireDate(errs, path+".effective", info.EffectiveDate, false)
+	requireDate(errs, path+".onset", info.OnsetDate, false)
+	requireDate(errs, path+".expires", info.ExpiresDate, false)
+
+	for i, resource := range info.Resources {
+		resourcePath := fmt.Sprintf("%s.resource[%d]", path, i)
+		requireValue(errs, resourcePath+".resourceDesc", resource.Description)
+		requireValue(errs, resourcePath+".mimeType", resource.MIMEType)
+	}
+
+	for i, area := range info.Areas {
+		areaPath := fmt.Sprintf("%s.area[%d]", path, i)
+		requireValue(errs, areaPath+".areaDesc", area.Description)
+
+		for j, polygon := range area.polygons() {
+			if err := validatePolygon(polygon); err != nil {
+				errs.Add(fmt.Sprintf("%s.polygon[%d]", areaPath, j), "%s", err)
+			}
+		}
+
+		for j, circle := range area.circles() {
+			if err := validateCircle(circle); err != nil {
+				errs.Add(fmt.Sprintf("%s.circle[%d]", areaPath, j), "%s", err)
+			}
+		}
+	}
+}
+
+func requireValue(errs *ValidationErrors, path, value string) {
+	if strings.TrimSpace(value) == "" {
+		errs.Add(path, "is required")
+	}
+}
+
+// requireIdentifier checks a required identifier or sender follows the character rules of section 3.2.1
+func requireIdentifier(errs *ValidationErrors, path, value string) {
+	if strings.TrimSpace(value) == "" {
+		errs.Add(path, "is required")
+		return
+	}
+
+	if problem := identifierProblem(value); problem != "" {
+		errs.Add(path, "%q %s", value, problem)
+	}
+}
+
+func requireEnum(errs *ValidationErrors, path, value string, allowed []string) {
+	if strings.TrimSpace(value) == "" {
+		errs.Add(path, "is required")
+		return
+	}
+
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	errs.Add(path, "%q is not one of %s", value, strings.Join(allowed, ", "))
+}
+
+func requireDate(errs *ValidationErrors, path, value string, required bool) {
+	if value == "" {
+		if required {
+			errs.Add(path, "is required")
+		}
+
+		return
+	}
+
+	if _, err := ParseCAPDate(value); err != nil {
+		errs.Add(path, "%q is not a CAP date", value)
+	}
+}
+
+// validatePolygon checks a CAP polygon has at least four closed coordinate pairs
+func validatePolygon(value string) error {
+	pairs := strings.Fields(value)
+
+	if len(pairs) < 4 {
+		return fmt.Errorf("must have at least 4 coordinate pairs")
+	}
+
+	for _, pair := range pairs {
+		if _, _, err := parseCoordinatePair(pair); err != nil {
+			return err
+		}
+	}
+
+	if pairs[0] != pairs[len(pairs)-1] {
+		return fmt.Errorf("first and last coordinate pairs must be the same")
+	}
+
+	return nil
+}
+
+// validateCircle checks a CAP circle is a coordinate pair followed by a radius
+func validateCircle(value string) error {
+	fields := strings.Fields(value)
+
+	if len(fields) != 2 {
+		return fmt.Errorf("must be a coordinate pair followed by a radius")
+	}
+
+	if _, _, err := parseCoordinatePair(fields[0]); err != nil {
+		return err
+	}
+
+	radius, err := strconv.ParseFloat(fields[1], 64)
+
+	if err != nil || radius < 0 {
+		return fmt.Errorf("invalid radius %q", fields[1])
+	}
+
+	return nil
+}
+
+// parseCoordinatePair parses a WGS 84 "lat,lon" coordinate pair
+func parseCoordinatePair(pair string) (float64, float64, error) {
+	parts := strings.Split(pair, ",")
+
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid coordinate pair %q", pair)
+	}
+
+	lat, err := strconv.ParseFloat(parts[0], 64)
+
+	if err != nil || !(lat >= -90 && lat <= 90) {
+		return 0, 0, fmt.Errorf("invalid latitude in %q", pair)
+	}
+
+	lon, err := strconv.ParseFloat(parts[1], 64)
+
+	if err != nil || !(lon >= -180 && lon <= 180) {
+		return 0, 0, fmt.Errorf("invalid longitude in %q", pair)
+	}
+
+	return lat, lon, nil
+}
@@ -0,0 +1,56 @@
+package cap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAcceptsAlertExample(t *testing.T) {
+	alert, err := getCAPAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := alert.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	alert := Alert{
+		SenderID:      "sender@example.com",
+		SentDate:      "yesterday",
+		MessageStatus: "Actual",
+		MessageType:   "Alert",
+		Scope:         "Restricted",
+		Infos: []Info{{
+			EventCategory: "Weather",
+			EventType:     "Flood Warning",
+			Urgency:       "Expected",
+			Severity:      "Moderate",
+			Certainty:     "Likely",
+			Resources:     []Resource{{Description: "Map"}},
+			Areas:         []Area{{Description: "Test", Polygons: []string{"1,1 2,2 3,3 4,4"}, Circles: []string{"91,0 10"}}},
+		}},
+	}
+
+	err := alert.Validate()
+	errs, ok := err.(ValidationErrors)
+
+	if !ok {
+		t.Fatalf("Expected ValidationErrors but got %v", err)
+	}
+
+	paths := make([]string, len(errs))
+
+	for i, e := range errs {
+		paths[i] = e.Path
+	}
+
+	assertEqual(t,
+		strings.Join(paths, " "),
+		"identifier sent restriction info[0].category info[0].resource[0].mimeType info[0].area[0].polygon[0] info[0].area[0].circle[0]",
+		"Unexpected validation errors")
+	assertStartsWith(t, errs[0].Error(), "identifier: is required", "Unexpected error message")
+}
@@ -0,0 +1,229 @@
+package cap
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VTEC action codes used in the action field of a P-VTEC string
+const (
+	VTECActionNew         string = "NEW"
+	VTECActionContinue    string = "CON"
+	VTECActionExtendTime  string = "EXT"
+	VTECActionExtendArea  string = "EXA"
+	VTECActionExtendBoth  string = "EXB"
+	VTECActionUpgrade     string = "UPG"
+	VTECActionCancel      string = "CAN"
+	VTECActionExpire      string = "EXP"
+	VTECActionCorrection  string = "COR"
+	VTECActionRoutine     string = "ROU"
+	VTECActionEventReturn string = "EXR"
+)
+
+// VTECDate is the form of the date / time groups used in VTEC strings
+const VTECDate string = "060102T1504Z"
+
+// vtecZeroDate is used in VTEC strings for times that are not known or not applicable
+const vtecZeroDate string = "000000T0000Z"
+
+var pvtecPattern = regexp.MustCompile(
+	`^/([OTEX])\.([A-Z]{3})\.([A-Z0-9]{4})\.([A-Z]{2})\.([A-Z])\.(\d{4})\.(\d{6}T\d{4}Z)-(\d{6}T\d{4}Z)/$`)
+
+var hvtecPattern = regexp.MustCompile(
+	`^/([A-Z0-9]{5})\.([N0-3U])\.([A-Z]{2})\.(\d{6}T\d{4}Z)\.(\d{6}T\d{4}Z)\.(\d{6}T\d{4}Z)\.([A-Z]{2})/$`)
+
+// VTEC represents a Primary Valid Time Event Code (P-VTEC) used by the National Weather Service
+//
+// See NWS Directive 10-1703 for a description of each field
+type VTEC struct {
+	ProductClass        string
+	Action              string
+	Office              string
+	Phenomena           string
+	Significance        string
+	EventTrackingNumber int
+	BeginDate           time.Time
+	EndDate             time.Time
+
+	// Hydrologic is the H-VTEC string that followed this P-VTEC, if any
+	Hydrologic *HVTEC
+}
+
+// HVTEC represents a Hydrologic Valid Time Event Code (H-VTEC) used by the National Weather Service
+type HVTEC struct {
+	LocationID     string
+	FloodSeverity  string
+	ImmediateCause string
+	BeginDate      time.Time
+	CrestDate      time.Time
+	EndDate        time.Time
+	FloodRecord    string
+}
+
+// ParseVTEC parses a single P-VTEC string such as /O.NEW.KLZK.FL.W.0061.150816T0245Z-000000T0000Z/
+func ParseVTEC(value string) (*VTEC, error) {
+	m := pvtecPattern.FindStringSubmatch(strings.TrimSpace(value))
+
+	if m == nil {
+		return nil, fmt.Errorf("Invalid P-VTEC string: %q", value)
+	}
+
+	etn, err := strconv.Atoi(m[6])
+
+	if err != nil {
+		return nil, err
+	}
+
+	vtec := VTEC{
+		ProductClass:        m[1],
+		Action:              m[2],
+		Office:              m[3],
+		Phenomena:           m[4],
+		Significance:        m[5],
+		EventTrackingNumber: etn,
+	}
+
+	if vtec.BeginDate, err = parseVTECDate(m[7]); err != nil {
+		return nil, err
+	}
+
+	if vtec.EndDate, err = parseVTECDate(m[8]); err != nil {
+		return nil, err
+	}
+
+	return &vtec, nil
+}
+
+// ParseHVTEC parses a single H-VTEC string such as /PTTA4.1.ER.000000T0000Z.150811T1500Z.000000T0000Z.NO/
+func ParseHVTEC(value string) (*HVTEC, error) {
+	m := hvtecPattern.FindStringSubmatch(strings.TrimSpace(value))
+
+	if m == nil {
+		return nil, fmt.Errorf("Invalid H-VTEC string: %q", value)
+	}
+
+	hvtec := HVTEC{
+		LocationID:     m[1],
+		FloodSeverity:  m[2],
+		ImmediateCause: m[3],
+		FloodRecord:    m[7],
+	}
+
+	var err error
+
+	if hvtec.BeginDate, err = parseVTECDate(m[4]); err != nil {
+		return nil, err
+	}
+
+	if hvtec.CrestDate, err = parseVTECDate(m[5]); err != nil {
+		return nil, err
+	}
+
+	if hvtec.EndDate, err = parseVTECDate(m[6]); err != nil {
+		return nil, err
+	}
+
+	return &hvtec, nil
+}
+
+// ParseVTECParameter parses the value of a VTEC parameter into its P-VTEC strings
+//
+// The NWS places one or more P-VTEC strings in a single parameter value, separated
+// by whitespace. A P-VTEC string may be followed by an H-VTEC string, which is
+// attached to the preceding VTEC as Hydrologic.
+func ParseVTECParameter(value string) ([]VTEC, error) {
+	var found []VTEC
+
+	for _, field := range strings.Fields(value) {
+		if pvtecPattern.MatchString(field) {
+			vtec, err := ParseVTEC(field)
+
+			if err != nil {
+				return nil, err
+			}
+
+			found = append(found, *vtec)
+			continue
+		}
+
+		if len(found) == 0 {
+			return nil, fmt.Errorf("H-VTEC string without a preceding P-VTEC string: %q", field)
+		}
+
+		hvtec, err := ParseHVTEC(field)
+
+		if err != nil {
+			return nil, err
+		}
+
+		found[len(found)-1].Hydrologic = hvtec
+	}
+
+	return found, nil
+}
+
+// VTEC returns back the VTEC strings contained in the VTEC parameter
+func (info *Info) VTEC() ([]VTEC, error) {
+	return ParseVTECParameter(info.Parameter("VTEC"))
+}
+
+// VTEC returns back the VTEC strings contained in the VTEC parameter
+func (ae *NWSAtomEntry) VTEC() ([]VTEC, error) {
+	return ParseVTECParameter(ae.Parameter("VTEC"))
+}
+
+// EventKey returns back a key identifying the event this VTEC belongs to
+//
+// Every product issued for the same event (NEW, CON, EXT, CAN, ...) shares the
+// same office, phenomena, significance and event tracking number, so the key can
+// be used to collapse the updates for an event into a single lifecycle. Event
+// tracking numbers are reset each year, so long-running consumers should expire
+// keys once the event has ended.
+func (v *VTEC) EventKey() string {
+	return fmt.Sprintf("%s.%s.%s.%04d", v.Office, v.Phenomena, v.Significance, v.EventTrackingNumber)
+}
+
+// IsTerminal returns back whether this VTEC ends the lifecycle of its event
+func (v *VTEC) IsTerminal() bool {
+	switch v.Action {
+	case VTECActionCancel, VTECActionExpire, VTECActionUpgrade:
+		return true
+	}
+
+	return false
+}
+
+// String returns back the VTEC in its P-VTEC string form
+func (v *VTEC) String() string {
+	return fmt.Sprintf("/%s.%s.%s.%s.%s.%04d.%s-%s/",
+		v.ProductClass, v.Action, v.Office, v.Phenomena, v.Significance,
+		v.EventTrackingNumber, formatVTECDate(v.BeginDate), formatVTECDate(v.EndDate))
+}
+
+// String returns back the HVTEC in its H-VTEC string form
+func (h *HVTEC) String() string {
+	return fmt.Sprintf("/%s.%s.%s.%s.%s.%s.%s/",
+		h.LocationID, h.FloodSeverity, h.ImmediateCause, formatVTECDate(h.BeginDate),
+		formatVTECDate(h.CrestDate), formatVTECDate(h.EndDate), h.FloodRecord)
+}
+
+// parseVTECDate parses a VTEC date / time group, returning the zero time for 000000T0000Z
+func parseVTECDate(value string) (time.Time, error) {
+	if value == vtecZeroDate {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(VTECDate, value)
+}
+
+// formatVTECDate formats a time as a VTEC date / time group
+func formatVTECDate(t time.Time) string {
+	if t.IsZero() {
+		return vtecZeroDate
+	}
+
+	return t.UTC().Format(VTECDate)
+}
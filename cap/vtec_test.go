@@ -0,0 +1,117 @@
+package cap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseVTECHasProperValues(t *testing.T) {
+	vtec, err := ParseVTEC("/O.NEW.KLZK.FL.W.0061.150816T0245Z-000000T0000Z/")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, vtec.ProductClass, "O", "ProductClass does not match!")
+	assertEqual(t, vtec.Action, VTECActionNew, "Action does not match!")
+	assertEqual(t, vtec.Office, "KLZK", "Office does not match!")
+	assertEqual(t, vtec.Phenomena, "FL", "Phenomena does not match!")
+	assertEqual(t, vtec.Significance, "W", "Significance does not match!")
+	assertEqual(t, vtec.EventTrackingNumber, 61, "EventTrackingNumber does not match!")
+	assertEqual(t,
+		vtec.BeginDate,
+		time.Date(2015, 8, 16, 2, 45, 0, 0, time.UTC),
+		"BeginDate does not match!")
+	assertEqual(t, vtec.EndDate.IsZero(), true, "EndDate should be the zero time")
+}
+
+func TestParseVTECReturnsErrForInvalidString(t *testing.T) {
+	_, err := ParseVTEC("/O.NEW.KLZK.FL.W.61.150816T0245Z-000000T0000Z/")
+
+	assertStartsWith(t, err.Error(), "Invalid P-VTEC string", "Unexpected or missing error message")
+}
+
+func TestVTECStringRoundTrips(t *testing.T) {
+	value := "/O.EXT.KLZK.FL.W.0102.000000T0000Z-150817T0000Z/"
+	vtec, err := ParseVTEC(value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, vtec.String(), value, "String does not match the parsed value")
+}
+
+func TestParseVTECParameterAttachesHVTEC(t *testing.T) {
+	alert, err := getCAPAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := alert.Infos[0].VTEC()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(found), 1, "One P-VTEC should be present")
+	assertEqual(t, found[0].Action, VTECActionContinue, "Action does not match!")
+	assertEqual(t, found[0].EventKey(), "KLZK.FL.W.0108", "EventKey does not match!")
+
+	hvtec := found[0].Hydrologic
+
+	if hvtec == nil {
+		t.Fatal("H-VTEC was not attached to the P-VTEC")
+	}
+
+	assertEqual(t, hvtec.LocationID, "PTTA4", "LocationID does not match!")
+	assertEqual(t, hvtec.FloodSeverity, "1", "FloodSeverity does not match!")
+	assertEqual(t, hvtec.ImmediateCause, "ER", "ImmediateCause does not match!")
+	assertEqual(t,
+		hvtec.CrestDate,
+		time.Date(2015, 8, 11, 15, 0, 0, 0, time.UTC),
+		"CrestDate does not match!")
+	assertEqual(t, hvtec.FloodRecord, "NO", "FloodRecord does not match!")
+	assertEqual(t,
+		hvtec.String(),
+		"/PTTA4.1.ER.000000T0000Z.150811T1500Z.000000T0000Z.NO/",
+		"String does not match the parsed value")
+}
+
+func TestParseVTECParameterReturnsEmptyForEmptyValue(t *testing.T) {
+	found, err := ParseVTECParameter("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(found), 0, "No VTEC should be found")
+}
+
+func TestParseVTECParameterReturnsErrForOrphanHVTEC(t *testing.T) {
+	_, err := ParseVTECParameter("/PTTA4.1.ER.000000T0000Z.150811T1500Z.000000T0000Z.NO/")
+
+	assertStartsWith(t, err.Error(), "H-VTEC string without", "Unexpected or missing error message")
+}
+
+func TestNWSAtomEntryVTECSharesEventKeyAcrossActions(t *testing.T) {
+	feed, err := getNwsAtomFeedExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := feed.Entries[0].VTEC()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel := found[0]
+	cancel.Action = VTECActionCancel
+
+	assertEqual(t, found[0].EventKey(), cancel.EventKey(), "EventKey should not depend on the action")
+	assertEqual(t, found[0].IsTerminal(), false, "CON should not be terminal")
+	assertEqual(t, cancel.IsTerminal(), true, "CAN should be terminal")
+}
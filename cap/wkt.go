@@ -0,0 +1,359 @@
+package cap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// wkbMultiPolygon is the Well-Known Binary geometry type of a MultiPolygon
+const wkbMultiPolygon uint32 = 6
+
+// wkbPolygon is the Well-Known Binary geometry type of a Polygon
+const wkbPolygon uint32 = 3
+
+// WKT returns back the Well-Known Text form of the polygons, such as
+// "MULTIPOLYGON (((-91 35, -91 36, -90 36, -91 35)))", or "MULTIPOLYGON EMPTY"
+// if there are none
+//
+// Positions are written longitude first, as PostGIS expects for EPSG:4326.
+func (m MultiPolygon) WKT() string {
+	if len(m) == 0 {
+		return "MULTIPOLYGON EMPTY"
+	}
+
+	var b strings.Builder
+
+	b.WriteString("MULTIPOLYGON (")
+
+	for i, polygon := range m {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteString("(")
+
+		for j, ring := range polygon {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+
+			b.WriteString("(")
+
+			for k, pos := range ring {
+				if k > 0 {
+					b.WriteString(", ")
+				}
+
+				b.WriteString(strconv.FormatFloat(pos[0], 'f', -1, 64))
+				b.WriteString(" ")
+				b.WriteString(strconv.FormatFloat(pos[1], 'f', -1, 64))
+			}
+
+			b.WriteString(")")
+		}
+
+		b.WriteString(")")
+	}
+
+	b.WriteString(")")
+
+	return b.String()
+}
+
+// WKB returns back the little-endian Well-Known Binary form of the polygons as a MultiPolygon
+func (m MultiPolygon) WKB() []byte {
+	var b bytes.Buffer
+
+	binary.Write(&b, binary.LittleEndian, uint8(1))
+	binary.Write(&b, binary.LittleEndian, wkbMultiPolygon)
+	binary.Write(&b, binary.LittleEndian, uint32(len(m)))
+
+	for _, polygon := range m {
+		binary.Write(&b, binary.LittleEndian, uint8(1))
+		binary.Write(&b, binary.LittleEndian, wkbPolygon)
+		binary.Write(&b, binary.LittleEndian, uint32(len(polygon)))
+
+		for _, ring := range polygon {
+			binary.Write(&b, binary.LittleEndian, uint32(len(ring)))
+
+			for _, pos := range ring {
+				binary.Write(&b, binary.LittleEndian, pos)
+			}
+		}
+	}
+
+	return b.Bytes()
+}
+
+// WKT returns back the Well-Known Text MULTIPOLYGON of the area's geometry
+//
+// The polygons and circles, or the geocode boundaries without them, are
+// combined as in Geometry, so circles become 64-sided polygons.
+func (area *Area) WKT() string {
+	return area.Geometry().WKT()
+}
+
+// WKB returns back the Well-Known Binary MULTIPOLYGON of the area's geometry, see WKT
+func (area *Area) WKB() []byte {
+	return area.Geometry().WKB()
+}
+
+// SetWKT replaces the area's polygons with a Well-Known Text POLYGON or
+// MULTIPOLYGON, one CAP polygon for every polygon
+//
+// A CAP polygon is a single ring, so an error is returned for geometries with
+// holes, as well as for text that cannot be parsed. The area is not changed on
+// error.
+func (area *Area) SetWKT(value string) error {
+	geometry, err := ParseWKT(value)
+
+	if err != nil {
+		return err
+	}
+
+	if len(geometry) == 0 {
+		return fmt.Errorf("WKT has no polygons")
+	}
+
+	polygons := make([]string, len(geometry))
+
+	for i, p := range geometry {
+		if len(p) != 1 {
+			return fmt.Errorf("WKT polygon has holes, which a CAP area cannot hold")
+		}
+
+		polygons[i] = formatPolygon(p[0])
+
+		if err := validatePolygon(polygons[i]); err != nil {
+			return fmt.Errorf("Invalid WKT polygon: %s", err)
+		}
+	}
+
+	area.Polygons = polygons
+	area.Polygon = firstOf(polygons)
+
+	return nil
+}
+
+// ParseWKT parses a Well-Known Text POLYGON or MULTIPOLYGON of longitude,
+// latitude positions
+//
+// Keywords are case-insensitive, an EWKT "SRID=4326;" prefix is accepted, and
+// Z and M values are ignored. Every ring must be closed and have at least four
+// positions.
+func ParseWKT(value string) (MultiPolygon, error) {
+	p := &wktParser{input: value}
+	p.skipSpace()
+
+	if strings.HasPrefix(strings.ToUpper(p.input[p.pos:]), "SRID=") {
+		end := strings.IndexByte(p.input[p.pos:], ';')
+
+		if end < 0 {
+			return nil, p.errorf("expected ';' after the SRID")
+		}
+
+		p.pos += end + 1
+	}
+
+	var geometry MultiPolygon
+
+	switch keyword := p.word(); keyword {
+	case "POLYGON":
+		if p.dimensions(); p.empty() {
+			break
+		}
+
+		polygon, err := p.polygon()
+
+		if err != nil {
+			return nil, err
+		}
+
+		geometry = MultiPolygon{polygon}
+	case "MULTIPOLYGON":
+		if p.dimensions(); p.empty() {
+			break
+		}
+
+		err := p.list(func() error {
+			polygon, err := p.polygon()
+			geometry = append(geometry, polygon)
+
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Invalid WKT: expected POLYGON or MULTIPOLYGON but found %q", keyword)
+	}
+
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+
+	return geometry, nil
+}
+
+// wktParser reads Well-Known Text from input starting at pos
+type wktParser struct {
+	input string
+	pos   int
+}
+
+func (p *wktParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Invalid WKT at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// word returns back the next run of letters, upper cased
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.pos
+
+	for p.pos < len(p.input) && unicode.IsLetter(rune(p.input[p.pos])) {
+		p.pos++
+	}
+
+	return strings.ToUpper(p.input[start:p.pos])
+}
+
+// dimensions skips a Z, M or ZM dimension keyword
+func (p *wktParser) dimensions() {
+	start := p.pos
+
+	if word := p.word(); word != "Z" && word != "M" && word != "ZM" {
+		p.pos = start
+	}
+}
+
+// empty returns back true and consumes the EMPTY keyword if it comes next
+func (p *wktParser) empty() bool {
+	start := p.pos
+
+	if p.word() == "EMPTY" {
+		return true
+	}
+
+	p.pos = start
+
+	return false
+}
+
+// expect consumes the given character
+func (p *wktParser) expect(c byte) error {
+	if p.skipSpace(); p.pos >= len(p.input) || p.input[p.pos] != c {
+		return p.errorf("expected '%c'", c)
+	}
+
+	p.pos++
+
+	return nil
+}
+
+// list parses a parenthesized, comma separated list with item
+func (p *wktParser) list(item func() error) error {
+	if err := p.expect('('); err != nil {
+		return err
+	}
+
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+
+		if p.skipSpace(); p.pos < len(p.input) && p.input[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+
+		return p.expect(')')
+	}
+}
+
+func (p *wktParser) polygon() (Polygon, error) {
+	var polygon Polygon
+
+	err := p.list(func() error {
+		ring, err := p.ring()
+		polygon = append(polygon, ring)
+
+		return err
+	})
+
+	return polygon, err
+}
+
+func (p *wktParser) ring() (Ring, error) {
+	var ring Ring
+
+	err := p.list(func() error {
+		pos, err := p.position()
+		ring = append(ring, pos)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ring) < 4 {
+		return nil, p.errorf("rings must have at least 4 positions")
+	}
+
+	if !ring.Closed() {
+		return nil, p.errorf("rings must end where they start")
+	}
+
+	return ring, nil
+}
+
+// position parses a longitude and latitude, skipping any Z and M values
+func (p *wktParser) position() ([2]float64, error) {
+	var values []float64
+
+	for {
+		p.skipSpace()
+		start := p.pos
+
+		for p.pos < len(p.input) && strings.IndexByte("+-.0123456789eE", p.input[p.pos]) >= 0 {
+			p.pos++
+		}
+
+		if start == p.pos {
+			break
+		}
+
+		text := p.input[start:p.pos]
+		value, err := strconv.ParseFloat(text, 64)
+
+		if err != nil || math.IsInf(value, 0) {
+			p.pos = start
+			return [2]float64{}, p.errorf("invalid number %q", text)
+		}
+
+		values = append(values, value)
+	}
+
+	if len(values) < 2 || len(values) > 4 {
+		return [2]float64{}, p.errorf("positions must have 2 to 4 numbers")
+	}
+
+	if math.Abs(values[0]) > 180 || math.Abs(values[1]) > 90 {
+		return [2]float64{}, p.errorf("position %g %g is out of range", values[0], values[1])
+	}
+
+	return [2]float64{values[0], values[1]}, nil
+}
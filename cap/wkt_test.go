@@ -0,0 +1,101 @@
+package cap
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestAreaWKT(t *testing.T) {
+	area := Area{Polygons: []string{"35,-91 36,-91 36,-90 35,-91"}}
+
+	assertEqual(t, area.WKT(), "MULTIPOLYGON (((-91 35, -91 36, -90 36, -91 35)))", "Polygons should be written longitude first")
+	assertEqual(t, (&Area{}).WKT(), "MULTIPOLYGON EMPTY", "Areas without shapes should be empty")
+
+	area = Area{Polygons: []string{"35,-91 36,-91 36,-90 35,-91"}, Circles: []string{"32.9525,-115.5527 2"}}
+	geometry, err := ParseWKT(area.WKT())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(geometry), 2, "The polygon and circle should both be written")
+	assertEqual(t, len(geometry[1][0]), circleSegments+1, "The circle should be a buffered polygon")
+	assertEqual(t, geometry.Contains(32.96, -115.55), true, "The point should be inside the circle")
+}
+
+func TestAreaWKB(t *testing.T) {
+	wkb := (&Area{Polygons: []string{"35,-91 36,-91 36,-90 35,-91"}}).WKB()
+
+	// Byte order, type and count for the MultiPolygon and the Polygon, then the ring
+	assertEqual(t, len(wkb), 1+4+4+1+4+4+4+4*16, "Unexpected WKB length")
+	assertEqual(t, wkb[0], byte(1), "WKB should be little-endian")
+	assertEqual(t, binary.LittleEndian.Uint32(wkb[1:]), uint32(6), "The geometry should be a MultiPolygon")
+	assertEqual(t, binary.LittleEndian.Uint32(wkb[10:]), uint32(3), "The member should be a Polygon")
+	assertEqual(t, binary.LittleEndian.Uint32(wkb[18:]), uint32(4), "The ring should have 4 positions")
+	assertEqual(t, math.Float64frombits(binary.LittleEndian.Uint64(wkb[22:])), -91.0, "Positions should be longitude first")
+	assertEqual(t, math.Float64frombits(binary.LittleEndian.Uint64(wkb[30:])), 35.0, "Positions should be longitude first")
+}
+
+func TestParseWKT(t *testing.T) {
+	geometry, err := ParseWKT("SRID=4326;multipolygon Z (((-91 35 10, -91 36 10, -90 36 10, -91 35 10)), ((0 0, 0 4, 4 4, 4 0, 0 0), (1 1, 2 1, 2 2, 1 1)))")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(geometry), 2, "Both polygons should be read")
+	assertEqual(t, len(geometry[1]), 2, "The hole should be read")
+	assertEqual(t, geometry[0][0][1], [2]float64{-91, 36}, "Z values should be dropped")
+
+	geometry, err = ParseWKT("POLYGON EMPTY")
+
+	assertEqual(t, err, nil, "Empty polygons should be accepted")
+	assertEqual(t, len(geometry), 0, "Empty polygons have no geometry")
+}
+
+func TestParseWKTErrors(t *testing.T) {
+	cases := map[string]string{
+		"POINT (1 2)":                              `Invalid WKT: expected POLYGON or MULTIPOLYGON but found "POINT"`,
+		"POLYGON ((0 0, 0 1, 1 1, 1 0))":           "Invalid WKT at offset 29: rings must end where they start",
+		"POLYGON ((0 0, 0 1, 0 0))":                "Invalid WKT at offset 24: rings must have at least 4 positions",
+		"POLYGON ((0 0, 0 1, 1 1, 0 0)":            "Invalid WKT at offset 29: expected ')'",
+		"POLYGON ((0 0, 0 1, 1 1, 0 0)) extra":     `Invalid WKT at offset 31: unexpected "extra"`,
+		"POLYGON ((0 0, 0 91, 1 1, 0 0))":          "Invalid WKT at offset 19: position 0 91 is out of range",
+		"POLYGON ((0 0, 0 1 2 3 4, 1 1, 0 0))":     "Invalid WKT at offset 24: positions must have 2 to 4 numbers",
+		"POLYGON ((0 0, 0 1-, 1 1, 0 0))":          `Invalid WKT at offset 17: invalid number "1-"`,
+		"SRID=4326 POLYGON ((0 0, 0 1, 1 1, 0 0))": "Invalid WKT at offset 0: expected ';' after the SRID",
+	}
+
+	for input, expected := range cases {
+		_, err := ParseWKT(input)
+
+		if err == nil {
+			t.Errorf("Expected %q to be rejected", input)
+			continue
+		}
+
+		assertEqual(t, err.Error(), expected, "Unexpected error for "+input)
+	}
+}
+
+func TestAreaSetWKT(t *testing.T) {
+	area := Area{Description: "Test"}
+
+	assertEqual(t, area.SetWKT("POLYGON ((-91 35, -91 36, -90 36, -91 35))"), nil, "The polygon should be set")
+	assertEqual(t, len(area.Polygons), 1, "The polygon should replace the area's polygons")
+	assertEqual(t, area.Polygons[0], "35,-91 36,-91 36,-90 35,-91", "The polygon should be written latitude first")
+
+	err := area.SetWKT("POLYGON ((0 0, 0 4, 4 4, 4 0, 0 0), (1 1, 2 1, 2 2, 1 1))")
+
+	assertEqual(t, err.Error(), "WKT polygon has holes, which a CAP area cannot hold", "Holes should be rejected")
+	assertEqual(t, area.Polygons[0], "35,-91 36,-91 36,-90 35,-91", "The area should be left alone on error")
+
+	err = area.SetWKT("POLYGON EMPTY")
+
+	assertEqual(t, err.Error(), "WKT has no polygons", "Empty geometries should be rejected")
+
+	assertEqual(t, area.SetWKT("MULTIPOLYGON (((0 0, 0 1, 1 1, 0 0)), ((5 5, 5 6, 6 6, 5 5)))"), nil, "Several polygons should be set")
+	assertEqual(t, len(area.Polygons), 2, "Every polygon should be a CAP polygon")
+	assertEqual(t, area.Polygons[1], "5,5 6,5 6,6 5,5", "Unexpected second polygon")
+}
@@ -0,0 +1,443 @@
+// Command cap inspects, validates and converts Common Alerting Protocol messages
+//
+// Usage:
+//
+//	cap validate [-profile name]... file...
+//	cap convert -to 1.1|1.2|json|geojson|kml|wkt [file]
+//	cap show [-format text|markdown|html] [-tz zone] [file]
+//	cap diff old.xml new.xml
+//	cap feed fetch [-json] [url]
+//
+// A file of "-" or no file reads the alert from standard input. CAP 1.1 and
+// 1.2 alerts are accepted everywhere. Validate checks every alert in a file,
+// such as an export or an EDXL-DE envelope, while the other commands read the
+// first. Converting to wkt writes a MULTIPOLYGON line for every area, ready
+// for PostGIS.
+//
+// The exit status is 0 on success, 1 when validate finds an invalid alert or
+// diff finds differences, and 2 for usage, input and network errors, so the
+// tool can gate CI jobs checking hand-authored alerts.
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark-adams/cap-go/cap"
+	_ "github.com/mark-adams/cap-go/ipaws" // registers the IPAWS profile
+)
+
+// Exit statuses
+const (
+	exitOK      int = 0
+	exitFailed  int = 1
+	exitTrouble int = 2
+)
+
+const usage string = `Usage:
+  cap validate [-profile name]... file...
+  cap convert -to 1.1|1.2|json|geojson|kml|wkt [file]
+  cap show [-format text|markdown|html] [-tz zone] [file]
+  cap diff old.xml new.xml
+  cap feed fetch [-json] [url]
+`
+
+// errUsage is returned by commands given bad arguments
+var errUsage = errors.New("invalid usage")
+
+// command runs a subcommand, returning its exit status
+type command func(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+
+var commands = map[string]command{
+	"validate": runValidate,
+	"convert":  runConvert,
+	"show":     runShow,
+	"diff":     runDiff,
+	"feed":     runFeed,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitTrouble
+	}
+
+	cmd, ok := commands[args[0]]
+
+	if !ok {
+		fmt.Fprintf(stderr, "cap: unknown command %q\n%s", args[0], usage)
+		return exitTrouble
+	}
+
+	status, err := cmd(args[1:], stdin, stdout, stderr)
+
+	if err == errUsage || err == flag.ErrHelp {
+		fmt.Fprint(stderr, usage)
+		return exitTrouble
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "cap %s: %s\n", args[0], err)
+		return exitTrouble
+	}
+
+	return status
+}
+
+// newFlagSet returns back a FlagSet leaving error reporting to run
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.Usage = func() {}
+
+	return flags
+}
+
+// listFlag collects the values of a flag given several times
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// openInput returns back the file at path, or standard input for "-"
+func openInput(path string, stdin io.Reader) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(stdin), nil
+	}
+
+	return os.Open(path)
+}
+
+// readAlert decodes the first CAP 1.2 or 1.1 alert in the file at path, or standard input for "-"
+func readAlert(path string, stdin io.Reader) (*cap.Alert, error) {
+	r, err := openInput(path, stdin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	alert, err := cap.NewDecoder(r).Decode()
+
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s: no CAP alert found", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return alert, nil
+}
+
+// readAlerts calls fn with every CAP 1.2 or 1.1 alert in the file at path, or
+// standard input for "-"
+//
+// Alerts are labelled with the path, followed by their index in brackets when
+// the file holds more than one.
+func readAlerts(path string, stdin io.Reader, fn func(label string, alert *cap.Alert)) error {
+	r, err := openInput(path, stdin)
+
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	dec := cap.NewDecoder(r)
+	alert, err := dec.Decode()
+
+	if err == io.EOF {
+		return fmt.Errorf("%s: no CAP alert found", path)
+	}
+
+	for i := 0; err != io.EOF; i++ {
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+
+		// The next alert is read ahead to know whether the file holds several
+		next, nextErr := dec.Decode()
+		label := path
+
+		if i > 0 || nextErr != io.EOF {
+			label = fmt.Sprintf("%s[%d]", path, i)
+		}
+
+		fn(label, alert)
+		alert, err = next, nextErr
+	}
+
+	return nil
+}
+
+// inputPath returns back the single optional file argument, "-" when there is none
+func inputPath(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		return "-", nil
+	case 1:
+		return args[0], nil
+	default:
+		return "", errUsage
+	}
+}
+
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	var names listFlag
+
+	flags := newFlagSet("validate")
+	flags.Var(&names, "profile", "also check the rules of a registered profile, such as CAP-CP, CAP-AU, IPAWS or Geocodes")
+
+	if err := flags.Parse(args); err != nil {
+		return exitTrouble, err
+	}
+
+	var profiles []cap.Profile
+
+	for _, name := range names {
+		profile, ok := cap.LookupProfile(name)
+
+		if !ok {
+			return exitTrouble, fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(cap.Profiles(), ", "))
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	paths := flags.Args()
+
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	status := exitOK
+
+	for _, path := range paths {
+		err := readAlerts(path, stdin, func(label string, alert *cap.Alert) {
+			err := alert.Validate(profiles...)
+
+			if err == nil {
+				fmt.Fprintf(stdout, "%s: valid\n", label)
+				return
+			}
+
+			status = exitFailed
+
+			for _, e := range err.(cap.ValidationErrors) {
+				fmt.Fprintf(stdout, "%s: %s\n", label, e)
+			}
+		})
+
+		if err != nil {
+			return exitTrouble, err
+		}
+	}
+
+	return status, nil
+}
+
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	flags := newFlagSet("convert")
+	to := flags.String("to", "", "output format: 1.1, 1.2, json, geojson, kml or wkt")
+
+	if err := flags.Parse(args); err != nil {
+		return exitTrouble, err
+	}
+
+	path, err := inputPath(flags.Args())
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	alert, err := readAlert(path, stdin)
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	var output []byte
+
+	switch *to {
+	case "1.1":
+		output, err = xml.MarshalIndent(cap.Alert11{Alert: *alert}, "", "  ")
+		output = append([]byte(xml.Header), output...)
+	case "1.2":
+		output, err = xml.MarshalIndent(alert, "", "  ")
+		output = append([]byte(xml.Header), output...)
+	case "json":
+		output, err = json.MarshalIndent(alert, "", "  ")
+	case "geojson":
+		output, err = json.MarshalIndent(alert.GeoJSON(), "", "  ")
+	case "kml":
+		output, err = alert.MarshalKML()
+	case "wkt":
+		var lines []string
+
+		for _, info := range alert.Infos {
+			for _, area := range info.Areas {
+				lines = append(lines, area.WKT())
+			}
+		}
+
+		output = []byte(strings.Join(lines, "\n"))
+	default:
+		return exitTrouble, errUsage
+	}
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	_, err = fmt.Fprintf(stdout, "%s\n", output)
+
+	return exitOK, err
+}
+
+func runShow(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	flags := newFlagSet("show")
+	format := flags.String("format", "text", "output format: text, markdown or html")
+	tz := flags.String("tz", "", "time zone to show dates in, such as America/Chicago")
+
+	if err := flags.Parse(args); err != nil {
+		return exitTrouble, err
+	}
+
+	path, err := inputPath(flags.Args())
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	var renderer *cap.Renderer
+
+	switch *format {
+	case "text":
+		renderer, err = cap.NewTextRenderer("")
+	case "markdown":
+		renderer, err = cap.NewMarkdownRenderer("")
+	case "html":
+		renderer, err = cap.NewHTMLRenderer("")
+	default:
+		return exitTrouble, errUsage
+	}
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	if *tz != "" {
+		if renderer.Location, err = time.LoadLocation(*tz); err != nil {
+			return exitTrouble, err
+		}
+	}
+
+	alert, err := readAlert(path, stdin)
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	return exitOK, renderer.Render(stdout, alert)
+}
+
+func runDiff(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	flags := newFlagSet("diff")
+
+	if err := flags.Parse(args); err != nil {
+		return exitTrouble, err
+	}
+
+	if flags.NArg() != 2 {
+		return exitTrouble, errUsage
+	}
+
+	old, err := readAlert(flags.Arg(0), stdin)
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	new, err := readAlert(flags.Arg(1), stdin)
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	changes := cap.Diff(old, new)
+
+	if len(changes) == 0 {
+		return exitOK, nil
+	}
+
+	fmt.Fprintln(stdout, changes)
+
+	return exitFailed, nil
+}
+
+func runFeed(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	if len(args) == 0 || args[0] != "fetch" {
+		return exitTrouble, errUsage
+	}
+
+	flags := newFlagSet("feed fetch")
+	asJSON := flags.Bool("json", false, "print the entries as JSON")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitTrouble, err
+	}
+
+	url := cap.NwsNationalAtomFeedURL
+
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		url = flags.Arg(0)
+	default:
+		return exitTrouble, errUsage
+	}
+
+	feed, err := cap.GetAtomFeed(url)
+
+	if err != nil {
+		return exitTrouble, err
+	}
+
+	if *asJSON {
+		output, err := json.MarshalIndent(feed.Entries, "", "  ")
+
+		if err != nil {
+			return exitTrouble, err
+		}
+
+		fmt.Fprintf(stdout, "%s\n", output)
+
+		return exitOK, nil
+	}
+
+	for _, entry := range feed.Entries {
+		fmt.Fprintf(stdout, "%s\t%s\t%s\t%s\n", entry.UpdatedDate, entry.Severity, entry.EventType, entry.AreaDescription)
+	}
+
+	return exitOK, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return status, stdout.String(), stderr.String()
+}
+
+func TestValidateExitStatus(t *testing.T) {
+	status, stdout, _ := runCommand(t, "", "validate", "-profile", "IPAWS", "../../examples/ipaws_alert.xml")
+
+	if status != exitOK {
+		t.Errorf("Expected status %d but got %d: %s", exitOK, status, stdout)
+	}
+
+	status, stdout, _ = runCommand(t, "", "validate", "-profile", "CAP-CP", "../../examples/ipaws_alert.xml")
+
+	if status != exitFailed || !strings.Contains(stdout, "code: must include profile:CAP-CP:0.4") {
+		t.Errorf("Expected CAP-CP errors with status %d but got %d: %s", exitFailed, status, stdout)
+	}
+
+	status, _, stderr := runCommand(t, "", "validate", "-profile", "Nope", "../../examples/ipaws_alert.xml")
+
+	if status != exitTrouble || !strings.Contains(stderr, `unknown profile "Nope"`) {
+		t.Errorf("Expected an unknown profile error but got %d: %s", status, stderr)
+	}
+}
+
+func TestValidateReadsStandardInput(t *testing.T) {
+	status, _, stderr := runCommand(t, "<alert", "validate")
+
+	if status != exitTrouble || !strings.Contains(stderr, "cap validate: -:") {
+		t.Errorf("Expected a parse error but got %d: %s", status, stderr)
+	}
+}
+
+func TestValidateChecksEveryAlert(t *testing.T) {
+	xmlData, err := ioutil.ReadFile("../../examples/ipaws_alert.xml")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alert := strings.TrimPrefix(string(xmlData), xml.Header)
+	invalid := strings.Replace(alert, "<status>Actual</status>", "<status>Real</status>", 1)
+	status, stdout, stderr := runCommand(t, "<alerts>"+alert+invalid+"</alerts>", "validate")
+
+	if status != exitFailed {
+		t.Fatalf("Expected status %d but got %d: %s%s", exitFailed, status, stdout, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "-[0]: valid\n-[1]: status: ") {
+		t.Errorf("Expected every alert to be validated but got:\n%s", stdout)
+	}
+}
+
+func TestConvertBetweenVersions(t *testing.T) {
+	status, stdout, stderr := runCommand(t, "", "convert", "-to", "1.2", "../../examples/nws_alert.xml")
+
+	if status != exitOK {
+		t.Fatalf("Expected status %d but got %d: %s", exitOK, status, stderr)
+	}
+
+	if !strings.Contains(stdout, `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">`) {
+		t.Errorf("Expected a CAP 1.2 alert but got:\n%s", stdout)
+	}
+
+	status, stdout, _ = runCommand(t, stdout, "convert", "-to", "1.1")
+
+	if status != exitOK || !strings.Contains(stdout, `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.1">`) {
+		t.Errorf("Expected a CAP 1.1 alert but got %d:\n%s", status, stdout)
+	}
+
+	for _, format := range []string{"json", "geojson", "kml", "wkt"} {
+		if status, _, stderr := runCommand(t, "", "convert", "-to", format, "../../examples/ipaws_alert.xml"); status != exitOK {
+			t.Errorf("Converting to %s failed with %d: %s", format, status, stderr)
+		}
+	}
+
+	if status, _, _ := runCommand(t, "", "convert", "-to", "yaml", "../../examples/ipaws_alert.xml"); status != exitTrouble {
+		t.Errorf("Expected unknown formats to fail but got %d", status)
+	}
+}
+
+func TestShowRendersSummary(t *testing.T) {
+	status, stdout, stderr := runCommand(t, "", "show", "-format", "markdown", "../../examples/ipaws_alert.xml")
+
+	if status != exitOK {
+		t.Fatalf("Expected status %d but got %d: %s", exitOK, status, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "## **SEVERE** Flash Flood Warning issued") {
+		t.Errorf("Unexpected summary:\n%s", stdout)
+	}
+}
+
+func TestDiffExitStatus(t *testing.T) {
+	xmlData, err := ioutil.ReadFile("../../examples/ipaws_alert.xml")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated := filepath.Join(t.TempDir(), "updated.xml")
+	err = ioutil.WriteFile(updated, bytes.Replace(xmlData, []byte("<severity>Severe"), []byte("<severity>Extreme"), 1), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _, _ := runCommand(t, "", "diff", "../../examples/ipaws_alert.xml", "../../examples/ipaws_alert.xml"); status != exitOK {
+		t.Errorf("Expected identical alerts to exit with %d but got %d", exitOK, status)
+	}
+
+	status, stdout, _ := runCommand(t, "", "diff", "../../examples/ipaws_alert.xml", updated)
+
+	if status != exitFailed || stdout != "~ info[0].severity: \"Severe\" -> \"Extreme\"\n" {
+		t.Errorf("Expected a severity change with status %d but got %d: %s", exitFailed, status, stdout)
+	}
+}
+
+func TestFeedFetch(t *testing.T) {
+	feed, err := ioutil.ReadFile("../../examples/nws_atom.xml")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	status, stdout, stderr := runCommand(t, "", "feed", "fetch", server.URL)
+
+	if status != exitOK {
+		t.Fatalf("Expected status %d but got %d: %s", exitOK, status, stderr)
+	}
+
+	if lines := strings.Count(stdout, "\n"); lines == 0 {
+		t.Errorf("Expected one line per entry but got:\n%s", stdout)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{nil, {"frobnicate"}, {"feed"}, {"diff", "a.xml"}, {"validate", "-bogus"}} {
+		if status, _, stderr := runCommand(t, "", args...); status != exitTrouble || stderr == "" {
+			t.Errorf("Expected %v to fail with %d but got %d", args, exitTrouble, status)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Package ipaws implements the CAP v1.2 USA IPAWS Profile used by FEMA's
+// Integrated Public Alert and Warning System
+//
+// Alerts submitted to IPAWS-OPEN must follow the core CAP 1.2 rules as well as
+// the profile rules checked by Validate. Prepare fills in the profile-specific
+// elements and parameters of an alert before it is signed and submitted.
+package ipaws
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mark-adams/cap-go/cap"
+)
+
+// ProfileCode is the code element value identifying the IPAWS profile
+const ProfileCode string = "IPAWSv1.0"
+
+// Parameter names defined by the IPAWS profile
+const (
+	EASOrgParameter       string = "EAS-ORG"
+	BlockChannelParameter string = "BLOCKCHANNEL"
+	CMAMTextParameter     string = "CMAMtext"
+	CMAMLongTextParameter string = "CMAMlongtext"
+	SAMEValueName         string = "SAME"
+)
+
+// Length limits of the WEA (CMAS) text parameters, as counted by WEALength
+const (
+	CMAMTextLimit     int = 90
+	CMAMLongTextLimit int = 360
+)
+
+// EASOrgValues are the originator codes allowed in the EAS-ORG parameter
+var EASOrgValues = []string{"PEP", "CIV", "WXR", "EAS"}
+
+// BlockChannelValues are the dissemination channels that can be blocked with BLOCKCHANNEL
+var BlockChannelValues = []string{"CMAS", "EAS", "NWEM", "PUBLIC"}
+
+// LanguageValues are the info languages accepted by IPAWS
+var LanguageValues = []string{"en-US", "es-US"}
+
+var (
+	sameEventCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	sameGeocodePattern   = regexp.MustCompile(`^\d{6}$`)
+)
+
+// Profile checks the IPAWS profile rules, and is registered with the cap
+// package as "IPAWS"
+var Profile cap.Profile = profile{}
+
+func init() {
+	cap.RegisterProfile(Profile)
+}
+
+type profile struct{}
+
+func (profile) Name() string {
+	return "IPAWS"
+}
+
+func (profile) Validate(alert *cap.Alert, errs *cap.ValidationErrors) {
+	validateProfile(alert, errs)
+}
+
+// Validate checks the alert against the core CAP 1.2 rules and the IPAWS profile rules
+//
+// The returned error is a cap.ValidationErrors listing every problem found, or
+// nil if the alert can be submitted. Signatures cannot be checked on a parsed
+// alert; use ValidateXML to also require one.
+func Validate(alert *cap.Alert) error {
+	return alert.Validate(Profile)
+}
+
+// ValidateXML parses a CAP 1.2 alert and checks it with Validate, additionally
+// requiring the enveloped XML digital signature IPAWS-OPEN expects
+func ValidateXML(xmlData []byte) error {
+	alert, err := cap.ParseAlert(xmlData)
+
+	if err != nil {
+		return err
+	}
+
+	var errs cap.ValidationErrors
+
+	if err := Validate(alert); err != nil {
+		errs = append(errs, err.(cap.ValidationErrors)...)
+	}
+
+	if !hasSignature(alert) {
+		errs.Add("Signature", "an enveloped XML digital signature is required")
+	}
+
+	return errs.Err()
+}
+
+func validateProfile(alert *cap.Alert, errs *cap.ValidationErrors) {
+	if !alert.HasHandlingCode(ProfileCode) {
+		errs.Add("code", "must include %s", ProfileCode)
+	}
+
+	if len(alert.Infos) == 0 && (alert.MessageType == "Alert" || alert.MessageType == "Update") {
+		errs.Add("info", "is required for %s messages", alert.MessageType)
+	}
+
+	for i := range alert.Infos {
+		validateInfo(&alert.Infos[i], fmt.Sprintf("info[%d]", i), errs)
+	}
+}
+
+func validateInfo(info *cap.Info, path string, errs *cap.ValidationErrors) {
+	if info.Language != "" && !contains(LanguageValues, info.Language) {
+		errs.Add(path+".language", "%q is not one of %s", info.Language, strings.Join(LanguageValues, ", "))
+	}
+
+	same := eventCodes(info, SAMEValueName)
+
+	switch {
+	case len(same) == 0:
+		errs.Add(path+".eventCode", "a SAME event code is required")
+	case len(same) > 1:
+		errs.Add(path+".eventCode", "only one SAME event code is allowed")
+	case !sameEventCodePattern.MatchString(same[0]):
+		errs.Add(path+".eventCode", "SAME event code %q must be three upper case letters", same[0])
+	}
+
+	if info.ExpiresDate == "" {
+		errs.Add(path+".expires", "is required")
+	}
+
+	easOrg := info.Parameter(EASOrgParameter)
+
+	if easOrg == "" {
+		errs.Add(path+".parameter", "%s is required", EASOrgParameter)
+	} else if !contains(EASOrgValues, easOrg) {
+		errs.Add(path+".parameter", "%s %q is not one of %s", EASOrgParameter, easOrg, strings.Join(EASOrgValues, ", "))
+	}
+
+	for _, channel := range parameterValues(info, BlockChannelParameter) {
+		if !contains(BlockChannelValues, channel) {
+			errs.Add(path+".parameter", "%s %q is not one of %s", BlockChannelParameter, channel, strings.Join(BlockChannelValues, ", "))
+		}
+	}
+
+	checkLength(info, CMAMTextParameter, CMAMTextLimit, path, errs)
+	checkLength(info, CMAMLongTextParameter, CMAMLongTextLimit, path, errs)
+
+	if len(info.Areas) == 0 {
+		errs.Add(path+".area", "is required")
+	}
+
+	for i := range info.Areas {
+		areaPath := fmt.Sprintf("%s.area[%d]", path, i)
+		codes := info.Areas[i].GeocodeAll(SAMEValueName)
+
+		if len(codes) == 0 {
+			errs.Add(areaPath+".geocode", "a SAME geocode is required")
+		}
+
+		for _, code := range codes {
+			if !sameGeocodePattern.MatchString(code) {
+				errs.Add(areaPath+".geocode", "SAME geocode %q must be six digits", code)
+			}
+		}
+	}
+}
+
+func checkLength(info *cap.Info, name string, limit int, path string, errs *cap.ValidationErrors) {
+	for _, value := range parameterValues(info, name) {
+		if _, n := WEALength(value); n > limit {
+			errs.Add(path+".parameter", "%s is %d characters, the limit is %d", name, n, limit)
+		}
+	}
+}
+
+// hasSignature reports whether the alert has an enveloped XML digital signature child
+func hasSignature(alert *cap.Alert) bool {
+	for _, extra := range alert.Extra {
+		if extra.Name() == (xml.Name{Space: cap.XMLDSigNamespace, Local: "Signature"}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Options are the profile-specific values used by Prepare
+type Options struct {
+	// SAMEEventCode is the three letter SAME event code, such as TOR or FFW
+	SAMEEventCode string
+
+	// EASOrg is the EAS originator code, one of EASOrgValues
+	EASOrg string
+
+	// BlockChannels lists the dissemination channels the alert must not be sent to
+	BlockChannels []string
+
+	// CMAMText is the 90 character WEA text, generated from the Info when empty
+	CMAMText string
+
+	// CMAMLongText is the optional 360 character WEA text
+	CMAMLongText string
+}
+
+// Prepare adds the IPAWS profile code to the alert's handling codes and sets
+// the profile-specific event codes and parameters of every Info block
+//
+// Other handling codes are kept, while existing event codes and parameters are
+// replaced by the options that are set. When Options.CMAMText is empty, the text is generated with
+// CMAMText. The alert is validated afterwards and the result of Validate is
+// returned.
+func Prepare(alert *cap.Alert, opts Options) error {
+	if !alert.HasHandlingCode(ProfileCode) {
+		alert.HandlingCodes = append(alert.HandlingCodes, ProfileCode)
+	}
+
+	for i := range alert.Infos {
+		info := &alert.Infos[i]
+
+		if opts.SAMEEventCode != "" {
+			info.EventCode = setNamedValue(info.EventCode, SAMEValueName, opts.SAMEEventCode)
+		}
+
+		if opts.EASOrg != "" {
+			info.Parameters = setNamedValue(info.Parameters, EASOrgParameter, opts.EASOrg)
+		}
+
+		if len(opts.BlockChannels) > 0 {
+			info.Parameters = removeNamedValue(info.Parameters, BlockChannelParameter)
+
+			for _, channel := range opts.BlockChannels {
+				info.AddParameter(BlockChannelParameter, channel)
+			}
+		}
+
+		text := opts.CMAMText
+
+		if text == "" {
+			text = CMAMText(info)
+		}
+
+		info.Parameters = setNamedValue(info.Parameters, CMAMTextParameter, text)
+
+		if opts.CMAMLongText != "" {
+			info.Parameters = setNamedValue(info.Parameters, CMAMLongTextParameter, opts.CMAMLongText)
+		}
+	}
+
+	return Validate(alert)
+}
+
+// Truncate shortens text to at most limit characters, breaking at a word where possible
+func Truncate(text string, limit int) string {
+	runes := []rune(text)
+
+	if len(runes) <= limit {
+		return text
+	}
+
+	cut := string(runes[:limit])
+
+	if i := strings.LastIndex(cut, " "); i > 0 && runes[limit] != ' ' {
+		cut = cut[:i]
+	}
+
+	return strings.TrimSpace(cut)
+}
+
+func eventCodes(info *cap.Info, name string) []string {
+	var found []string
+
+	for _, code := range info.EventCode {
+		if code.ValueName == name {
+			found = append(found, code.Value)
+		}
+	}
+
+	return found
+}
+
+func parameterValues(info *cap.Info, name string) []string {
+	var found []string
+
+	for _, param := range info.Parameters {
+		if param.ValueName == name {
+			found = append(found, param.Value)
+		}
+	}
+
+	return found
+}
+
+func setNamedValue(values []cap.NamedValue, name, value string) []cap.NamedValue {
+	values = removeNamedValue(values, name)
+	return append(values, cap.NamedValue{ValueName: name, Value: value})
+}
+
+func removeNamedValue(values []cap.NamedValue, name string) []cap.NamedValue {
+	kept := values[:0:0]
+
+	for _, v := range values {
+		if v.ValueName != name {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,193 @@
+package ipaws
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/mark-adams/cap-go/cap"
+)
+
+func getIPAWSAlertExample() ([]byte, *cap.Alert, error) {
+	xmlData, err := ioutil.ReadFile("../examples/ipaws_alert.xml")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alert, err := cap.ParseAlert(xmlData)
+
+	return xmlData, alert, err
+}
+
+func assertHasError(t *testing.T, err error, path, contains string) {
+	errs, ok := err.(cap.ValidationErrors)
+
+	if !ok {
+		t.Errorf("Expected cap.ValidationErrors but got %v", err)
+		return
+	}
+
+	for _, e := range errs {
+		if e.Path == path && strings.Contains(e.Message, contains) {
+			return
+		}
+	}
+
+	t.Errorf("Expected an error for %s containing %q but got %v", path, contains, err)
+}
+
+func TestValidateXMLAcceptsExample(t *testing.T) {
+	xmlData, _, err := getIPAWSAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateXML(xmlData); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateXMLRequiresSignature(t *testing.T) {
+	xmlData, _, err := getIPAWSAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := strings.Index(string(xmlData), "<Signature")
+	end := strings.Index(string(xmlData), "</Signature>") + len("</Signature>")
+	unsigned := string(xmlData[:start]) + string(xmlData[end:])
+
+	assertHasError(t, ValidateXML([]byte(unsigned)), "Signature", "signature is required")
+}
+
+func TestValidateReportsProfileRules(t *testing.T) {
+	_, alert, err := getIPAWSAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &alert.Infos[0]
+	alert.HandlingCodes, alert.HandlingCode = nil, ""
+	info.Language = "fr-CA"
+	info.EventCode[0].Value = "ffw"
+	info.ExpiresDate = ""
+	info.Parameters[0].Value = "XYZ"
+	info.AddParameter(BlockChannelParameter, "RADIO")
+	info.AddParameter(CMAMTextParameter, strings.Repeat("x", 91))
+	info.Areas[0].Geocodes[0].Value = "5067"
+
+	err = Validate(alert)
+
+	assertHasError(t, err, "code", ProfileCode)
+	assertHasError(t, err, "info[0].language", "fr-CA")
+	assertHasError(t, err, "info[0].eventCode", "three upper case letters")
+	assertHasError(t, err, "info[0].expires", "is required")
+	assertHasError(t, err, "info[0].parameter", "EAS-ORG \"XYZ\"")
+	assertHasError(t, err, "info[0].parameter", "BLOCKCHANNEL \"RADIO\"")
+	assertHasError(t, err, "info[0].parameter", "CMAMtext is 91 characters")
+	assertHasError(t, err, "info[0].area[0].geocode", "six digits")
+}
+
+func TestValidateIncludesCoreRules(t *testing.T) {
+	_, alert, err := getIPAWSAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alert.Scope = "Everyone"
+
+	assertHasError(t, Validate(alert), "scope", "is not one of")
+}
+
+func TestPrepareAddsProfileParameters(t *testing.T) {
+	alert := cap.Alert{
+		MessageID:     "1",
+		SenderID:      "sender@example.com",
+		SentDate:      "2015-08-15T20:45:00-05:00",
+		MessageStatus: "Actual",
+		MessageType:   "Alert",
+		Scope:         "Public",
+		Infos: []cap.Info{{
+			EventCategory: "Met",
+			EventType:     "Tornado Warning",
+			Urgency:       "Immediate",
+			Severity:      "Extreme",
+			Certainty:     "Observed",
+			ExpiresDate:   "2015-08-15T21:45:00-05:00",
+			Headline:      "Tornado Warning issued August 15 at 8:45PM CDT until August 15 at 9:45PM CDT by NWS Little Rock",
+			Areas:         []cap.Area{{Description: "Jackson", Geocodes: []cap.NamedValue{{ValueName: "SAME", Value: "005067"}}}},
+		}},
+	}
+
+	err := Prepare(&alert, Options{SAMEEventCode: "TOR", EASOrg: "WXR", BlockChannels: []string{"NWEM"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := alert.Infos[0]
+
+	assertEqual(t, len(alert.HandlingCodes) == 1 && alert.HandlingCodes[0] == ProfileCode, true, "HandlingCode does not match!")
+	assertEqual(t, info.EventCode[0].Value, "TOR", "SAME event code does not match!")
+	assertEqual(t, info.Parameter(EASOrgParameter), "WXR", "EAS-ORG does not match!")
+	assertEqual(t, info.Parameter(BlockChannelParameter), "NWEM", "BLOCKCHANNEL does not match!")
+	assertEqual(t,
+		info.Parameter(CMAMTextParameter),
+		"TORNADO WARNING in this area until 9:45 PM.",
+		"CMAMtext does not match!")
+}
+
+func TestPrepareKeepsOtherHandlingCodes(t *testing.T) {
+	_, alert, err := getIPAWSAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alert.HandlingCodes = []string{"layer:SOREM:1.0"}
+
+	Prepare(alert, Options{})
+	Prepare(alert, Options{})
+
+	assertEqual(t, strings.Join(alert.HandlingCodes, " "), "layer:SOREM:1.0 "+ProfileCode, "The profile code should be added once after the other codes")
+}
+
+func TestPrepareKeepsBlockChannelsWhenNoneAreGiven(t *testing.T) {
+	_, alert, err := getIPAWSAlertExample()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &alert.Infos[0]
+	info.Parameters = removeNamedValue(info.Parameters, BlockChannelParameter)
+	info.AddParameter(BlockChannelParameter, "CMAS")
+	info.AddParameter(BlockChannelParameter, "NWEM")
+
+	Prepare(alert, Options{SAMEEventCode: "TOR"})
+
+	assertEqual(t, strings.Join(parameterValues(info, BlockChannelParameter), " "), "CMAS NWEM", "Existing BLOCKCHANNEL parameters should be kept")
+
+	Prepare(alert, Options{BlockChannels: []string{"EAS"}})
+
+	assertEqual(t, strings.Join(parameterValues(info, BlockChannelParameter), " "), "EAS", "BLOCKCHANNEL parameters should be replaced when channels are given")
+}
+
+func TestTruncateBreaksAtWords(t *testing.T) {
+	assertEqual(t, Truncate("short", 10), "short", "Short text should not change")
+	assertEqual(t, Truncate("one two three", 9), "one two", "Text should break at a word")
+	assertEqual(t, Truncate("one two three", 7), "one two", "Text ending at a word should keep it")
+	assertEqual(t, Truncate("abcdefghij", 4), "abcd", "Long words should be cut")
+}
+
+func TestProfileIsRegistered(t *testing.T) {
+	profile, ok := cap.LookupProfile("IPAWS")
+
+	assertEqual(t, ok, true, "IPAWS profile should be registered")
+	assertEqual(t, profile, Profile, "Wrong profile returned")
+}
@@ -0,0 +1,27 @@
+package ipaws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kr/pretty"
+)
+
+func assertEqual(t *testing.T, expected, actual interface{}, message string) {
+	if expected != actual {
+		t.Error(message)
+		for _, desc := range pretty.Diff(expected, actual) {
+			t.Error(desc)
+		}
+	}
+}
+
+func assertStartsWith(t *testing.T, strVal, prefix string, message string) {
+
+	if strings.Index(strVal, prefix) == -1 {
+		t.Error(message)
+		for _, desc := range pretty.Diff(prefix, strVal[:len(prefix)]) {
+			t.Error(desc)
+		}
+	}
+}
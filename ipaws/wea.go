@@ -0,0 +1,301 @@
+package ipaws
+
+import (
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/mark-adams/cap-go/cap"
+	"golang.org/x/text/language"
+)
+
+// WEAEncoding is the character set a WEA message is broadcast in
+type WEAEncoding int
+
+// The character sets used for cell broadcast
+const (
+	// GSM7 is the GSM 03.38 7-bit default alphabet
+	GSM7 WEAEncoding = iota
+	// UCS2 is used when the text contains characters outside the GSM 7-bit alphabet
+	UCS2
+)
+
+func (e WEAEncoding) String() string {
+	if e == UCS2 {
+		return "UCS-2"
+	}
+
+	return "GSM 7-bit"
+}
+
+// gsm7Basic is the GSM 03.38 default alphabet
+const gsm7Basic string = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension is the GSM 03.38 extension table, whose characters take two septets
+const gsm7Extension string = "\f^{}\\[~]|€"
+
+// weaTransliterations replaces common characters that force UCS-2 with GSM 7-bit equivalents
+var weaTransliterations = strings.NewReplacer(
+	"‘", "'", "’", "'", "“", "\"", "”", "\"", "–", "-", "—", "-", "…", "...",
+	"á", "a", "í", "i", "ó", "o", "ú", "u", "Á", "A", "Í", "I", "Ó", "O", "Ú", "U",
+	"â", "a", "ê", "e", "î", "i", "ô", "o", "û", "u", "ç", "c", "ë", "e", "ï", "i", " ", " ",
+)
+
+// WEAMessage is the text of a Wireless Emergency Alert
+type WEAMessage struct {
+	Text     string
+	Encoding WEAEncoding
+
+	// Length is the size of Text counted against the WEA limit: UTF-16 code
+	// units for UCS-2, where characters outside the Basic Multilingual Plane
+	// count twice, and septets for GSM 7-bit, where extension characters count
+	// twice
+	Length int
+
+	// FromParameter reports whether Text was taken from a CMAMtext or CMAMlongtext parameter
+	FromParameter bool
+}
+
+// WEAOptions control how GenerateWEA builds a message
+type WEAOptions struct {
+	// Limit is the maximum Length of the message, CMAMTextLimit when zero
+	Limit int
+
+	// Source names the alert originator at the start of the message, the
+	// Info's senderName when empty
+	Source string
+
+	// Location is the time zone the expiry time is shown in, the offset of the
+	// expires date when nil
+	Location *time.Location
+
+	// Transliterate replaces characters outside the GSM 7-bit alphabet with
+	// close equivalents where possible, so the message is not sent as UCS-2.
+	// The "área" of the Spanish template is not in the GSM 7-bit alphabet, so
+	// Spanish messages are UCS-2 unless this is set.
+	Transliterate bool
+}
+
+// weaTemplate holds the wording of the FEMA WEA template in one language
+type weaTemplate struct {
+	inArea  string
+	forArea string
+	until   string
+}
+
+var (
+	weaEnglish = weaTemplate{inArea: "in this area", forArea: "for", until: "until"}
+	weaSpanish = weaTemplate{inArea: "en esta área", forArea: "para", until: "hasta las"}
+)
+
+// GenerateWEA builds Wireless Emergency Alert text for an Info block
+//
+// When the Info has a CMAMtext parameter (or a CMAMlongtext parameter and the
+// limit is above CMAMTextLimit) that fits, it is used as is. Otherwise the text
+// follows the FEMA template "SOURCE: EVENT in this area until TIME. INSTRUCTION",
+// naming the areas instead of "this area" in messages longer than
+// CMAMTextLimit. Info blocks in Spanish use the Spanish template, whose "área"
+// makes the message UCS-2 unless WEAOptions.Transliterate is set. Parts of the
+// instruction are dropped, and the text is finally shortened at a word, until
+// the message fits the limit.
+func GenerateWEA(info *cap.Info, opts WEAOptions) WEAMessage {
+	limit := opts.Limit
+
+	if limit <= 0 {
+		limit = CMAMTextLimit
+	}
+
+	params := []string{info.Parameter(CMAMTextParameter)}
+
+	if limit > CMAMTextLimit {
+		params = []string{info.Parameter(CMAMLongTextParameter), params[0]}
+	}
+
+	for _, text := range params {
+		if text == "" {
+			continue
+		}
+
+		if msg := newWEAMessage(text, opts.Transliterate); msg.Length <= limit {
+			msg.FromParameter = true
+			return msg
+		}
+	}
+
+	template := weaEnglish
+	base, _ := info.LanguageTag().Base()
+
+	if spanish, _ := language.Spanish.Base(); base == spanish {
+		template = weaSpanish
+	}
+
+	source := opts.Source
+
+	if source == "" {
+		source = info.SenderName
+	}
+
+	event := info.EventType
+
+	if event == "" {
+		event = info.Headline
+	}
+
+	lead := strings.ToUpper(collapseSpace(event))
+
+	if source != "" {
+		lead = collapseSpace(source) + ": " + lead
+	}
+
+	where := template.inArea
+
+	if limit > CMAMTextLimit {
+		if areas := areaDescriptions(info); areas != "" {
+			where = template.forArea + " " + areas
+		}
+	}
+
+	lead += " " + where
+
+	if expires := weaExpiry(info.ExpiresDate, opts.Location); expires != "" {
+		lead += " " + template.until + " " + expires
+	}
+
+	lead += "."
+
+	// Try the whole instruction first, then fewer and fewer of its sentences.
+	// Longer messages also carry the headline while there is room for it.
+	var candidates []string
+
+	sentences := splitSentences(info.Instruction)
+
+	if headline := splitSentences(info.Headline); limit > CMAMTextLimit && len(headline) > 0 && info.Headline != event {
+		candidates = append(candidates, lead+" "+strings.Join(append(headline, sentences...), " "))
+	}
+
+	for n := len(sentences); n >= 0; n-- {
+		candidates = append(candidates, lead+" "+strings.Join(sentences[:n], " "))
+	}
+
+	for _, text := range candidates {
+		if msg := newWEAMessage(strings.TrimSpace(text), opts.Transliterate); msg.Length <= limit {
+			return msg
+		}
+	}
+
+	return fitWEAMessage(lead, limit, opts.Transliterate)
+}
+
+// CMAMText returns back the CMAMtext parameter of the Info, or the 90
+// character WEA text generated for it
+func CMAMText(info *cap.Info) string {
+	return GenerateWEA(info, WEAOptions{Limit: CMAMTextLimit}).Text
+}
+
+// WEALength returns back the encoding of text and its length counted against the WEA limits
+func WEALength(text string) (WEAEncoding, int) {
+	septets := 0
+
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r):
+			septets++
+		case strings.ContainsRune(gsm7Extension, r):
+			septets += 2
+		default:
+			return UCS2, ucs2Length(text)
+		}
+	}
+
+	return GSM7, septets
+}
+
+// ucs2Length returns back the number of UTF-16 code units of text
+func ucs2Length(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
+
+func newWEAMessage(text string, transliterate bool) WEAMessage {
+	if transliterate {
+		text = weaTransliterations.Replace(text)
+	}
+
+	encoding, length := WEALength(text)
+
+	return WEAMessage{Text: text, Encoding: encoding, Length: length}
+}
+
+// fitWEAMessage shortens text at a word until its message fits the limit
+func fitWEAMessage(text string, limit int, transliterate bool) WEAMessage {
+	msg := newWEAMessage(text, transliterate)
+
+	for cut := utf8.RuneCountInString(msg.Text); msg.Length > limit && cut > 0; {
+		cut--
+		msg = newWEAMessage(Truncate(msg.Text, cut), false)
+	}
+
+	return msg
+}
+
+func weaExpiry(value string, loc *time.Location) string {
+	if value == "" {
+		return ""
+	}
+
+	expires, err := cap.ParseCAPDate(value)
+
+	if err != nil {
+		return ""
+	}
+
+	if loc == nil {
+		return expires.Format("3:04 PM")
+	}
+
+	return expires.In(loc).Format("3:04 PM MST")
+}
+
+func areaDescriptions(info *cap.Info) string {
+	var names []string
+
+	for _, area := range info.Areas {
+		if d := collapseSpace(area.Description); d != "" {
+			names = append(names, d)
+		}
+	}
+
+	return strings.Join(names, "; ")
+}
+
+// splitSentences splits text into sentences ending in ".", "!" or "?"
+func splitSentences(text string) []string {
+	var (
+		sentences []string
+		current   strings.Builder
+	)
+
+	for _, word := range strings.Fields(text) {
+		if current.Len() > 0 {
+			current.WriteByte(' ')
+		}
+
+		current.WriteString(word)
+
+		if strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "?") {
+			sentences = append(sentences, current.String())
+			current.Reset()
+		}
+	}
+
+	if current.Len() > 0 {
+		sentences = append(sentences, current.String()+".")
+	}
+
+	return sentences
+}
+
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,104 @@
+package ipaws
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark-adams/cap-go/cap"
+)
+
+func getWEAInfo() *cap.Info {
+	return &cap.Info{
+		Language:    "en-US",
+		EventType:   "Flash Flood Warning",
+		SenderName:  "NWS",
+		ExpiresDate: "2015-08-16T02:45:00-05:00",
+		Headline:    "Flash Flood Warning issued August 15 at 8:45PM CDT by NWS Little Rock",
+		Instruction: "Move to higher ground now. Turn around, don't drown.",
+		Areas:       []cap.Area{{Description: "Jackson; Woodruff"}},
+	}
+}
+
+func TestGenerateWEAFollowsTemplate(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+
+	if err != nil {
+		t.Skip(err)
+	}
+
+	msg := GenerateWEA(getWEAInfo(), WEAOptions{Location: chicago})
+
+	assertEqual(t, msg.Text, "NWS: FLASH FLOOD WARNING in this area until 2:45 AM CDT. Move to higher ground now.", "Text does not match!")
+	assertEqual(t, msg.Encoding, GSM7, "Encoding does not match!")
+	assertEqual(t, msg.Length, 83, "Length does not match!")
+	assertEqual(t, msg.FromParameter, false, "Text should be generated")
+}
+
+func TestGenerateWEALongTextNamesAreas(t *testing.T) {
+	msg := GenerateWEA(getWEAInfo(), WEAOptions{Limit: CMAMLongTextLimit, Source: "National Weather Service"})
+
+	assertEqual(t,
+		msg.Text,
+		"National Weather Service: FLASH FLOOD WARNING for Jackson; Woodruff until 2:45 AM. "+
+			"Flash Flood Warning issued August 15 at 8:45PM CDT by NWS Little Rock. Move to higher ground now. Turn around, don't drown.",
+		"Text does not match!")
+}
+
+func TestGenerateWEAShortensToLimit(t *testing.T) {
+	info := getWEAInfo()
+	info.EventType = strings.Repeat("Very Long Event Name ", 6)
+
+	msg := GenerateWEA(info, WEAOptions{})
+
+	if msg.Length > CMAMTextLimit {
+		t.Errorf("Expected at most %d characters but got %d: %q", CMAMTextLimit, msg.Length, msg.Text)
+	}
+
+	assertStartsWith(t, msg.Text, "NWS: VERY LONG EVENT NAME", "Text should keep its start")
+}
+
+func TestGenerateWEAUsesParameters(t *testing.T) {
+	info := getWEAInfo()
+	info.AddParameter(CMAMTextParameter, "Short text")
+	info.AddParameter(CMAMLongTextParameter, "Long text")
+
+	short := GenerateWEA(info, WEAOptions{})
+	long := GenerateWEA(info, WEAOptions{Limit: CMAMLongTextLimit})
+
+	assertEqual(t, short.Text, "Short text", "CMAMtext should be used")
+	assertEqual(t, short.FromParameter, true, "Text should come from the parameter")
+	assertEqual(t, long.Text, "Long text", "CMAMlongtext should be used")
+	assertEqual(t, CMAMText(info), "Short text", "CMAMText should return the parameter")
+}
+
+func TestGenerateWEASpanish(t *testing.T) {
+	info := getWEAInfo()
+	info.Language = "es-US"
+	info.EventType = "Aviso de Inundación Repentina"
+	info.Instruction = "Suba a terreno alto."
+
+	msg := GenerateWEA(info, WEAOptions{})
+
+	assertEqual(t, msg.Text, "NWS: AVISO DE INUNDACIÓN REPENTINA en esta área hasta las 2:45 AM. Suba a terreno alto.", "Text does not match!")
+	assertEqual(t, msg.Encoding, UCS2, "Accented text needs UCS-2")
+
+	msg = GenerateWEA(info, WEAOptions{Transliterate: true})
+
+	assertEqual(t, msg.Text, "NWS: AVISO DE INUNDACION REPENTINA en esta area hasta las 2:45 AM. Suba a terreno alto.", "Text does not match!")
+	assertEqual(t, msg.Encoding, GSM7, "Transliterated text should fit GSM 7-bit")
+}
+
+func TestWEALengthCountsExtensionCharacters(t *testing.T) {
+	encoding, n := WEALength("Cost [5€]")
+	assertEqual(t, encoding, GSM7, "Encoding does not match!")
+	assertEqual(t, n, 12, "Extension characters should count twice")
+
+	encoding, n = WEALength("Tornado 🌪")
+	assertEqual(t, encoding, UCS2, "Encoding does not match!")
+	assertEqual(t, n, 10, "Characters outside the BMP should count as two UTF-16 units")
+
+	encoding, n = WEALength("en esta área")
+	assertEqual(t, encoding, UCS2, "Encoding does not match!")
+	assertEqual(t, n, 12, "UCS-2 should count UTF-16 units")
+}